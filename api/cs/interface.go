@@ -1,6 +1,9 @@
 package cs
 
-import "github.com/celeskyking/go-nacos/types"
+import (
+	"github.com/celeskyking/go-nacos/pkg/logger"
+	"github.com/celeskyking/go-nacos/types"
+)
 
 type FileMirror interface {
 
@@ -16,3 +19,8 @@ type FileMirror interface {
 	//文件的md5值
 	MD5() string
 }
+
+//LoggerSetter 使用服务的Logger输出日志的FileMirror,config服务在监听文件的变化之前设置
+type LoggerSetter interface {
+	SetLogger(l logger.Logger)
+}
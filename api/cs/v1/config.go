@@ -1,22 +1,22 @@
 package v1
 
 import (
-	"errors"
+	"context"
 	"github.com/celeskyking/go-nacos/api"
 	"github.com/celeskyking/go-nacos/client/http"
 	"github.com/celeskyking/go-nacos/client/loadbalancer"
 	"github.com/celeskyking/go-nacos/err"
+	"github.com/celeskyking/go-nacos/pkg/logger"
 	"github.com/celeskyking/go-nacos/pkg/query"
-	"github.com/celeskyking/go-nacos/pkg/util"
+	"github.com/celeskyking/go-nacos/pkg/stats"
 	"github.com/celeskyking/go-nacos/types"
 	"github.com/parnurzeal/gorequest"
-	"github.com/sirupsen/logrus"
-	"io/ioutil"
+	"io"
 	"net/url"
-	"os"
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -43,22 +43,7 @@ func (s statusCodeConverter) Converter(code int) error {
 }
 
 func newConverter() statusCodeConverter {
-	return func(code int) error {
-		switch code {
-		case 200:
-			return nil
-		case 400:
-			return err.ErrBadRequest
-		case 403:
-			return err.ErrForbidden
-		case 404:
-			return err.ErrNotFound
-		case 500:
-			return err.ErrInternalServerError
-		default:
-			return err.ErrUnSupportStatusCode
-		}
-	}
+	return err.StatusError
 }
 
 func NewConverter() StatusCodeConverter {
@@ -74,10 +59,33 @@ type ConfigHttpClient interface {
 	PublishConfig(request *types.PublishConfig) (result *types.Result, err error)
 
 	DeleteConfigs(request *types.ConfigsRequest) (response *types.Result, err error)
+	LoadBalance() loadbalancer.LB
+	//WithContext 返回使用ctx作为父span的客户端
+	WithContext(ctx context.Context) ConfigHttpClient
+	//UpdateServers 使用addrs替换nacos server的列表
+	UpdateServers(addrs []string) error
+	//Stop 停止endpoint的刷新和nacos server的健康检查
+	Stop()
 }
 
+//NewConfigHttpClient 创建配置中心的http客户端,没有可用的nacos server或者地址不合法的时候只输出错误日志,
+//返回的客户端请求都会返回loadbalancer.ErrNoServerAvailable,直到通过UpdateServers或者ServerProvider更新了地址
 func NewConfigHttpClient(option *api.HttpConfigOption) ConfigHttpClient {
-	return newConfigHttpClient(option)
+	ch, er := newConfigHttpClient(option)
+	if er != nil {
+		ch.log.Error("create config http client failed", logger.Err(er))
+	}
+	return ch
+}
+
+//NewConfigHttpClientE 和NewConfigHttpClient相同,但是没有可用的nacos server或者地址不合法的时候返回错误
+func NewConfigHttpClientE(option *api.HttpConfigOption) (ConfigHttpClient, error) {
+	ch, er := newConfigHttpClient(option)
+	if er != nil {
+		ch.Stop()
+		return nil, er
+	}
+	return ch, nil
 }
 
 type configHttpClient struct {
@@ -86,38 +94,68 @@ type configHttpClient struct {
 	Option *api.HttpConfigOption
 
 	Converter StatusCodeConverter
+
+	stopC chan struct{}
+
+	stopOnce *sync.Once
+	//WithContext设置的context,作为span的父context
+	ctx context.Context
+
+	log logger.Logger
+
+	rec stats.Recorder
 }
 
-func newConfigHttpClient(option *api.HttpConfigOption) *configHttpClient {
-	ss := option.Servers
-	if len(ss) == 0 {
-		logrus.Errorf("不合法的nacos服务器列表,服务器最少存在一个")
-		os.Exit(1)
-	}
+func newConfigHttpClient(option *api.HttpConfigOption) (*configHttpClient, error) {
+	//没有配置server的时候使用endpoint或者ServerProvider提供的地址列表
+	ss, providers, er := api.InitialServers(option)
 	var servers []*loadbalancer.Server
-	for _, s := range ss {
-		u, er := api.ToURL(s)
-		if er != nil {
-			logrus.Errorf("不合法的server地址:%s", s)
-			os.Exit(1)
-		}
-		server := loadbalancer.NewServer(u, 100, path.Join(Prefix, HealthPath))
-		servers = append(servers, server)
+	if er == nil {
+		servers, er = api.ToServers(ss, path.Join(Prefix, HealthPath))
 	}
-	ch := &configHttpClient{}
-	if option.LBStrategy == api.RoundRobin {
-		ch.LB = loadbalancer.NewRoundRobin(servers, true)
-	} else {
-		ch.LB = loadbalancer.NewDirectProxy(servers)
+	ch := &configHttpClient{
+		stopC:    make(chan struct{}),
+		stopOnce: &sync.Once{},
+		ctx:      context.Background(),
+		log:      logger.With(option.Logger),
+		rec:      stats.With(option.Recorder),
 	}
+	ch.LB = api.NewLB(option, servers)
 	ch.Option = option
 	ch.Converter = NewConverter()
-	return ch
+	for _, p := range providers {
+		go api.WatchServers(p.Run(ch.stopC), ch.LB, path.Join(Prefix, HealthPath), ch.log)
+	}
+	return ch, er
+}
+
+//WithContext 返回使用ctx创建span的客户端,和当前的客户端共享负载均衡和生命周期
+func (c *configHttpClient) WithContext(ctx context.Context) ConfigHttpClient {
+	client := *c
+	client.ctx = ctx
+	return &client
+}
+
+func (c *configHttpClient) LoadBalance() loadbalancer.LB {
+	return c.LB
+}
+
+func (c *configHttpClient) UpdateServers(addrs []string) error {
+	return api.UpdateServers(c.LB, addrs, path.Join(Prefix, HealthPath))
+}
+
+//Stop 停止endpoint的刷新和nacos server的健康检查,可以重复调用
+func (c *configHttpClient) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stopC)
+		if closer, ok := c.LB.(io.Closer); ok {
+			closer.Close()
+		}
+	})
 }
 
 func (c *configHttpClient) GetConfigs(request *types.ConfigsRequest) (*types.ConfigsResponse, error) {
-	logrus.Infof("get configs,request%+v", request)
-	u := api.SelectOne(c.LB)
+	c.log.Debug("get configs", logger.F("tenant", request.Tenant), logger.F("group", request.Group), logger.F("dataId", request.DataID))
 	if request.Tenant == "Public" {
 		request.Tenant = ""
 	}
@@ -125,8 +163,13 @@ func (c *configHttpClient) GetConfigs(request *types.ConfigsRequest) (*types.Con
 	if er != nil {
 		return nil, er
 	}
-	response, bs, errs := http.New().Timeout(DefaultConnectTimeout).Get(u + path.Join(Prefix, c.Option.Version, GetConfigPath)).Query(req).EndBytes()
-	er = handleErrorResponse(c.Converter, response, errs)
+	p := path.Join(Prefix, c.Option.Version, GetConfigPath)
+	ctx, span := api.StartRequestSpan(c.ctx, "GET "+p, req)
+	response, bs, errs := api.Do(ctx, c.LB, c.Option.RetryPolicy, c.log, c.rec, "GET "+p, func(server string, timeout time.Duration) (gorequest.Response, []byte, []error) {
+		return http.NewConfigHttp().Timeout(timeout).Get(server + p).Query(req).EndBytes()
+	})
+	api.EndRequestSpan(span, response, errs)
+	er = handleErrorResponse(c.Converter, response, bs, errs)
 	if er == nil {
 		v := &types.ConfigsResponse{
 			Value: string(bs),
@@ -139,14 +182,27 @@ func (c *configHttpClient) GetConfigs(request *types.ConfigsRequest) (*types.Con
 
 //ListenConfigs 监听变更并且回调变更,当前的callback方法并不是纯异步的操作,只是同步操作
 func (c *configHttpClient) ListenConfigs(request *types.ListenConfigsRequest) ([]*types.ListenChange, error) {
-	logrus.Infof("listen configs, request:%+s", util.ToJSONString(request))
-	u := api.SelectOne(c.LB) + path.Join(Prefix, c.Option.Version, ListenerConfigPath)
+	c.log.Debug("listen configs", logger.F("count", len(request.ListeningConfigs)))
+	p := path.Join(Prefix, c.Option.Version, ListenerConfigPath)
 	req := request.Line()
-	resp, body, errs := http.New().Timeout(time.Minute).Post(u).
-		Set("Long-Pulling-Timeout", DefaultPollingTimeout).
-		Send("Listening-Configs=" + req).
-		End()
-	er := handleErrorResponse(c.Converter, resp, errs)
+	//长轮询使用自己的超时时间,不使用重试策略的超时时间
+	ctx, span := api.StartRequestSpan(c.ctx, "POST "+p, "")
+	resp, bs, errs := api.DoLongPoll(ctx, c.LB, c.Option.RetryPolicy, c.log, c.rec, "POST "+p, func(server string, timeout time.Duration) (gorequest.Response, []byte, []error) {
+		return http.NewConfigHttp().Timeout(time.Minute).Post(server+p).
+			Set("Long-Pulling-Timeout", DefaultPollingTimeout).
+			Send("Listening-Configs=" + req).
+			EndBytes()
+	})
+	api.EndRequestSpan(span, resp, errs)
+	body := string(bs)
+	er := handleErrorResponse(c.Converter, resp, bs, errs)
+	if er != nil {
+		c.rec.LongPoll(stats.LongPollError)
+	} else if len(strings.TrimSpace(body)) == 0 {
+		c.rec.LongPoll(stats.LongPollUnchanged)
+	} else {
+		c.rec.LongPoll(stats.LongPollChanged)
+	}
 	if er == nil {
 		if len(body) == 0 {
 			return nil, nil
@@ -182,15 +238,18 @@ func (c *configHttpClient) ListenConfigs(request *types.ListenConfigsRequest) ([
 
 //PublishConfig 发布配置信息
 func (c *configHttpClient) PublishConfig(request *types.PublishConfig) (*types.Result, error) {
-	logrus.Infof("publish configs, request:%+v", request)
-	u := api.SelectOne(c.LB)
+	c.log.Debug("publish configs", logger.F("tenant", request.Tenant), logger.F("group", request.Group), logger.F("dataId", request.DataID), logger.F("content", request.Content))
 	req, er := query.Marshal(request)
 	if er != nil {
 		return nil, er
 	}
-	u = u + path.Join(Prefix, c.Option.Version, PublishConfigPath)
-	resp, body, errs := http.New().Post(u).SendString(req).EndBytes()
-	er = handleErrorResponse(c.Converter, resp, errs)
+	p := path.Join(Prefix, c.Option.Version, PublishConfigPath)
+	ctx, span := api.StartRequestSpan(c.ctx, "POST "+p, req)
+	resp, body, errs := api.Do(ctx, c.LB, c.Option.RetryPolicy, c.log, c.rec, "POST "+p, func(server string, timeout time.Duration) (gorequest.Response, []byte, []error) {
+		return http.NewConfigHttp().Timeout(timeout).Post(server + p).SendString(req).EndBytes()
+	})
+	api.EndRequestSpan(span, resp, errs)
+	er = handleErrorResponse(c.Converter, resp, body, errs)
 	if er == nil {
 		r, er := strconv.ParseBool(string(body))
 		if er != nil {
@@ -202,14 +261,18 @@ func (c *configHttpClient) PublishConfig(request *types.PublishConfig) (*types.R
 }
 
 func (c *configHttpClient) DeleteConfigs(request *types.ConfigsRequest) (*types.Result, error) {
-	logrus.Infof("delete configs, request:%+v", request)
-	u := api.SelectOne(c.LB)
+	c.log.Debug("delete configs", logger.F("tenant", request.Tenant), logger.F("group", request.Group), logger.F("dataId", request.DataID))
 	req, er := query.Marshal(request)
 	if er != nil {
 		return nil, er
 	}
-	resp, bs, errs := http.New().Delete(u + path.Join(Prefix, c.Option.Version, DeleteConfigPath)).SendString(req).EndBytes()
-	er = handleErrorResponse(c.Converter, resp, errs)
+	p := path.Join(Prefix, c.Option.Version, DeleteConfigPath)
+	ctx, span := api.StartRequestSpan(c.ctx, "DELETE "+p, req)
+	resp, bs, errs := api.Do(ctx, c.LB, c.Option.RetryPolicy, c.log, c.rec, "DELETE "+p, func(server string, timeout time.Duration) (gorequest.Response, []byte, []error) {
+		return http.NewConfigHttp().Timeout(timeout).Delete(server + p).SendString(req).EndBytes()
+	})
+	api.EndRequestSpan(span, resp, errs)
+	er = handleErrorResponse(c.Converter, resp, bs, errs)
 	if er == nil {
 		r, er := strconv.ParseBool(string(bs))
 		if er != nil {
@@ -221,16 +284,16 @@ func (c *configHttpClient) DeleteConfigs(request *types.ConfigsRequest) (*types.
 	}
 }
 
-func handleErrorResponse(converter StatusCodeConverter, resp gorequest.Response, errs []error) error {
-	if resp != nil && errs != nil {
-		data, er := ioutil.ReadAll(resp.Body)
-		if er != nil {
-			return errors.New("go-nacos system error,statusCode:5xx")
-		}
-		return err.NewHttpClientError(string(data), errs...)
+//handleErrorResponse 请求失败或者converter返回错误的时候返回NacosError,converter返回的错误作为NacosError.Cause
+func handleErrorResponse(converter StatusCodeConverter, resp gorequest.Response, body []byte, errs []error) error {
+	if resp == nil || len(errs) != 0 {
+		return err.NewNacosError(resp, body, errs...)
 	}
-	if resp == nil {
-		return err.NewHttpClientError("valid response")
+	cause := converter.Converter(resp.StatusCode)
+	if cause == nil {
+		return nil
 	}
-	return converter.Converter(resp.StatusCode)
+	e := err.NewNacosError(resp, body)
+	e.Cause = cause
+	return e
 }
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"github.com/celeskyking/go-nacos/api/ns/endpoint"
+	"github.com/celeskyking/go-nacos/client/loadbalancer"
+	"github.com/celeskyking/go-nacos/pkg/logger"
+	"github.com/pkg/errors"
+)
+
+//NewEndpoint 按照Endpoint和EndpointOptions创建endpoint,EndpointEnabled为false的时候返回nil
+func NewEndpoint(option *HttpConfigOption) (*endpoint.Endpoint, error) {
+	if !option.EndpointEnabled {
+		return nil, nil
+	}
+	o := &endpoint.Options{}
+	if option.EndpointOptions != nil {
+		c := *option.EndpointOptions
+		o = &c
+	}
+	if o.Address == "" {
+		o.Address = option.Endpoint
+	}
+	if o.Logger == nil {
+		o.Logger = option.Logger
+	}
+	if o.Address == "" {
+		return nil, errors.New("endpoint address is empty")
+	}
+	return endpoint.NewEndpointWithOptions(o), nil
+}
+
+//InitialServers 返回初始的nacos server列表和需要监听的ServerProvider,包括开启的endpoint和ServerProvider。
+//Servers为空的时候从provider同步获取,没有获取到地址的时候返回错误,同时仍然返回provider
+func InitialServers(option *HttpConfigOption) ([]string, []ServerProvider, error) {
+	var providers []ServerProvider
+	e, er := NewEndpoint(option)
+	if er != nil {
+		return nil, nil, er
+	}
+	if e != nil {
+		providers = append(providers, e)
+	}
+	if option.ServerProvider != nil {
+		providers = append(providers, withLogger(option.ServerProvider, option.Logger))
+	}
+	servers := option.Servers
+	for _, p := range providers {
+		if len(servers) > 0 {
+			break
+		}
+		if servers, er = p.Servers(); er != nil {
+			logger.With(option.Logger).Error("server provider not available", logger.Err(er))
+		}
+	}
+	if len(servers) == 0 {
+		//provider之后可用的时候通过WatchServers更新地址列表
+		return nil, providers, errors.New("nacos server list is empty")
+	}
+	return servers, providers, nil
+}
+
+//withLogger DNSProvider没有设置Logger的时候返回使用客户端的Logger的副本
+func withLogger(p ServerProvider, l logger.Logger) ServerProvider {
+	d, ok := p.(*DNSProvider)
+	if !ok || d.Logger != nil || l == nil {
+		return p
+	}
+	c := *d
+	c.Logger = l
+	return &c
+}
+
+//ToServers 把nacos server的地址转换为负载均衡的server,healthPath为健康检查的路径
+func ToServers(addrs []string, healthPath string) ([]*loadbalancer.Server, error) {
+	var servers []*loadbalancer.Server
+	for _, s := range addrs {
+		u, er := ToURL(s)
+		if er != nil {
+			return nil, errors.Wrapf(er, "invalid nacos server address:%s", s)
+		}
+		servers = append(servers, loadbalancer.NewServer(u, 100, healthPath))
+	}
+	return servers, nil
+}
+
+//UpdateServers 使用addrs替换lb的server列表,地址不合法或者为空的时候返回错误
+func UpdateServers(lb loadbalancer.LB, addrs []string, healthPath string) error {
+	servers, er := ToServers(addrs, healthPath)
+	if er != nil {
+		return er
+	}
+	if len(servers) == 0 {
+		return errors.New("nacos server list is empty")
+	}
+	lb.RefreshServers(servers)
+	return nil
+}
+
+//WatchServers 把provider发送的地址列表刷新到lb,包含不合法地址的列表会被忽略,changes关闭之后返回。
+//log为nil的时候使用全局的Logger
+func WatchServers(changes <-chan []string, lb loadbalancer.LB, healthPath string, log logger.Logger) {
+	log = logger.With(log)
+	for addrs := range changes {
+		if er := UpdateServers(lb, addrs, healthPath); er != nil {
+			log.Error("ignore server list from provider", logger.F("servers", addrs), logger.Err(er))
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"github.com/celeskyking/go-nacos/client/loadbalancer"
+	"github.com/celeskyking/go-nacos/pkg/logger"
+	"testing"
+)
+
+func TestWatchServers(t *testing.T) {
+	lb := loadbalancer.NewDirectProxy(nil)
+	changes := make(chan []string, 2)
+	changes <- []string{"10.0.0.1:8848", "%%"}
+	changes <- []string{"10.0.0.2:8848"}
+	close(changes)
+	WatchServers(changes, lb, "/health", nil)
+	servers := lb.GetServers()
+	if len(servers) != 1 || servers[0].URL.Host != "10.0.0.2:8848" {
+		t.Fatal("expect invalid list ignored and valid list refreshed")
+	}
+	if _, er := NewEndpoint(&HttpConfigOption{EndpointEnabled: true}); er == nil {
+		t.Fatal("expect error for empty endpoint address")
+	}
+}
+
+func TestDNSProvider(t *testing.T) {
+	p := NewDNSProvider("localhost", 8848)
+	servers, er := p.Servers()
+	if er != nil {
+		t.Fatalf("resolve localhost error:%+v", er)
+	}
+	found := false
+	for _, s := range servers {
+		found = found || s == "127.0.0.1:8848"
+	}
+	if !found {
+		t.Fatalf("expect 127.0.0.1:8848 in servers:%v", servers)
+	}
+	stop := make(chan struct{})
+	c := p.Run(stop)
+	lb := loadbalancer.NewRoundRobin(nil, false)
+	go WatchServers(c, lb, "/health", nil)
+	close(stop)
+	for range c {
+	}
+}
+
+func TestInitialServers_ProviderLogger(t *testing.T) {
+	p := NewDNSProvider("localhost", 8848)
+	var l logger.Logger = logger.Nop{}
+	_, providers, _ := InitialServers(&HttpConfigOption{ServerProvider: p, Logger: l})
+	d, ok := providers[0].(*DNSProvider)
+	if !ok || d.Logger != l || p.Logger != nil {
+		t.Fatal("expect a copy of the provider using the client's logger")
+	}
+}
@@ -4,56 +4,282 @@ import (
 	"bufio"
 	"bytes"
 	"github.com/celeskyking/go-nacos/client/http"
-	"github.com/sirupsen/logrus"
-	"path"
+	"github.com/celeskyking/go-nacos/err"
+	"github.com/celeskyking/go-nacos/pkg/logger"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"time"
 )
 
 const (
 	ServerListPath string = "nacos/serverlist"
 	Interval              = 30 * time.Second
+
+	DefaultContextPath = "nacos"
+
+	DefaultClusterName = "serverlist"
+	//DefaultBackoff 请求失败之后第一次重试的等待时间,之后每次翻倍,最长为Interval
+	DefaultBackoff = time.Second
+
+	DefaultTimeout = 5 * time.Second
 )
 
+//Options endpoint(地址服务器)的配置,请求的地址为 http(s)://Address/ContextPath/ClusterName?namespace=Namespace
+type Options struct {
+	//endpoint的地址,例如 jmenv.tbsite.net:8080
+	Address string
+	//是否使用https
+	IsHttps bool
+	//上下文路径,默认为nacos
+	ContextPath string
+	//地址列表的名字,默认为serverlist
+	ClusterName string
+	//命名空间,不为空的时候作为namespace参数
+	Namespace string
+	//额外的查询参数
+	Query url.Values
+	//刷新的间隔,默认为30s
+	Interval time.Duration
+	//请求失败之后第一次重试的等待时间,默认为1s
+	Backoff time.Duration
+	//每次请求的超时时间,默认为5s
+	Timeout time.Duration
+	//保存最后一次获取的地址列表的文件,冷启动endpoint不可用的时候使用,为空的时候不保存
+	CacheFile string
+	//输出日志使用的Logger,为空的时候使用全局的Logger
+	Logger logger.Logger
+}
+
 func NewEndpoint(address string) *Endpoint {
+	return NewEndpointWithOptions(&Options{
+		Address: address,
+	})
+}
+
+//NewEndpointWithOptions 按照options创建endpoint,没有设置的选项使用默认值
+func NewEndpointWithOptions(options *Options) *Endpoint {
+	o := *options
+	if o.ContextPath == "" {
+		o.ContextPath = DefaultContextPath
+	}
+	if o.ClusterName == "" {
+		o.ClusterName = DefaultClusterName
+	}
+	if o.Interval <= 0 {
+		o.Interval = Interval
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = DefaultBackoff
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultTimeout
+	}
 	return &Endpoint{
-		address: address,
+		address: o.Address,
+		options: &o,
+		log:     logger.With(o.Logger),
 	}
 }
 
 type Endpoint struct {
 	//endpoint的地址
 	address string
+
+	options *Options
+
+	log logger.Logger
+
+	lock sync.Mutex
+	//Servers最后一次从endpoint获取到的地址列表,Run第一次刷新的时候直接使用,避免启动的时候重复请求
+	fetched []string
+
+	fetchedAt time.Time
+}
+
+//URL 返回请求地址列表的url
+func (e *Endpoint) URL() string {
+	scheme := "http"
+	if e.options.IsHttps {
+		scheme = "https"
+	}
+	u := url.URL{
+		Scheme: scheme,
+		Host:   e.address,
+		Path:   "/" + strings.Trim(e.options.ContextPath, "/") + "/" + strings.Trim(e.options.ClusterName, "/"),
+	}
+	q := url.Values{}
+	for k, v := range e.options.Query {
+		q[k] = v
+	}
+	if e.options.Namespace != "" {
+		q.Set("namespace", e.options.Namespace)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+//Fetch 请求一次地址列表,空的列表也作为错误返回
+func (e *Endpoint) Fetch() ([]string, error) {
+	resp, data, errs := http.New().Timeout(e.options.Timeout).Get(e.URL()).EndBytes()
+	if len(errs) != 0 || resp.StatusCode != 200 {
+		return nil, errors.Wrap(err.NewNacosError(resp, data, errs...), "request endpoint")
+	}
+	servers := parse(data)
+	if len(servers) == 0 {
+		return nil, errors.New("endpoint returns empty server list")
+	}
+	return servers, nil
+}
+
+func parse(data []byte) []string {
+	var servers []string
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		servers = append(servers, line)
+	}
+	return servers
 }
 
+//LoadCache 读取CacheFile中保存的地址列表
+func (e *Endpoint) LoadCache() ([]string, error) {
+	if e.options.CacheFile == "" {
+		return nil, errors.New("endpoint cache file not set")
+	}
+	data, er := ioutil.ReadFile(e.options.CacheFile)
+	if er != nil {
+		return nil, errors.Wrap(er, "read endpoint cache")
+	}
+	servers := parse(data)
+	if len(servers) == 0 {
+		return nil, errors.New("endpoint cache is empty")
+	}
+	return servers, nil
+}
+
+func (e *Endpoint) saveCache(servers []string) {
+	if e.options.CacheFile == "" {
+		return
+	}
+	if er := os.MkdirAll(filepath.Dir(e.options.CacheFile), 0755); er != nil {
+		e.log.Error("create endpoint cache dir failed", logger.F("path", e.options.CacheFile), logger.Err(er))
+		return
+	}
+	//先在同一个目录写临时文件再重命名,避免进程退出的时候留下不完整的文件,多个进程共用CacheFile的时候也不会互相覆盖临时文件
+	if er := writeFile(e.options.CacheFile, []byte(strings.Join(servers, "\n")+"\n")); er != nil {
+		e.log.Error("write endpoint cache failed", logger.F("path", e.options.CacheFile), logger.Err(er))
+	}
+}
+
+func writeFile(path string, data []byte) error {
+	f, er := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if er != nil {
+		return er
+	}
+	tmp := f.Name()
+	if _, er = f.Write(data); er == nil {
+		er = f.Chmod(0644)
+	}
+	if closeErr := f.Close(); er == nil {
+		er = closeErr
+	}
+	if er == nil {
+		er = os.Rename(tmp, path)
+	}
+	if er != nil {
+		_ = os.Remove(tmp)
+	}
+	return er
+}
+
+//Servers 同步获取一次地址列表,endpoint不可用的时候使用CacheFile中的地址列表
+func (e *Endpoint) Servers() ([]string, error) {
+	servers, er := e.Fetch()
+	if er == nil {
+		e.saveCache(servers)
+		e.lock.Lock()
+		e.fetched, e.fetchedAt = servers, time.Now()
+		e.lock.Unlock()
+		return servers, nil
+	}
+	cached, cacheErr := e.LoadCache()
+	if cacheErr != nil {
+		return nil, er
+	}
+	e.log.Warn("endpoint not available, use cached server list", logger.F("endpoint", e.URL()), logger.Err(er))
+	return cached, nil
+}
+
+//Run 立即获取地址列表,之后按照Interval刷新,失败的时候按照Backoff重试。地址列表变化的时候发送到返回的channel,
+//第一次获取失败的时候发送CacheFile中的地址列表。stop关闭之后关闭返回的channel。
+//Interval之内调用过Servers的时候不会立即请求,已经获取到的地址列表不会重复发送
 func (e *Endpoint) Run(stop <-chan struct{}) chan []string {
 	notify := make(chan []string, 0)
-	ticker := time.NewTicker(Interval)
+	last, wait := e.takeFetched()
 	go func() {
+		defer close(notify)
+		backoff := e.options.Backoff
+		first := last == nil
 		for {
-			select {
-			case <-ticker.C:
-				resp, data, errs := http.New().Get("http://" + path.Join(e.address, ServerListPath)).EndBytes()
-				if len(errs) != 0 {
-					for _, e := range errs {
-						logrus.Errorf("endpoint failed:%+v", e)
-						return
-					}
-				}
-				if resp.StatusCode != 200 {
-					logrus.Error("endpoint statusCode not ok")
+			if wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-stop:
+					timer.Stop()
 					return
 				}
-				var servers []string
-				s := bufio.NewScanner(bytes.NewReader(data))
-				for s.Scan() {
-					servers = append(servers, s.Text())
+			}
+			servers, er := e.Fetch()
+			wait = e.options.Interval
+			if er != nil {
+				e.log.Error("endpoint failed", logger.F("endpoint", e.URL()), logger.F("retryIn", backoff), logger.Err(er))
+				wait = backoff
+				backoff = backoff * 2
+				if backoff > e.options.Interval {
+					backoff = e.options.Interval
+				}
+				if first {
+					servers, _ = e.LoadCache()
+				} else {
+					servers = nil
+				}
+			} else {
+				backoff = e.options.Backoff
+				e.saveCache(servers)
+			}
+			first = false
+			if len(servers) > 0 && !reflect.DeepEqual(servers, last) {
+				select {
+				case notify <- servers:
+					last = servers
+				case <-stop:
+					return
 				}
-				notify <- servers
-			case <-stop:
-				close(notify)
-				return
 			}
 		}
 	}()
 	return notify
 }
+
+//takeFetched 返回Servers获取到的地址列表以及距离下一次刷新的时间,超过Interval的时候返回nil。每次获取的结果只使用一次
+func (e *Endpoint) takeFetched() ([]string, time.Duration) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	servers, at := e.fetched, e.fetchedAt
+	e.fetched = nil
+	wait := e.options.Interval - time.Since(at)
+	if servers == nil || wait <= 0 {
+		return nil, 0
+	}
+	return servers, wait
+}
@@ -0,0 +1,154 @@
+package endpoint
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeEndpoint struct {
+	lock sync.Mutex
+
+	status int
+
+	body string
+
+	requests []*http.Request
+}
+
+func (f *fakeEndpoint) set(status int, body string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.status, f.body = status, body
+}
+
+func (f *fakeEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.requests = append(f.requests, r)
+	w.WriteHeader(f.status)
+	_, _ = w.Write([]byte(f.body))
+}
+
+func (f *fakeEndpoint) lastRequest() *http.Request {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.requests[len(f.requests)-1]
+}
+
+func receive(t *testing.T, c chan []string) []string {
+	select {
+	case servers := <-c:
+		return servers
+	case <-time.After(2 * time.Second):
+		t.Fatal("expect server list from endpoint")
+	}
+	return nil
+}
+
+func TestEndpoint_Run(t *testing.T) {
+	f := &fakeEndpoint{status: http.StatusInternalServerError}
+	s := httptest.NewServer(f)
+	defer s.Close()
+	cache := filepath.Join(t.TempDir(), "servers")
+	e := NewEndpointWithOptions(&Options{
+		Address:     strings.TrimPrefix(s.URL, "http://"),
+		ContextPath: "/custom",
+		ClusterName: "nacos-list",
+		Namespace:   "dev",
+		Interval:    20 * time.Millisecond,
+		Backoff:     5 * time.Millisecond,
+		CacheFile:   cache,
+	})
+	stop := make(chan struct{})
+	c := e.Run(stop)
+	//失败之后按照backoff重试,不会停止刷新
+	time.Sleep(20 * time.Millisecond)
+	f.set(http.StatusOK, "10.0.0.1:8848\n\n10.0.0.2:8848\n")
+	if servers := receive(t, c); !reflect.DeepEqual(servers, []string{"10.0.0.1:8848", "10.0.0.2:8848"}) {
+		t.Fatalf("unexpected servers:%v", servers)
+	}
+	r := f.lastRequest()
+	if r.URL.Path != "/custom/nacos-list" || r.URL.Query().Get("namespace") != "dev" {
+		t.Fatalf("unexpected endpoint request:%s", r.URL.String())
+	}
+	f.set(http.StatusOK, "10.0.0.3:8848")
+	if servers := receive(t, c); !reflect.DeepEqual(servers, []string{"10.0.0.3:8848"}) {
+		t.Fatalf("unexpected servers:%v", servers)
+	}
+	close(stop)
+	for range c {
+	}
+
+	//endpoint不可用的时候使用缓存的地址列表冷启动
+	f.set(http.StatusServiceUnavailable, "")
+	cached, er := NewEndpointWithOptions(&Options{Address: strings.TrimPrefix(s.URL, "http://"), CacheFile: cache}).Servers()
+	if er != nil || !reflect.DeepEqual(cached, []string{"10.0.0.3:8848"}) {
+		t.Fatalf("expect cached servers, got:%v, %+v", cached, er)
+	}
+	stop = make(chan struct{})
+	defer close(stop)
+	c = NewEndpointWithOptions(&Options{Address: strings.TrimPrefix(s.URL, "http://"), CacheFile: cache, Backoff: time.Hour}).Run(stop)
+	if servers := receive(t, c); !reflect.DeepEqual(servers, []string{"10.0.0.3:8848"}) {
+		t.Fatalf("expect cached servers on cold start, got:%v", servers)
+	}
+}
+
+func TestEndpoint_Fetch(t *testing.T) {
+	f := &fakeEndpoint{status: http.StatusNotFound, body: "10.0.0.1:8848"}
+	s := httptest.NewServer(f)
+	defer s.Close()
+	e := NewEndpoint(strings.TrimPrefix(s.URL, "http://"))
+	if _, er := e.Fetch(); er == nil {
+		t.Fatal("expect error for non-200 response")
+	}
+	f.set(http.StatusOK, "\n")
+	if _, er := e.Fetch(); er == nil {
+		t.Fatal("expect error for empty server list")
+	}
+	if _, er := e.Servers(); er == nil {
+		t.Fatal("expect error without cache")
+	}
+	if r := f.lastRequest(); r.URL.Path != "/"+ServerListPath {
+		t.Fatalf("unexpected default path:%s", r.URL.Path)
+	}
+}
+
+func TestEndpoint_ServersThenRun(t *testing.T) {
+	f := &fakeEndpoint{status: http.StatusOK, body: "10.0.0.1:8848"}
+	s := httptest.NewServer(f)
+	defer s.Close()
+	dir := t.TempDir()
+	e := NewEndpointWithOptions(&Options{
+		Address:   strings.TrimPrefix(s.URL, "http://"),
+		Interval:  100 * time.Millisecond,
+		CacheFile: filepath.Join(dir, "servers"),
+	})
+	if _, er := e.Servers(); er != nil {
+		t.Fatal(er)
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	c := e.Run(stop)
+	//启动的时候使用Servers获取到的结果,Interval之后才会再次请求
+	time.Sleep(30 * time.Millisecond)
+	f.lock.Lock()
+	n := len(f.requests)
+	f.lock.Unlock()
+	if n != 1 {
+		t.Fatalf("expect endpoint requested once on startup, got:%d", n)
+	}
+	f.set(http.StatusOK, "10.0.0.2:8848")
+	if servers := receive(t, c); !reflect.DeepEqual(servers, []string{"10.0.0.2:8848"}) {
+		t.Fatalf("unexpected servers:%v", servers)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 1 {
+		t.Fatalf("expect no temporary cache file left, got:%v", files)
+	}
+}
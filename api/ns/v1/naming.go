@@ -1,22 +1,20 @@
 package v1
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/celeskyking/go-nacos/api"
-	"github.com/celeskyking/go-nacos/api/ns/endpoint"
 	"github.com/celeskyking/go-nacos/client/http"
 	"github.com/celeskyking/go-nacos/client/loadbalancer"
 	"github.com/celeskyking/go-nacos/err"
+	"github.com/celeskyking/go-nacos/pkg/logger"
 	"github.com/celeskyking/go-nacos/pkg/query"
-	"github.com/celeskyking/go-nacos/pkg/util"
+	"github.com/celeskyking/go-nacos/pkg/stats"
 	"github.com/celeskyking/go-nacos/types"
 	"github.com/parnurzeal/gorequest"
-	"github.com/pkg/errors"
-	"github.com/sirupsen/logrus"
-	"io/ioutil"
-	"os"
+	"io"
 	"path"
-	"strconv"
+	"sync"
 	"time"
 )
 
@@ -80,64 +78,55 @@ type NamingHttpClient interface {
 	UpdateServiceInstanceHealthy(request *types.UpdateServiceInstanceHealthyRequest) (*types.Result, error)
 
 	LoadBalance() loadbalancer.LB
+	//WithContext 返回使用ctx作为父span的客户端
+	WithContext(ctx context.Context) NamingHttpClient
+	//UpdateServers 使用addrs替换nacos server的列表
+	UpdateServers(addrs []string) error
 
 	Stop()
 }
 
+//NewNamingHttpClient 创建naming的http客户端,没有可用的nacos server或者地址不合法的时候只输出错误日志,
+//返回的客户端请求都会返回loadbalancer.ErrNoServerAvailable,直到通过UpdateServers或者ServerProvider更新了地址
 func NewNamingHttpClient(option *api.HttpConfigOption) NamingHttpClient {
-	ss := option.Servers
-	if len(ss) == 0 {
-		logrus.Errorf("不合法的nacos服务器列表,服务器最少存在一个")
-		os.Exit(1)
-	}
-	var servers []*loadbalancer.Server
-	for _, s := range ss {
-		u, er := api.ToURL(s)
-		if er != nil {
-			logrus.Errorf("不合法的server地址:%s", s)
-			os.Exit(1)
-		}
-		server := loadbalancer.NewServer(u, 100, path.Join(Prefix, HealthPath))
-		servers = append(servers, server)
+	ch, er := newNamingHttpClient(option)
+	if er != nil {
+		ch.log.Error("create naming http client failed", logger.Err(er))
 	}
-	var e *endpoint.Endpoint
-	var serverChanges chan []string
-	stopC := make(chan struct{})
-	if option.EndpointEnabled {
-		endpointAddr := option.Endpoint
-		if endpointAddr == "" {
-			logrus.Fatal("endpoint的address不能够为空")
-			os.Exit(1)
-		}
-		e = endpoint.NewEndpoint(endpointAddr)
-		serverChanges = e.Run(stopC)
+	return ch
+}
+
+//NewNamingHttpClientE 和NewNamingHttpClient相同,但是没有可用的nacos server或者地址不合法的时候返回错误
+func NewNamingHttpClientE(option *api.HttpConfigOption) (NamingHttpClient, error) {
+	ch, er := newNamingHttpClient(option)
+	if er != nil {
+		ch.Stop()
+		return nil, er
 	}
+	return ch, nil
+}
 
-	ch := &namingHttpClient{}
-	if option.LBStrategy == api.RoundRobin {
-		ch.LB = loadbalancer.NewRoundRobin(servers, true)
-	} else {
-		ch.LB = loadbalancer.NewDirectProxy(servers)
+func newNamingHttpClient(option *api.HttpConfigOption) (*namingHttpClient, error) {
+	//没有配置server的时候使用endpoint或者ServerProvider提供的地址列表
+	ss, providers, er := api.InitialServers(option)
+	var servers []*loadbalancer.Server
+	if er == nil {
+		servers, er = api.ToServers(ss, path.Join(Prefix, HealthPath))
 	}
+	stopC := make(chan struct{})
+	ch := &namingHttpClient{
+		stopC:    stopC,
+		stopOnce: &sync.Once{},
+		ctx:      context.Background(),
+		log:      logger.With(option.Logger),
+		rec:      stats.With(option.Recorder),
+	}
+	ch.LB = api.NewLB(option, servers)
 	ch.Option = option
-	go func() {
-		for ss := range serverChanges {
-			var servers []*loadbalancer.Server
-			for _, s := range ss {
-				u, er := api.ToURL(s)
-				if er != nil {
-					logrus.Errorf("不合法的server地址:%s", s)
-					os.Exit(1)
-				}
-				server := loadbalancer.NewServer(u, 100, path.Join(Prefix, HealthPath))
-				servers = append(servers, server)
-			}
-			if len(servers) > 0 {
-				ch.LB.RefreshServers(servers)
-			}
-		}
-	}()
-	return ch
+	for _, p := range providers {
+		go api.WatchServers(p.Run(stopC), ch.LB, path.Join(Prefix, HealthPath), ch.log)
+	}
+	return ch, er
 }
 
 //namingHttpClient implement NamingHttpClient service
@@ -146,15 +135,31 @@ type namingHttpClient struct {
 
 	Option *api.HttpConfigOption
 
-	endpoint *endpoint.Endpoint
-
 	stopC chan struct{}
+
+	stopOnce *sync.Once
+	//WithContext设置的context,作为span的父context
+	ctx context.Context
+
+	log logger.Logger
+
+	rec stats.Recorder
+}
+
+//request 按照重试策略请求nacos server,q为url的参数,使用WithContext设置的context创建span
+func (n *namingHttpClient) request(method, p, q string) (gorequest.Response, []byte, []error) {
+	endpoint := method + " " + p
+	ctx, span := api.StartRequestSpan(n.ctx, endpoint, q)
+	resp, body, errs := api.Do(ctx, n.LB, n.Option.RetryPolicy, n.log, n.rec, endpoint, func(server string, timeout time.Duration) (gorequest.Response, []byte, []error) {
+		return http.NewNamingHttp().Timeout(timeout).CustomMethod(method, server+p).Query(q).EndBytes()
+	})
+	api.EndRequestSpan(span, resp, errs)
+	return resp, body, errs
 }
 
 func (n *namingHttpClient) GetNacosServers() (*types.NacosServers, error) {
-	url := api.SelectOne(n.LB) + path.Join(Prefix, n.Option.Version, NacosServersPath)
-	resp, body, errs := http.NewNamingHttp().Timeout(DefaultConnectTimeout).Get(url).EndBytes()
-	er := handleErrorResponse(resp, errs)
+	resp, body, errs := n.request(gorequest.GET, path.Join(Prefix, n.Option.Version, NacosServersPath), "")
+	er := handleErrorResponse(resp, body, errs)
 	if er != nil {
 		return nil, er
 	}
@@ -163,85 +168,97 @@ func (n *namingHttpClient) GetNacosServers() (*types.NacosServers, error) {
 	return &servers, er
 }
 
+//WithContext 返回使用ctx创建span的客户端,和当前的客户端共享负载均衡和生命周期
+func (n *namingHttpClient) WithContext(ctx context.Context) NamingHttpClient {
+	c := *n
+	c.ctx = ctx
+	return &c
+}
+
 func (n *namingHttpClient) LoadBalance() loadbalancer.LB {
 	return n.LB
 }
 
+func (n *namingHttpClient) UpdateServers(addrs []string) error {
+	return api.UpdateServers(n.LB, addrs, path.Join(Prefix, HealthPath))
+}
+
+//Stop 停止endpoint的刷新和nacos server的健康检查,可以重复调用
 func (n *namingHttpClient) Stop() {
-	n.stopC <- struct{}{}
+	n.stopOnce.Do(func() {
+		close(n.stopC)
+		if c, ok := n.LB.(io.Closer); ok {
+			c.Close()
+		}
+	})
 }
 
 func (n *namingHttpClient) RegisterServiceInstance(instance *types.ServiceInstance) (*types.Result, error) {
-	logrus.Infof("register service instance:%s", util.ToJSONString(instance))
-	url := api.SelectOne(n.LB) + path.Join(Prefix, n.Option.Version, InstancePath)
+	n.log.Debug("register service instance", instanceFields(instance)...)
 	req, er := query.Marshal(instance)
 	if er != nil {
 		return nil, er
 	}
-	logrus.Info("register instance:" + req)
-	resp, body, errs := http.NewNamingHttp().Timeout(DefaultConnectTimeout).Post(url).Query(req).End()
-	er = handleErrorResponse(resp, errs)
+	resp, body, errs := n.request(gorequest.POST, path.Join(Prefix, n.Option.Version, InstancePath), req)
+	er = handleErrorResponse(resp, body, errs)
 	if er != nil {
 		return nil, er
 	}
 	return &types.Result{
-		Success: body == "ok",
+		Success: string(body) == "ok",
 	}, nil
 }
 
 func (n *namingHttpClient) DeRegisterServiceInstance(instance *types.ServiceInstance) (*types.Result, error) {
-	logrus.Infof("DeRegister service instance:%s", util.ToJSONString(instance))
-	url := api.SelectOne(n.LB) + path.Join(Prefix, n.Option.Version, InstancePath)
+	n.log.Debug("deregister service instance", instanceFields(instance)...)
 	req, er := query.Marshal(instance)
 	if er != nil {
 		return nil, er
 	}
-	resp, body, errs := http.NewNamingHttp().Timeout(DefaultConnectTimeout).Delete(url).Query(req).End()
-	er = handleErrorResponse(resp, errs)
+	resp, body, errs := n.request(gorequest.DELETE, path.Join(Prefix, n.Option.Version, InstancePath), req)
+	er = handleErrorResponse(resp, body, errs)
 	if er != nil {
 		return nil, er
 	}
 	return &types.Result{
-		Success: body == "ok",
+		Success: string(body) == "ok",
 	}, nil
 }
 
 func (n *namingHttpClient) UpdateServiceInstance(instance *types.ServiceInstance) (*types.Result, error) {
-	logrus.Infof("update service instance:%s", util.ToJSONString(instance))
-	url := api.SelectOne(n.LB) + path.Join(Prefix, n.Option.Version, InstancePath)
+	n.log.Debug("update service instance", instanceFields(instance)...)
 	req, er := query.Marshal(instance)
 	if er != nil {
 		return nil, er
 	}
-	resp, body, errs := http.NewNamingHttp().Timeout(DefaultConnectTimeout).Put(url).Query(req).End()
-	er = handleErrorResponse(resp, errs)
+	resp, body, errs := n.request(gorequest.PUT, path.Join(Prefix, n.Option.Version, InstancePath), req)
+	er = handleErrorResponse(resp, body, errs)
 	if er != nil {
 		return nil, er
 	}
 	return &types.Result{
-		Success: body == "ok",
+		Success: string(body) == "ok",
 	}, nil
 }
 
 func (n *namingHttpClient) GetCatalogServices(option *types.ServiceListOption) ([]*types.CatalogServiceDetail, error) {
 	var result []*types.CatalogServiceDetail
-	url := api.SelectOne(n.LB) + path.Join(Prefix, n.Option.Version, CatalogServicesPath)
 	req, er := query.Marshal(option)
 	if er != nil {
 		return nil, er
 	}
-	resp, _, errs := http.NewNamingHttp().Timeout(DefaultConnectTimeout).Get(url).Query(req).EndStruct(&result)
-	er = handleErrorResponse(resp, errs)
+	resp, body, errs := n.request(gorequest.GET, path.Join(Prefix, n.Option.Version, CatalogServicesPath), req)
+	er = handleErrorResponse(resp, body, errs)
 	if er != nil {
 		return nil, er
 	}
+	er = json.Unmarshal(body, &result)
 	return result, er
 }
 
 func (n *namingHttpClient) GetServiceInstanceDetail(instance *types.ServiceInstance) (*types.InstanceDetail, error) {
-	url := api.SelectOne(n.LB) + path.Join(Prefix, n.Option.Version, InstancePath)
-	resp, body, errs := http.NewNamingHttp().Timeout(DefaultConnectTimeout).Get(url).EndBytes()
-	er := handleErrorResponse(resp, errs)
+	resp, body, errs := n.request(gorequest.GET, path.Join(Prefix, n.Option.Version, InstancePath), "")
+	er := handleErrorResponse(resp, body, errs)
 	if er != nil {
 		return nil, er
 	}
@@ -251,13 +268,12 @@ func (n *namingHttpClient) GetServiceInstanceDetail(instance *types.ServiceInsta
 }
 
 func (n *namingHttpClient) ListServiceInstance(option *types.ServiceInstanceListOption) (*types.ServiceInstanceListResult, error) {
-	url := api.SelectOne(n.LB) + path.Join(Prefix, n.Option.Version, InstanceListPath)
 	req, er := query.Marshal(option)
 	if er != nil {
 		return nil, er
 	}
-	resp, body, errs := http.NewNamingHttp().Timeout(DefaultConnectTimeout).Get(url).Query(req).EndBytes()
-	er = handleErrorResponse(resp, errs)
+	resp, body, errs := n.request(gorequest.GET, path.Join(Prefix, n.Option.Version, InstanceListPath), req)
+	er = handleErrorResponse(resp, body, errs)
 	if er != nil {
 		return nil, er
 	}
@@ -267,18 +283,17 @@ func (n *namingHttpClient) ListServiceInstance(option *types.ServiceInstanceList
 }
 
 func (n *namingHttpClient) HeartBeat(beat *types.HeartBeat) (*types.HeartBeatResult, error) {
-	url := api.SelectOne(n.LB) + path.Join(Prefix, n.Option.Version, InstanceHeartBeatPath)
 	req, er := query.Marshal(beat)
 	if er != nil {
 		return nil, er
 	}
-	resp, body, errs := http.NewNamingHttp().Timeout(DefaultConnectTimeout).Put(url).Query(req).End()
-	er = handleErrorResponse(resp, errs)
+	resp, body, errs := n.request(gorequest.PUT, path.Join(Prefix, n.Option.Version, InstanceHeartBeatPath), req)
+	er = handleErrorResponse(resp, body, errs)
 	if er != nil {
 		return nil, er
 	}
 	var r types.HeartBeatResult
-	er = json.Unmarshal([]byte(body), &r)
+	er = json.Unmarshal(body, &r)
 	if er != nil {
 		return nil, er
 	}
@@ -286,64 +301,60 @@ func (n *namingHttpClient) HeartBeat(beat *types.HeartBeat) (*types.HeartBeatRes
 }
 
 func (n *namingHttpClient) CreateService(service *types.Service) (*types.Result, error) {
-	logrus.Infof("create service:%s", util.ToJSONString(service))
-	url := api.SelectOne(n.LB) + path.Join(Prefix, n.Option.Version, ServicePath)
+	n.log.Debug("create service", serviceFields(service)...)
 	req, er := query.Marshal(service)
 	if er != nil {
 		return nil, er
 	}
-	resp, body, errs := http.NewNamingHttp().Timeout(DefaultConnectTimeout).Post(url).Query(req).End()
-	er = handleErrorResponse(resp, errs)
+	resp, body, errs := n.request(gorequest.POST, path.Join(Prefix, n.Option.Version, ServicePath), req)
+	er = handleErrorResponse(resp, body, errs)
 	if er != nil {
 		return nil, er
 	}
 	return &types.Result{
-		Success: body == "ok",
+		Success: string(body) == "ok",
 	}, nil
 }
 
 func (n *namingHttpClient) DeleteService(service *types.Service) (*types.Result, error) {
-	logrus.Infof("delete service:%s", util.ToJSONString(service))
-	url := api.SelectOne(n.LB) + path.Join(Prefix, n.Option.Version, ServicePath)
+	n.log.Debug("delete service", serviceFields(service)...)
 	req, er := query.Marshal(service)
 	if er != nil {
 		return nil, er
 	}
-	resp, body, errs := http.NewNamingHttp().Timeout(DefaultConnectTimeout).Delete(url).Query(req).End()
-	er = handleErrorResponse(resp, errs)
+	resp, body, errs := n.request(gorequest.DELETE, path.Join(Prefix, n.Option.Version, ServicePath), req)
+	er = handleErrorResponse(resp, body, errs)
 	if er != nil {
 		return nil, er
 	}
 	return &types.Result{
-		Success: body == "ok",
+		Success: string(body) == "ok",
 	}, nil
 }
 
 func (n *namingHttpClient) UpdateService(service *types.Service) (*types.Result, error) {
-	logrus.Infof("delete service:%s", util.ToJSONString(service))
-	url := api.SelectOne(n.LB) + path.Join(Prefix, n.Option.Version, ServicePath)
+	n.log.Debug("update service", serviceFields(service)...)
 	req, er := query.Marshal(service)
 	if er != nil {
 		return nil, er
 	}
-	resp, body, errs := http.NewNamingHttp().Timeout(DefaultConnectTimeout).Put(url).Query(req).End()
-	er = handleErrorResponse(resp, errs)
+	resp, body, errs := n.request(gorequest.PUT, path.Join(Prefix, n.Option.Version, ServicePath), req)
+	er = handleErrorResponse(resp, body, errs)
 	if er != nil {
 		return nil, er
 	}
 	return &types.Result{
-		Success: body == "ok",
+		Success: string(body) == "ok",
 	}, nil
 }
 
 func (n *namingHttpClient) GetService(service *types.Service) (*types.ServiceDetail, error) {
-	url := api.SelectOne(n.LB) + path.Join(Prefix, n.Option.Version, ServicePath)
 	req, er := query.Marshal(service)
 	if er != nil {
 		return nil, er
 	}
-	resp, body, errs := http.NewNamingHttp().Timeout(DefaultConnectTimeout).Get(url).Query(req).EndBytes()
-	er = handleErrorResponse(resp, errs)
+	resp, body, errs := n.request(gorequest.GET, path.Join(Prefix, n.Option.Version, ServicePath), req)
+	er = handleErrorResponse(resp, body, errs)
 	if er != nil {
 		return nil, er
 	}
@@ -353,13 +364,12 @@ func (n *namingHttpClient) GetService(service *types.Service) (*types.ServiceDet
 }
 
 func (n *namingHttpClient) ListService(option *types.ServiceListOption) (*types.ServiceListResult, error) {
-	url := api.SelectOne(n.LB) + path.Join(Prefix, n.Option.Version, ServiceListPath)
 	req, er := query.Marshal(option)
 	if er != nil {
 		return nil, er
 	}
-	resp, body, errs := http.NewNamingHttp().Timeout(DefaultConnectTimeout).Get(url).Query(req).EndBytes()
-	er = handleErrorResponse(resp, errs)
+	resp, body, errs := n.request(gorequest.GET, path.Join(Prefix, n.Option.Version, ServiceListPath), req)
+	er = handleErrorResponse(resp, body, errs)
 	if er != nil {
 		return nil, er
 	}
@@ -369,13 +379,12 @@ func (n *namingHttpClient) ListService(option *types.ServiceListOption) (*types.
 }
 
 func (n *namingHttpClient) PatchCluster(cluster *types.Cluster) (*types.Result, error) {
-	url := api.SelectOne(n.LB) + path.Join(Prefix, n.Option.Version, ClusterPath)
 	req, er := query.Marshal(cluster)
 	if er != nil {
 		return nil, er
 	}
-	resp, body, errs := http.NewNamingHttp().Timeout(DefaultConnectTimeout).Put(url).Query(req).EndBytes()
-	er = handleErrorResponse(resp, errs)
+	resp, body, errs := n.request(gorequest.PUT, path.Join(Prefix, n.Option.Version, ClusterPath), req)
+	er = handleErrorResponse(resp, body, errs)
 	if er != nil {
 		return nil, er
 	}
@@ -384,9 +393,8 @@ func (n *namingHttpClient) PatchCluster(cluster *types.Cluster) (*types.Result,
 }
 
 func (n *namingHttpClient) GetSwitches() (*types.SwitchesDetail, error) {
-	url := api.SelectOne(n.LB) + path.Join(Prefix, n.Option.Version, SwitchesPath)
-	resp, body, errs := http.NewNamingHttp().Timeout(DefaultConnectTimeout).Get(url).EndBytes()
-	er := handleErrorResponse(resp, errs)
+	resp, body, errs := n.request(gorequest.GET, path.Join(Prefix, n.Option.Version, SwitchesPath), "")
+	er := handleErrorResponse(resp, body, errs)
 	if er != nil {
 		return nil, er
 	}
@@ -396,9 +404,8 @@ func (n *namingHttpClient) GetSwitches() (*types.SwitchesDetail, error) {
 }
 
 func (n *namingHttpClient) GetMetrics() (*types.Metrics, error) {
-	url := api.SelectOne(n.LB) + path.Join(Prefix, n.Option.Version, MetricsPath)
-	resp, body, errs := http.NewNamingHttp().Timeout(DefaultConnectTimeout).Get(url).EndBytes()
-	er := handleErrorResponse(resp, errs)
+	resp, body, errs := n.request(gorequest.GET, path.Join(Prefix, n.Option.Version, MetricsPath), "")
+	er := handleErrorResponse(resp, body, errs)
 	if er != nil {
 		return nil, er
 	}
@@ -408,9 +415,8 @@ func (n *namingHttpClient) GetMetrics() (*types.Metrics, error) {
 }
 
 func (n *namingHttpClient) GetLeader() (*types.NacosLeader, error) {
-	url := api.SelectOne(n.LB) + path.Join(Prefix, n.Option.Version, LeaderPath)
-	resp, body, errs := http.NewNamingHttp().Timeout(DefaultConnectTimeout).Get(url).EndBytes()
-	er := handleErrorResponse(resp, errs)
+	resp, body, errs := n.request(gorequest.GET, path.Join(Prefix, n.Option.Version, LeaderPath), "")
+	er := handleErrorResponse(resp, body, errs)
 	if er != nil {
 		return nil, er
 	}
@@ -420,14 +426,13 @@ func (n *namingHttpClient) GetLeader() (*types.NacosLeader, error) {
 }
 
 func (n *namingHttpClient) UpdateSwitches(request *types.UpdateSwitchRequest) (*types.Result, error) {
-	logrus.Infof("update switches:%s", util.ToJSONString(request))
-	url := api.SelectOne(n.LB) + path.Join(Prefix, n.Option.Version, SwitchesPath)
+	n.log.Debug("update switches", logger.F("entry", request.Entry), logger.F("value", request.Value))
 	req, er := query.Marshal(request)
 	if er != nil {
 		return nil, er
 	}
-	resp, body, errs := http.NewNamingHttp().Timeout(DefaultConnectTimeout).Put(url).Query(req).EndBytes()
-	er = handleErrorResponse(resp, errs)
+	resp, body, errs := n.request(gorequest.PUT, path.Join(Prefix, n.Option.Version, SwitchesPath), req)
+	er = handleErrorResponse(resp, body, errs)
 	if er != nil {
 		return nil, er
 	}
@@ -436,13 +441,12 @@ func (n *namingHttpClient) UpdateSwitches(request *types.UpdateSwitchRequest) (*
 }
 
 func (n *namingHttpClient) UpdateServiceInstanceHealthy(request *types.UpdateServiceInstanceHealthyRequest) (*types.Result, error) {
-	url := api.SelectOne(n.LB) + path.Join(Prefix, n.Option.Version, InstanceHealthPath)
 	req, er := query.Marshal(request)
 	if er != nil {
 		return nil, er
 	}
-	resp, body, errs := http.NewNamingHttp().Timeout(DefaultConnectTimeout).Put(url).Query(req).EndBytes()
-	er = handleErrorResponse(resp, errs)
+	resp, body, errs := n.request(gorequest.PUT, path.Join(Prefix, n.Option.Version, InstanceHealthPath), req)
+	er = handleErrorResponse(resp, body, errs)
 	if er != nil {
 		return nil, er
 	}
@@ -450,19 +454,30 @@ func (n *namingHttpClient) UpdateServiceInstanceHealthy(request *types.UpdateSer
 	return &types.Result{Success: b}, er
 }
 
-func handleErrorResponse(resp gorequest.Response, errs []error) error {
-	if resp != nil && errs != nil {
-		data, er := ioutil.ReadAll(resp.Body)
-		if er != nil {
-			return errors.New("go-nacos system error,statusCode:5xx")
-		}
-		return err.NewHttpClientError(string(data), errs...)
-	}
-	if resp == nil {
-		return err.NewHttpClientError("valid response")
-	} else if resp.StatusCode == 200 {
+func handleErrorResponse(resp gorequest.Response, body []byte, errs []error) error {
+	if resp != nil && len(errs) == 0 && resp.StatusCode == 200 {
 		return nil
-	} else {
-		return err.NewHttpClientError("response status code valid,code:"+strconv.Itoa(resp.StatusCode), nil)
+	}
+	return err.NewNacosError(resp, body, errs...)
+}
+
+//instanceFields 实例的日志字段,元数据中的敏感字段由logger脱敏
+func instanceFields(instance *types.ServiceInstance) []logger.Field {
+	return []logger.Field{
+		logger.F("namespace", instance.NamespaceID),
+		logger.F("group", instance.GroupName),
+		logger.F("service", instance.ServiceName),
+		logger.F("cluster", instance.ClusterName),
+		logger.F("ip", instance.IP),
+		logger.F("port", instance.Port),
+		logger.F("metadata", instance.Metadata),
+	}
+}
+
+func serviceFields(service *types.Service) []logger.Field {
+	return []logger.Field{
+		logger.F("namespace", service.NamespaceId),
+		logger.F("group", service.GroupName),
+		logger.F("service", service.ServiceName),
 	}
 }
@@ -118,10 +118,10 @@ func TestNamingHttpClient_RegisterServiceInstance(t *testing.T) {
 		ServiceName: "app1",
 		IP:          "10.10.10.15",
 		Port:        8080,
-		Metadata: util.MapToString(map[string]string{
+		Metadata: map[string]string{
 			"name": "tianqing.wang",
 			"age":  "30",
-		}),
+		},
 		Weight:    1.0,
 		Healthy:   true,
 		Enable:    true,
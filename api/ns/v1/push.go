@@ -1,37 +1,114 @@
 package v1
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
+	"github.com/celeskyking/go-nacos/api"
+	"github.com/celeskyking/go-nacos/pkg/logger"
+	"github.com/celeskyking/go-nacos/pkg/stats"
 	"github.com/celeskyking/go-nacos/pkg/util"
 	"github.com/celeskyking/go-nacos/types"
-	"github.com/sirupsen/logrus"
+	"github.com/pkg/errors"
+	"io/ioutil"
 	"math/rand"
 	"net"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-var (
-	UDPPort = 0
-)
-
 var (
 	GzipMagicCode = []byte("\x1F\x8B")
 )
 
+//NewPushReceiver 使用默认配置创建推送的接收器
 func NewPushReceiver() *PushReceiver {
+	return NewPushReceiverWithOptions(nil)
+}
+
+//NewPushReceiverWithOptions 创建推送的接收器,options为nil的时候使用api.DefaultPushOptions
+func NewPushReceiverWithOptions(options *api.PushOptions) *PushReceiver {
+	o := api.DefaultPushOptions()
+	if options != nil {
+		c := *options
+		o = &c
+		if o.BindAddress == "" {
+			o.BindAddress = "0.0.0.0"
+		}
+		if o.PortRangeStart <= 0 || o.PortRangeEnd <= o.PortRangeStart {
+			o.PortRangeStart = api.DefaultPushPortRangeStart
+			o.PortRangeEnd = api.DefaultPushPortRangeEnd
+		}
+	}
+	var validator ChecksumValidator = NonEmptyChecksum
+	if o.DisableChecksum {
+		validator = nil
+	}
 	return &PushReceiver{
-		QuitC:   make(chan struct{}, 0),
-		NotifyC: make(chan *PushMessage, 100),
+		options:           o,
+		checksumValidator: validator,
+		QuitC:             make(chan struct{}, 0),
+		NotifyC:           make(chan *PushMessage, 100),
+		subscribers:       make(map[string][]chan *PushMessage),
+		log:               logger.With(nil),
+		rec:               stats.With(nil),
 	}
 }
 
+//PushBufferSize 每个订阅者的推送队列长度
+const PushBufferSize = 16
+
 type PushReceiver struct {
-	Port int
+	options *api.PushOptions
 
-	QuitC chan struct{}
+	port int32
+
+	conn *net.UDPConn
 
+	lock sync.Mutex
+
+	QuitC chan struct{}
+	//所有的推送,没有被读取的时候丢弃,订阅某个服务的推送使用Subscribe
 	NotifyC chan *PushMessage
+	//按照服务和集群分发推送,key为 group@@service@@clusters
+	subscribers map[string][]chan *PushMessage
+
+	stopOnce sync.Once
+
+	checksumValidator ChecksumValidator
+
+	stats pushCounters
+
+	log logger.Logger
+
+	rec stats.Recorder
+}
+
+//ChecksumValidator 校验推送的服务列表,返回false的时候丢弃推送并且不回复ack,服务端会重新推送
+type ChecksumValidator func(msg *PushMessage) bool
+
+//PushStats 推送的统计数据
+type PushStats struct {
+	//收到的推送
+	Received uint64
+	//回复的ack
+	Acked uint64
+	//丢弃的推送,例如校验失败或者通知队列已满
+	Dropped uint64
+	//解析失败的推送
+	ParseFailed uint64
+}
+
+type pushCounters struct {
+	received uint64
+
+	acked uint64
+
+	dropped uint64
+
+	parseFailed uint64
 }
 
 type PushData struct {
@@ -42,25 +119,81 @@ type PushData struct {
 	LastRefTime int64 `json:"lastRefTime"`
 }
 
+//SetChecksumValidator 替换推送的校验,默认使用NonEmptyChecksum,为nil的时候不校验,需要在Start之前调用
+func (u *PushReceiver) SetChecksumValidator(validator ChecksumValidator) {
+	u.checksumValidator = validator
+}
+
+//SetLogger 设置输出日志使用的Logger,为nil的时候使用全局的Logger,需要在Bind之前调用
+func (u *PushReceiver) SetLogger(l logger.Logger) {
+	u.log = logger.With(l)
+}
+
+//SetRecorder 设置记录推送事件使用的Recorder,为nil的时候使用全局的Recorder,需要在Bind之前调用
+func (u *PushReceiver) SetRecorder(r stats.Recorder) {
+	u.rec = stats.With(r)
+}
+
+//Bind 绑定udp端口,固定端口的时候只尝试一次,否则按照随机的顺序尝试端口范围内的每个端口
+func (u *PushReceiver) Bind() error {
+	if u.options.Disabled {
+		return errors.New("push receiver disabled")
+	}
+	ports := []int{u.options.Port}
+	if u.options.Port == 0 {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		ports = r.Perm(u.options.PortRangeEnd - u.options.PortRangeStart)
+		for i := range ports {
+			ports[i] += u.options.PortRangeStart
+		}
+	}
+	var last error
+	for _, port := range ports {
+		conn, er := u.listen(port)
+		if er != nil {
+			last = er
+			continue
+		}
+		u.lock.Lock()
+		u.conn = conn
+		u.lock.Unlock()
+		atomic.StoreInt32(&u.port, int32(port))
+		u.log.Info("udp server started", logger.F("addr", conn.LocalAddr().String()))
+		return nil
+	}
+	return errors.Wrapf(last, "bind push receiver on %s failed", u.options.BindAddress)
+}
+
+//Start 绑定端口并且开始接收推送,绑定失败的时候退避重试直到Stop
 func (u *PushReceiver) Start() {
-	var conn *net.UDPConn
+	if u.options.Disabled {
+		return
+	}
 	retries := 0
 	max := 60
-	for {
-		r := rand.New(rand.NewSource(time.Now().UnixNano()))
-		port := r.Intn(1000) + 45000
-		u.Port = port
-		c, ok := u.Listen()
-		if !ok {
-			retries = retries + 1
-			time.Sleep(time.Duration(util.Min(retries, max)) * time.Second)
-			continue
-		} else {
-			conn = c
-			UDPPort = port
-			logrus.Info("connect to nacos success")
+	for u.GetPort() == 0 {
+		er := u.Bind()
+		if er == nil {
 			break
 		}
+		u.log.Error("listen to nacos push service failed", logger.Err(er))
+		retries = retries + 1
+		select {
+		case <-u.QuitC:
+			return
+		case <-time.After(time.Duration(util.Min(retries, max)) * time.Second):
+		}
+	}
+	u.Serve()
+}
+
+//Serve 在Bind成功之后接收推送,直到Stop
+func (u *PushReceiver) Serve() {
+	u.lock.Lock()
+	conn := u.conn
+	u.lock.Unlock()
+	if conn == nil {
+		return
 	}
 	defer func() {
 		_ = conn.Close()
@@ -75,39 +208,124 @@ func (u *PushReceiver) Start() {
 	}
 }
 
+//Stop 停止接收推送并且关闭端口
+func (u *PushReceiver) Stop() {
+	u.stopOnce.Do(func() {
+		close(u.QuitC)
+		u.lock.Lock()
+		if u.conn != nil {
+			_ = u.conn.Close()
+		}
+		u.lock.Unlock()
+	})
+}
+
+//GetPort 返回绑定的端口,没有绑定成功的时候返回0,订阅的时候服务端不会推送
+func (u *PushReceiver) GetPort() int {
+	return int(atomic.LoadInt32(&u.port))
+}
+
+//Stats 返回推送的统计数据
+func (u *PushReceiver) Stats() PushStats {
+	return PushStats{
+		Received:    atomic.LoadUint64(&u.stats.received),
+		Acked:       atomic.LoadUint64(&u.stats.acked),
+		Dropped:     atomic.LoadUint64(&u.stats.dropped),
+		ParseFailed: atomic.LoadUint64(&u.stats.parseFailed),
+	}
+}
+
+//GetNotifyChannel 返回所有推送的队列,多个读取者的时候每个推送只会被其中一个读取,订阅某个服务的推送使用Subscribe
 func (u *PushReceiver) GetNotifyChannel() <-chan *PushMessage {
 	return u.NotifyC
 }
 
-func (u *PushReceiver) Listen() (*net.UDPConn, bool) {
-	addr, err := net.ResolveUDPAddr("udp", "0.0.0.0:"+strconv.Itoa(u.Port))
-	if err != nil {
-		logrus.Errorf("listen to nacos push service failed:%+v", err)
-		return nil, false
+//Subscribe 订阅服务的推送,name为 group@@service,clusters和订阅实例列表的时候的clusters相同。
+//同一个服务的每个订阅者都会收到推送,返回的函数用来取消订阅
+func (u *PushReceiver) Subscribe(name, clusters string) (<-chan *PushMessage, func()) {
+	key := pushKey(name, clusters)
+	c := make(chan *PushMessage, PushBufferSize)
+	u.lock.Lock()
+	u.subscribers[key] = append(u.subscribers[key], c)
+	u.lock.Unlock()
+	var once sync.Once
+	return c, func() {
+		once.Do(func() {
+			u.lock.Lock()
+			defer u.lock.Unlock()
+			subscribers := u.subscribers[key]
+			for i, sub := range subscribers {
+				if sub == c {
+					subscribers = append(subscribers[:i:i], subscribers[i+1:]...)
+					break
+				}
+			}
+			if len(subscribers) == 0 {
+				delete(u.subscribers, key)
+			} else {
+				u.subscribers[key] = subscribers
+			}
+		})
+	}
+}
+
+func pushKey(name, clusters string) string {
+	return name + "@@" + clusters
+}
+
+//dispatch 把推送发送给服务的所有订阅者,有订阅者的队列已满的时候返回false
+func (u *PushReceiver) dispatch(msg *PushMessage) bool {
+	select {
+	case u.NotifyC <- msg:
+	default:
 	}
-	logrus.Infof("udp server started, addr:%s", addr.String())
-	connection, err := net.ListenUDP("udp", addr)
-	if err != nil {
-		logrus.Error("listen error", err)
-		return nil, false
+	u.lock.Lock()
+	subscribers := u.subscribers[pushKey(msg.Name, msg.Clusters)]
+	u.lock.Unlock()
+	delivered := true
+	for _, c := range subscribers {
+		select {
+		case c <- msg:
+		default:
+			delivered = false
+		}
+	}
+	return delivered
+}
+
+func (u *PushReceiver) listen(port int) (*net.UDPConn, error) {
+	addr, er := net.ResolveUDPAddr("udp", net.JoinHostPort(u.options.BindAddress, strconv.Itoa(port)))
+	if er != nil {
+		return nil, er
 	}
-	return connection, true
+	return net.ListenUDP("udp", addr)
 }
 
 func (u *PushReceiver) consume(conn *net.UDPConn) {
 	data := make([]byte, 64*1024)
-	n, remoteAddr, err := conn.ReadFromUDP(data)
-	if err != nil {
-		logrus.Error("failed to read UDP msg because of ", err)
+	n, remoteAddr, er := conn.ReadFromUDP(data)
+	if er != nil {
+		select {
+		case <-u.QuitC:
+		default:
+			u.log.Error("failed to read UDP msg", logger.Err(er))
+		}
+		return
+	}
+	atomic.AddUint64(&u.stats.received, 1)
+	u.rec.PushReceived()
+	u.log.Debug("receive push message", logger.F("from", remoteAddr.String()), logger.F("bytes", n))
+	j, er := decompress(data[:n])
+	if er != nil {
+		atomic.AddUint64(&u.stats.parseFailed, 1)
+		u.log.Error("failed to decompress push data", logger.F("from", remoteAddr.String()), logger.Err(er))
 		return
 	}
-	logrus.Infof("receive push message from %s\n", remoteAddr.String())
 	var pushData PushData
-	j := data[:n]
-	logrus.Infof("push message:%s", string(j))
-	er := json.Unmarshal([]byte(j), &pushData)
+	er = json.Unmarshal(j, &pushData)
 	if er != nil {
-		logrus.Error("failed to process push data", er)
+		atomic.AddUint64(&u.stats.parseFailed, 1)
+		u.log.Error("failed to process push data", logger.Err(er))
 		return
 	}
 
@@ -116,17 +334,24 @@ func (u *PushReceiver) consume(conn *net.UDPConn) {
 		var pushMessage PushMessage
 		er = json.Unmarshal([]byte(pushData.Data), &pushMessage)
 		if er != nil {
-			logrus.Errorf("failed to unmarshal json string:%+v", er)
+			atomic.AddUint64(&u.stats.parseFailed, 1)
+			u.log.Error("failed to unmarshal push message", logger.F("type", pushData.Type), logger.Err(er))
 			return
 		}
-		if len(pushMessage.Hosts) == 0 {
-			logrus.Errorf("get empty ip list, ignore it, dom:%s", pushMessage.Dom)
+		if u.checksumValidator != nil && !u.checksumValidator(&pushMessage) {
+			atomic.AddUint64(&u.stats.dropped, 1)
+			u.log.Error("push message checksum not valid", logger.F("dom", pushMessage.Dom), logger.F("checksum", pushMessage.Checksum))
+			return
+		}
+		//空列表同样通知给订阅者,是否接受由订阅者的保护策略决定
+		if !u.dispatch(&pushMessage) {
+			//订阅者的队列已满的时候不回复ack,服务端会重新推送,同时还有轮询兜底
+			atomic.AddUint64(&u.stats.dropped, 1)
+			u.log.Warn("push notify channel is full, drop message", logger.F("dom", pushMessage.Dom))
 			return
 		}
-		u.NotifyC <- &pushMessage
 		ack["type"] = "push-ack"
 		ack["data"] = ""
-		//todo
 	} else if pushData.Type == "dump" {
 		ack["type"] = "dump-ack"
 		ack["data"] = ""
@@ -137,17 +362,35 @@ func (u *PushReceiver) consume(conn *net.UDPConn) {
 	ack["lastRefTime"] = strconv.FormatInt(pushData.LastRefTime, 10)
 	ackData, er := json.Marshal(ack)
 	if er != nil {
-		logrus.Error("push message encode ack failed", er)
+		u.log.Error("push message encode ack failed", logger.Err(er))
 		return
 	}
 	_, er = conn.WriteToUDP(ackData, remoteAddr)
 	if er != nil {
-		logrus.Error("push message ack failed", er)
+		u.log.Error("push message ack failed", logger.F("to", remoteAddr.String()), logger.Err(er))
+		return
+	}
+	atomic.AddUint64(&u.stats.acked, 1)
+	u.rec.PushAcked()
+}
+
+//decompress 服务端的推送超过一定大小的时候会使用gzip压缩
+func decompress(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, GzipMagicCode) {
+		return data, nil
+	}
+	r, er := gzip.NewReader(bytes.NewReader(data))
+	if er != nil {
+		return nil, er
 	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
 }
 
-func GetUDPPort() int {
-	return UDPPort
+//NonEmptyChecksum 丢弃没有checksum的推送。nacos 1.x的服务端推送的checksum是服务的内部状态的md5再拼接时间戳,
+//客户端无法重新计算,需要更严格的校验的时候可以自定义ChecksumValidator
+func NonEmptyChecksum(msg *PushMessage) bool {
+	return msg.Checksum != ""
 }
 
 type Ack struct {
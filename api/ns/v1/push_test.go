@@ -0,0 +1,157 @@
+package v1
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"github.com/celeskyking/go-nacos/api"
+	"github.com/celeskyking/go-nacos/types"
+	"net"
+	"testing"
+	"time"
+)
+
+func newTestReceiver(t *testing.T) (*PushReceiver, *net.UDPConn) {
+	r := NewPushReceiverWithOptions(&api.PushOptions{BindAddress: "127.0.0.1", PortRangeStart: 47000, PortRangeEnd: 48000})
+	if er := r.Bind(); er != nil {
+		t.Fatalf("bind error:%+v", er)
+	}
+	go r.Serve()
+	conn, er := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: r.GetPort()})
+	if er != nil {
+		t.Fatal(er)
+	}
+	return r, conn
+}
+
+func pushPacket(t *testing.T, msg *PushMessage, compress bool) []byte {
+	data, _ := json.Marshal(msg)
+	b, _ := json.Marshal(&PushData{Type: "dom", Data: string(data), LastRefTime: msg.LastRefTime})
+	if !compress {
+		return b
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, _ = w.Write(b)
+	_ = w.Close()
+	return buf.Bytes()
+}
+
+func readAck(t *testing.T, conn *net.UDPConn) *Ack {
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	data := make([]byte, 1024)
+	n, er := conn.Read(data)
+	if er != nil {
+		t.Fatalf("read ack error:%+v", er)
+	}
+	var ack Ack
+	_ = json.Unmarshal(data[:n], &ack)
+	return &ack
+}
+
+func TestPushReceiver_Gzip(t *testing.T) {
+	r, conn := newTestReceiver(t)
+	defer r.Stop()
+	defer conn.Close()
+	msg := &PushMessage{
+		Name:        "dev@@demo",
+		LastRefTime: 100,
+		Checksum:    "abc",
+		Hosts:       []*types.Host{{IP: "10.0.0.1", Port: 8080}},
+	}
+	_, _ = conn.Write(pushPacket(t, msg, true))
+	if ack := readAck(t, conn); ack.Type != "push-ack" || ack.LastRefTime != "100" {
+		t.Fatalf("unexpected ack:%+v", ack)
+	}
+	select {
+	case m := <-r.GetNotifyChannel():
+		if m.Name != "dev@@demo" || len(m.Hosts) != 1 {
+			t.Fatalf("unexpected message:%+v", m)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expect push message notified")
+	}
+	_, _ = conn.Write([]byte("not json"))
+	msg.Checksum = ""
+	_, _ = conn.Write(pushPacket(t, msg, false))
+	time.Sleep(100 * time.Millisecond)
+	stats := r.Stats()
+	if stats.Received != 3 || stats.Acked != 1 || stats.ParseFailed != 1 || stats.Dropped != 1 {
+		t.Fatalf("unexpected stats:%+v", stats)
+	}
+}
+
+func TestPushReceiver_DisableChecksum(t *testing.T) {
+	r := NewPushReceiverWithOptions(&api.PushOptions{DisableChecksum: true})
+	if r.checksumValidator != nil {
+		t.Fatal("expect checksum validation disabled")
+	}
+	if NewPushReceiver().checksumValidator == nil {
+		t.Fatal("expect checksum validated by default")
+	}
+}
+
+func TestPushReceiver_Ports(t *testing.T) {
+	r1 := NewPushReceiverWithOptions(&api.PushOptions{BindAddress: "127.0.0.1", PortRangeStart: 48000, PortRangeEnd: 48001})
+	if er := r1.Bind(); er != nil {
+		t.Fatalf("bind error:%+v", er)
+	}
+	defer r1.Stop()
+	if r1.GetPort() != 48000 {
+		t.Fatalf("expect port 48000, got:%d", r1.GetPort())
+	}
+	r2 := NewPushReceiverWithOptions(&api.PushOptions{BindAddress: "127.0.0.1", Port: 48000})
+	if er := r2.Bind(); er == nil {
+		t.Fatalf("expect bind failed on used port")
+	}
+	r3 := NewPushReceiverWithOptions(&api.PushOptions{BindAddress: "127.0.0.1", PortRangeStart: 48000, PortRangeEnd: 48010})
+	if er := r3.Bind(); er != nil {
+		t.Fatalf("bind error:%+v", er)
+	}
+	defer r3.Stop()
+	if r3.GetPort() == 48000 || r3.GetPort() == 0 {
+		t.Fatalf("expect another port in range, got:%d", r3.GetPort())
+	}
+}
+
+func TestPushReceiver_Subscribe(t *testing.T) {
+	r, conn := newTestReceiver(t)
+	defer r.Stop()
+	defer conn.Close()
+	a1, cancelA1 := r.Subscribe("dev@@a", "")
+	a2, cancelA2 := r.Subscribe("dev@@a", "")
+	defer cancelA2()
+	b, cancelB := r.Subscribe("dev@@b", "")
+	defer cancelB()
+	_, _ = conn.Write(pushPacket(t, &PushMessage{Name: "dev@@a", LastRefTime: 1, Checksum: "abc"}, false))
+	if ack := readAck(t, conn); ack.Type != "push-ack" {
+		t.Fatalf("unexpected ack:%+v", ack)
+	}
+	for _, c := range []<-chan *PushMessage{a1, a2} {
+		select {
+		case m := <-c:
+			if m.Name != "dev@@a" {
+				t.Fatalf("unexpected message:%+v", m)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expect every subscriber of the service notified")
+		}
+	}
+	select {
+	case m := <-b:
+		t.Fatalf("expect other service not notified, got:%+v", m)
+	default:
+	}
+
+	cancelA1()
+	cancelA1()
+	_, _ = conn.Write(pushPacket(t, &PushMessage{Name: "dev@@a", LastRefTime: 2, Checksum: "abc"}, false))
+	readAck(t, conn)
+	select {
+	case <-a1:
+		t.Fatal("expect no message after cancel")
+	case <-a2:
+	case <-time.After(time.Second):
+		t.Fatal("expect remaining subscriber notified")
+	}
+}
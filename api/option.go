@@ -1,6 +1,12 @@
 package api
 
-import "time"
+import (
+	"github.com/celeskyking/go-nacos/api/ns/endpoint"
+	"github.com/celeskyking/go-nacos/client/loadbalancer"
+	"github.com/celeskyking/go-nacos/pkg/logger"
+	"github.com/celeskyking/go-nacos/pkg/stats"
+	"time"
+)
 
 type HttpConfigOption struct {
 	//连接超时
@@ -15,6 +21,18 @@ type HttpConfigOption struct {
 	Endpoint string
 	//EndpointEnabled 功能是否启动
 	EndpointEnabled bool
+	//EndpointOptions endpoint的路径,参数,刷新间隔和缓存文件,Address为空的时候使用Endpoint
+	EndpointOptions *endpoint.Options
+	//ServerProvider 动态提供nacos server的地址列表,例如DNSProvider
+	ServerProvider ServerProvider
+	//RetryPolicy 请求失败的时候的重试策略,为空的时候使用DefaultRetryPolicy
+	RetryPolicy *RetryPolicy
+	//HealthCheck RoundRobin的时候nacos server的健康检查配置,为空的时候使用loadbalancer.DefaultHealthCheckOptions
+	HealthCheck *loadbalancer.HealthCheckOptions
+	//Logger 客户端输出日志使用的Logger,为空的时候使用全局的Logger
+	Logger logger.Logger
+	//Recorder 客户端记录请求等内部事件使用的Recorder,为空的时候使用全局的Recorder
+	Recorder stats.Recorder
 }
 
 type LBStrategy int
@@ -23,6 +41,10 @@ const (
 	Direct LBStrategy = iota
 
 	RoundRobin
+	//EWMA 选择请求延迟最低的server,延迟相近的时候按照正在进行的请求数分散
+	EWMA
+	//LeastOutstanding 选择正在进行的请求最少的server
+	LeastOutstanding
 
 	DefaultConnectTimeout = 5 * time.Second
 )
@@ -36,8 +58,52 @@ type ServerOptions struct {
 	Endpoint string
 	//EndpointEnabled 功能是否启动
 	EndpointEnabled bool
+	//endpoint的详细配置,为空的时候只使用Endpoint作为地址
+	EndpointOptions *endpoint.Options
+	//动态提供nacos server的地址列表,例如DNSProvider,Addresses为空的时候作为初始的列表
+	ServerProvider ServerProvider
 	//命名空间地址
 	NamespaceID string
+	//接收服务端推送的udp配置,为空的时候使用DefaultPushOptions
+	Push *PushOptions
+	//请求nacos server失败的时候的重试策略,为空的时候使用DefaultRetryPolicy
+	RetryPolicy *RetryPolicy
+	//nacos server的健康检查配置,为空的时候使用默认的配置
+	HealthCheck *loadbalancer.HealthCheckOptions
+	//使用该配置创建的服务输出日志使用的Logger,为空的时候使用全局的Logger,参考logger.SetLogger
+	Logger logger.Logger
+	//使用该配置创建的服务记录内部事件使用的Recorder,例如metrics.New()返回的prometheus指标,为空的时候使用全局的Recorder,参考stats.SetRecorder
+	Recorder stats.Recorder
+}
+
+const (
+	DefaultPushPortRangeStart = 45000
+
+	DefaultPushPortRangeEnd = 46000
+)
+
+//PushOptions 接收服务端推送的udp端口配置
+type PushOptions struct {
+	//关闭推送,只依靠轮询刷新服务列表
+	Disabled bool
+	//绑定的地址,默认为0.0.0.0
+	BindAddress string
+	//固定的端口,为0的时候在[PortRangeStart, PortRangeEnd)之间随机选择
+	Port int
+
+	PortRangeStart int
+
+	PortRangeEnd int
+	//关闭推送的checksum校验,默认丢弃没有checksum的推送,参考v1.NonEmptyChecksum
+	DisableChecksum bool
+}
+
+func DefaultPushOptions() *PushOptions {
+	return &PushOptions{
+		BindAddress:    "0.0.0.0",
+		PortRangeStart: DefaultPushPortRangeStart,
+		PortRangeEnd:   DefaultPushPortRangeEnd,
+	}
 }
 
 type AppConfig struct {
@@ -60,6 +126,8 @@ type ConfigOptions struct {
 	*ServerOptions
 
 	SnapshotDir string
+	//后台监听配置失败的时候回调,例如监听请求失败或者快照写入失败,为空的时候只输出日志
+	OnError func(er error)
 }
 
 type DiscoveryOptions struct {
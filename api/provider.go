@@ -0,0 +1,100 @@
+package api
+
+import (
+	"context"
+	"github.com/celeskyking/go-nacos/pkg/logger"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//DefaultDNSInterval DNSProvider重新解析域名的间隔
+const DefaultDNSInterval = 30 * time.Second
+
+//ServerProvider 动态提供nacos server的地址列表,例如endpoint和DNS
+type ServerProvider interface {
+	//Servers 同步获取一次地址列表,没有配置nacos server地址的时候作为初始的列表
+	Servers() ([]string, error)
+	//Run 持续获取地址列表,列表变化的时候发送到返回的channel,stop关闭之后关闭返回的channel
+	Run(stop <-chan struct{}) chan []string
+}
+
+//DNSProvider 定期解析域名,使用解析到的全部ip作为nacos server的地址
+type DNSProvider struct {
+	//域名
+	Host string
+	//nacos server的端口
+	Port int
+	//重新解析的间隔
+	Interval time.Duration
+	//Resolver 为空的时候使用net.DefaultResolver
+	Resolver *net.Resolver
+	//输出日志使用的Logger,为空的时候使用客户端的Logger
+	Logger logger.Logger
+}
+
+//NewDNSProvider 每DefaultDNSInterval解析一次host
+func NewDNSProvider(host string, port int) *DNSProvider {
+	return &DNSProvider{
+		Host:     host,
+		Port:     port,
+		Interval: DefaultDNSInterval,
+	}
+}
+
+//Servers 解析域名,返回排序之后的host:port列表
+func (d *DNSProvider) Servers() ([]string, error) {
+	resolver := d.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultConnectTimeout)
+	defer cancel()
+	ips, er := resolver.LookupHost(ctx, d.Host)
+	if er != nil {
+		return nil, er
+	}
+	var servers []string
+	for _, ip := range ips {
+		servers = append(servers, net.JoinHostPort(ip, strconv.Itoa(d.Port)))
+	}
+	sort.Strings(servers)
+	return servers, nil
+}
+
+//Run 立即解析一次,之后每Interval解析一次,解析失败的时候保留上一次的结果
+func (d *DNSProvider) Run(stop <-chan struct{}) chan []string {
+	interval := d.Interval
+	if interval <= 0 {
+		interval = DefaultDNSInterval
+	}
+	log := logger.With(d.Logger)
+	notify := make(chan []string, 0)
+	go func() {
+		defer close(notify)
+		var last []string
+		for {
+			servers, er := d.Servers()
+			if er != nil {
+				log.Error("resolve nacos servers failed", logger.F("host", d.Host), logger.Err(er))
+			} else if len(servers) > 0 && !reflect.DeepEqual(servers, last) {
+				select {
+				case notify <- servers:
+					last = servers
+				case <-stop:
+					return
+				}
+			}
+			timer := time.NewTimer(interval)
+			select {
+			case <-timer.C:
+			case <-stop:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+	return notify
+}
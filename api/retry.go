@@ -0,0 +1,194 @@
+package api
+
+import (
+	"context"
+	"github.com/celeskyking/go-nacos/client/loadbalancer"
+	"github.com/celeskyking/go-nacos/pkg/logger"
+	"github.com/celeskyking/go-nacos/pkg/stats"
+	"github.com/parnurzeal/gorequest"
+	"github.com/pkg/errors"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	DefaultMaxAttempts = 3
+
+	DefaultBackoff = 100 * time.Millisecond
+
+	DefaultMaxBackoff = 2 * time.Second
+)
+
+//RetryPolicy 请求nacos server的重试策略,每次重试都会选择不同的server
+type RetryPolicy struct {
+	//最多的请求次数,包括第一次请求,小于等于1的时候不重试
+	MaxAttempts int
+	//每次请求的超时时间,为0的时候使用DefaultConnectTimeout
+	PerAttemptTimeout time.Duration
+	//第一次重试之前的等待时间,之后每次翻倍
+	Backoff time.Duration
+	//最长的等待时间
+	MaxBackoff time.Duration
+	//可以重试的状态码
+	RetryableStatusCodes []int
+	//自定义是否可以重试,不为空的时候忽略RetryableStatusCodes,也不再区分请求是否幂等。resp为nil的时候表示连接失败或者超时
+	Retryable func(resp gorequest.Response, errs []error) bool
+}
+
+//DefaultRetryPolicy 默认最多请求3次,连接失败,超时以及502,503,504的时候重试。
+//POST和PUT请求(例如注册实例和发布配置)超时的时候服务端可能已经处理,只在连接失败的时候重试
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:          DefaultMaxAttempts,
+		PerAttemptTimeout:    DefaultConnectTimeout,
+		Backoff:              DefaultBackoff,
+		MaxBackoff:           DefaultMaxBackoff,
+		RetryableStatusCodes: []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+	}
+}
+
+//Attempt 一次请求,server为选择的nacos server的地址,以/结尾
+type Attempt func(server string, timeout time.Duration) (gorequest.Response, []byte, []error)
+
+//Do 按照重试策略请求,policy为nil的时候使用DefaultRetryPolicy。连接失败的server会被立即标记为可疑,
+//返回最后一次请求的结果。每次请求的耗时会记录到server上,供EWMA等负载均衡策略使用。
+//endpoint为请求的方法和路径,例如"GET nacos/v1/ns/instance/list",用来记录请求的统计数据。每次请求都会创建ctx的子span。
+//log为输出重试日志的Logger,rec为记录请求的Recorder,为nil的时候使用全局的Logger和Recorder
+//ctx结束的时候不再等待重试的间隔,返回最后一次请求的结果和ctx的错误
+func Do(ctx context.Context, lb loadbalancer.LB, policy *RetryPolicy, log logger.Logger, rec stats.Recorder, endpoint string, attempt Attempt) (gorequest.Response, []byte, []error) {
+	return do(ctx, lb, policy, log, rec, endpoint, attempt, true, idempotent(endpoint))
+}
+
+//DoLongPoll 和Do相同,但是不记录请求的耗时,长轮询的耗时由服务端的挂起时间决定,不能反映server的延迟。
+//长轮询只查询配置的变化,超时之后可以重试
+func DoLongPoll(ctx context.Context, lb loadbalancer.LB, policy *RetryPolicy, log logger.Logger, rec stats.Recorder, endpoint string, attempt Attempt) (gorequest.Response, []byte, []error) {
+	return do(ctx, lb, policy, log, rec, endpoint, attempt, false, true)
+}
+
+func do(ctx context.Context, lb loadbalancer.LB, policy *RetryPolicy, log logger.Logger, rec stats.Recorder, endpoint string, attempt Attempt, observe, idempotent bool) (gorequest.Response, []byte, []error) {
+	if policy == nil {
+		policy = DefaultRetryPolicy()
+	}
+	log = logger.With(log)
+	rec = stats.With(rec)
+	timeout := policy.PerAttemptTimeout
+	if timeout <= 0 {
+		timeout = DefaultConnectTimeout
+	}
+	failover, _ := lb.(loadbalancer.FailoverLB)
+	var tried []*loadbalancer.Server
+	var resp gorequest.Response
+	var body []byte
+	var errs []error
+	for i := 0; i < policy.MaxAttempts || i == 0; i++ {
+		if i > 0 {
+			timer := time.NewTimer(policy.backoff(i))
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return resp, body, append(errs, ctx.Err())
+			}
+		}
+		var server *loadbalancer.Server
+		if failover != nil {
+			server = failover.SelectOneExcept(tried)
+		} else if i == 0 {
+			server = lb.SelectOne()
+		}
+		if server == nil {
+			if i == 0 {
+				return nil, nil, []error{loadbalancer.ErrNoServerAvailable}
+			}
+			break
+		}
+		tried = append(tried, server)
+		_, span := StartSpan(ctx, "nacos attempt", AttrServerAddress.String(server.URL.Host), AttrAttempt.Int(i+1))
+		start := time.Now()
+		server.Begin()
+		resp, body, errs = attempt(serverURL(server), timeout)
+		latency := time.Since(start)
+		EndRequestSpan(span, resp, errs)
+		server.End(latency, observe && resp != nil)
+		code := 0
+		if resp != nil {
+			code = resp.StatusCode
+		}
+		rec.Request(endpoint, server.URL.Host, code, latency)
+		if !policy.retryable(resp, errs, idempotent) {
+			break
+		}
+		if resp == nil && failover != nil {
+			failover.MarkSuspect(server)
+		}
+		log.Warn("request nacos server failed", logger.F("endpoint", endpoint), logger.F("server", server.URL.Host), logger.F("attempt", i+1), logger.F("errors", errs))
+	}
+	return resp, body, errs
+}
+
+func serverURL(server *loadbalancer.Server) string {
+	u := server.URL.String()
+	if !strings.HasSuffix(u, "/") {
+		u = u + "/"
+	}
+	return u
+}
+
+func (p *RetryPolicy) retryable(resp gorequest.Response, errs []error, idempotent bool) bool {
+	if p.Retryable != nil {
+		return p.Retryable(resp, errs)
+	}
+	if resp == nil {
+		if idempotent {
+			return len(errs) > 0
+		}
+		for _, er := range errs {
+			if IsConnectionError(er) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, code := range p.RetryableStatusCodes {
+		if resp.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+//idempotent endpoint的方法是否可以重复执行,POST和PUT超时的时候服务端可能已经处理了请求
+func idempotent(endpoint string) bool {
+	method := endpoint
+	if i := strings.IndexByte(endpoint, ' '); i >= 0 {
+		method = endpoint[:i]
+	}
+	switch strings.ToUpper(method) {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return false
+	}
+	return true
+}
+
+//IsConnectionError 请求还没有发送到server上的错误,例如建立连接失败,可以安全的重试
+func IsConnectionError(er error) bool {
+	var opErr *net.OpError
+	if errors.As(er, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return errors.Is(er, syscall.ECONNREFUSED)
+}
+
+func (p *RetryPolicy) backoff(retries int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < retries && d < p.MaxBackoff; i++ {
+		d = d * 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/celeskyking/go-nacos/client/loadbalancer"
+	"github.com/parnurzeal/gorequest"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T, url string) *loadbalancer.Server {
+	u, er := ToURL(url)
+	if er != nil {
+		t.Fatal(er)
+	}
+	return loadbalancer.NewServer(u, 100, "/")
+}
+
+func get(server string, timeout time.Duration) (gorequest.Response, []byte, []error) {
+	return gorequest.New().Timeout(timeout).Get(server + "ping").EndBytes()
+}
+
+func TestDo_Failover(t *testing.T) {
+	alive := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("pong"))
+	}))
+	defer alive.Close()
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	deadServer := newTestServer(t, dead.URL)
+	lb := loadbalancer.NewRoundRobin([]*loadbalancer.Server{deadServer, newTestServer(t, alive.URL)}, false)
+	policy := DefaultRetryPolicy()
+	policy.Backoff = time.Millisecond
+	for i := 0; i < 4; i++ {
+		resp, body, errs := Do(context.Background(), lb, policy, nil, nil, "GET ping", get)
+		if len(errs) != 0 || resp.StatusCode != http.StatusOK || string(body) != "pong" {
+			t.Fatalf("expect request failover to alive server, errs:%+v", errs)
+		}
+	}
+	if !deadServer.IsSuspect() {
+		t.Fatalf("expect dead server marked suspect")
+	}
+	for i := 0; i < 4; i++ {
+		if lb.SelectOne() == deadServer {
+			t.Fatalf("expect suspect server not selected")
+		}
+	}
+}
+
+func TestDo_RetryableStatusCode(t *testing.T) {
+	var hits []string
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits = append(hits, "unavailable")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+	badRequest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits = append(hits, "badRequest")
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer badRequest.Close()
+	lb := loadbalancer.NewRoundRobin([]*loadbalancer.Server{newTestServer(t, unavailable.URL), newTestServer(t, badRequest.URL)}, false)
+	policy := DefaultRetryPolicy()
+	policy.Backoff = time.Millisecond
+	resp, _, _ := Do(context.Background(), lb, policy, nil, nil, "GET ping", get)
+	if resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expect 400 returned without retry, got:%+v", resp)
+	}
+	//503会重试到另外一个server,400不会重试
+	if len(hits) > 2 || hits[len(hits)-1] != "badRequest" {
+		t.Fatalf("unexpected hits:%v", hits)
+	}
+
+	policy.MaxAttempts = 1
+	hits = nil
+	for i := 0; i < 2; i++ {
+		Do(context.Background(), lb, policy, nil, nil, "GET ping", get)
+	}
+	if len(hits) != 2 {
+		t.Fatalf("expect no retry with MaxAttempts 1, got:%v", hits)
+	}
+}
+
+func TestDo_EWMA(t *testing.T) {
+	handler := func(delay time.Duration) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(delay)
+		})
+	}
+	near := httptest.NewServer(handler(time.Millisecond))
+	defer near.Close()
+	far := httptest.NewServer(handler(30 * time.Millisecond))
+	defer far.Close()
+	nearServer := newTestServer(t, near.URL)
+	lb := NewLB(&HttpConfigOption{LBStrategy: EWMA, HealthCheck: &loadbalancer.HealthCheckOptions{Interval: time.Hour}},
+		[]*loadbalancer.Server{newTestServer(t, far.URL), nearServer})
+	defer lb.(io.Closer).Close()
+	counts := make(map[string]int)
+	for i := 0; i < 20; i++ {
+		resp, _, errs := Do(context.Background(), lb, nil, nil, nil, "GET ping", get)
+		if len(errs) != 0 {
+			t.Fatal(errs)
+		}
+		counts[resp.Request.URL.Host]++
+	}
+	if counts[nearServer.URL.Host] < 18 {
+		t.Fatalf("expect requests stick to near server, got:%v", counts)
+	}
+	if nearServer.Outstanding() != 0 || nearServer.Latency() == 0 {
+		t.Fatal("expect request timing recorded")
+	}
+}
+
+func TestDo_ContextDone(t *testing.T) {
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+	lb := loadbalancer.NewRoundRobin([]*loadbalancer.Server{newTestServer(t, unavailable.URL)}, false)
+	policy := DefaultRetryPolicy()
+	policy.Backoff = 10 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	resp, _, errs := Do(ctx, lb, policy, nil, nil, "GET ping", get)
+	if time.Since(start) > time.Second {
+		t.Fatalf("expect backoff interrupted by context, took:%s", time.Since(start))
+	}
+	if resp == nil || resp.StatusCode != http.StatusServiceUnavailable || len(errs) == 0 || !errors.Is(errs[len(errs)-1], context.DeadlineExceeded) {
+		t.Fatalf("expect last response and context error, got:%+v, %v", resp, errs)
+	}
+}
+
+func TestDo_NonIdempotent(t *testing.T) {
+	var hits int32
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+	policy := DefaultRetryPolicy()
+	policy.Backoff = time.Millisecond
+	policy.PerAttemptTimeout = 50 * time.Millisecond
+	post := func(server string, timeout time.Duration) (gorequest.Response, []byte, []error) {
+		return gorequest.New().Timeout(timeout).Post(server + "ping").EndBytes()
+	}
+	lb := loadbalancer.NewRoundRobin([]*loadbalancer.Server{newTestServer(t, slow.URL)}, false)
+	//超时的POST可能已经被服务端处理,不会重试
+	if _, _, errs := Do(context.Background(), lb, policy, nil, nil, "POST ping", post); len(errs) == 0 || atomic.LoadInt32(&hits) != 1 {
+		t.Fatalf("expect timed out POST not retried, hits:%d", atomic.LoadInt32(&hits))
+	}
+	//连接失败的时候请求没有发送,POST也会重试到其他的server
+	alive := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer alive.Close()
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	failover := loadbalancer.NewRoundRobin([]*loadbalancer.Server{newTestServer(t, dead.URL), newTestServer(t, alive.URL)}, false)
+	for i := 0; i < 2; i++ {
+		if resp, _, errs := Do(context.Background(), failover, policy, nil, nil, "POST ping", post); len(errs) != 0 || resp.StatusCode != http.StatusOK {
+			t.Fatalf("expect POST retried after connection refused, errs:%v", errs)
+		}
+	}
+}
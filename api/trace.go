@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"github.com/parnurzeal/gorequest"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"net/url"
+)
+
+//TracerName sdk创建span使用的tracer的名字,通过otel.SetTracerProvider设置的provider生效
+const TracerName = "github.com/celeskyking/go-nacos"
+
+//span的属性
+const (
+	AttrDataID = attribute.Key("nacos.data_id")
+
+	AttrGroup = attribute.Key("nacos.group")
+
+	AttrTenant = attribute.Key("nacos.tenant")
+
+	AttrService = attribute.Key("nacos.service")
+
+	AttrCluster = attribute.Key("nacos.cluster")
+
+	AttrNamespace = attribute.Key("nacos.namespace")
+
+	AttrEndpoint = attribute.Key("nacos.endpoint")
+
+	AttrServerAddress = attribute.Key("server.address")
+
+	AttrStatusCode = attribute.Key("http.response.status_code")
+
+	AttrAttempt = attribute.Key("nacos.attempt")
+)
+
+//queryAttributes 请求参数和span属性的对应关系
+var queryAttributes = map[string]attribute.Key{
+	"dataId":      AttrDataID,
+	"group":       AttrGroup,
+	"groupName":   AttrGroup,
+	"tenant":      AttrTenant,
+	"serviceName": AttrService,
+	"clusterName": AttrCluster,
+	"clusters":    AttrCluster,
+	"namespaceId": AttrNamespace,
+}
+
+//StartSpan 使用ctx中的trace作为父span创建span,ctx为nil的时候使用context.Background()
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return otel.Tracer(TracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+//StartRequestSpan 为一次请求nacos server创建span,从url编码的请求参数中提取dataId,group,tenant,serviceName和clusterName等属性
+func StartRequestSpan(ctx context.Context, endpoint, query string) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{AttrEndpoint.String(endpoint)}
+	if values, er := url.ParseQuery(query); er == nil {
+		for name, key := range queryAttributes {
+			if v := values.Get(name); v != "" {
+				attrs = append(attrs, key.String(v))
+			}
+		}
+	}
+	return StartSpan(ctx, "nacos "+endpoint, attrs...)
+}
+
+//EndRequestSpan 记录响应的状态码,请求失败或者状态码大于等于400的时候把span标记为错误,然后结束span
+func EndRequestSpan(span trace.Span, resp gorequest.Response, errs []error) {
+	var er error
+	if len(errs) > 0 {
+		er = errs[0]
+	}
+	if resp != nil {
+		span.SetAttributes(AttrStatusCode.Int(resp.StatusCode))
+		if er == nil && resp.StatusCode >= 400 {
+			er = fmt.Errorf("nacos server status code:%d", resp.StatusCode)
+		}
+	}
+	EndSpan(span, er)
+}
+
+//EndSpan 按照er设置span的状态并且结束span
+func EndSpan(span trace.Span, er error) {
+	if er != nil {
+		span.RecordError(er)
+		span.SetStatus(codes.Error, er.Error())
+	}
+	span.End()
+}
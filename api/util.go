@@ -24,3 +24,28 @@ func SelectOne(lb loadbalancer.LB) string {
 	}
 	return u
 }
+
+//NewLB 按照LBStrategy创建负载均衡,HealthCheck为空的时候使用默认的健康检查配置
+func NewLB(option *HttpConfigOption, servers []*loadbalancer.Server) loadbalancer.LB {
+	healthCheck := loadbalancer.DefaultHealthCheckOptions()
+	if option.HealthCheck != nil {
+		c := *option.HealthCheck
+		healthCheck = &c
+	}
+	if healthCheck.Logger == nil {
+		healthCheck.Logger = option.Logger
+	}
+	if healthCheck.Recorder == nil {
+		healthCheck.Recorder = option.Recorder
+	}
+	switch option.LBStrategy {
+	case RoundRobin:
+		return loadbalancer.NewRoundRobinWithHealthCheck(servers, healthCheck)
+	case EWMA:
+		return loadbalancer.NewEWMA(servers, healthCheck)
+	case LeastOutstanding:
+		return loadbalancer.NewLeastOutstanding(servers, healthCheck)
+	default:
+		return loadbalancer.NewDirectProxy(servers)
+	}
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"fmt"
+	"github.com/celeskyking/go-nacos/err"
+	"github.com/pkg/errors"
+)
+
+var ErrSnapshotDirIsEmpty = errors.New("未指定config service的snapshot目录")
+
+var ErrServersNotConfigured = errors.New("未配置nacos server信息")
+
+//Validate 校验server的配置,返回所有不合法的配置,没有错误的时候返回nil。返回的错误为*err.ValidationError
+func (o *ServerOptions) Validate() error {
+	v := &err.ValidationError{}
+	o.validate(v)
+	return v.Err()
+}
+
+func (o *ServerOptions) validate(v *err.ValidationError) {
+	if o == nil {
+		v.Add(ErrServersNotConfigured)
+		return
+	}
+	endpointEnabled := o.EndpointEnabled && (o.Endpoint != "" || o.EndpointOptions != nil && o.EndpointOptions.Address != "")
+	if o.EndpointEnabled && !endpointEnabled {
+		v.Add(errors.New("endpoint address is empty"))
+	}
+	if len(o.Addresses) == 0 && !endpointEnabled && o.ServerProvider == nil {
+		v.Add(errors.New("nacos server list is empty, set Addresses, Endpoint or ServerProvider"))
+	}
+	for _, addr := range o.Addresses {
+		if _, er := ToURL(addr); er != nil {
+			v.Add(errors.Wrapf(er, "invalid nacos server address:%s", addr))
+		}
+	}
+	if o.LBStrategy < Direct || o.LBStrategy > LeastOutstanding {
+		v.Add(fmt.Errorf("unknown LBStrategy:%d", o.LBStrategy))
+	}
+	if p := o.Push; p != nil && !p.Disabled {
+		if p.Port < 0 || p.Port > 65535 {
+			v.Add(fmt.Errorf("invalid push port:%d", p.Port))
+		}
+		if p.Port == 0 && p.PortRangeStart > 0 && p.PortRangeEnd > 0 && p.PortRangeEnd <= p.PortRangeStart {
+			v.Add(fmt.Errorf("invalid push port range:[%d, %d)", p.PortRangeStart, p.PortRangeEnd))
+		}
+	}
+	if p := o.RetryPolicy; p != nil {
+		if p.MaxAttempts < 0 {
+			v.Add(fmt.Errorf("invalid retry MaxAttempts:%d", p.MaxAttempts))
+		}
+		if p.PerAttemptTimeout < 0 || p.Backoff < 0 || p.MaxBackoff < 0 {
+			v.Add(errors.New("retry timeout and backoff must not be negative"))
+		}
+	}
+}
+
+//Validate 校验配置中心的配置,包括server的配置和snapshot目录
+func (o *ConfigOptions) Validate() error {
+	v := &err.ValidationError{}
+	o.ServerOptions.validate(v)
+	if o.SnapshotDir == "" {
+		v.Add(ErrSnapshotDirIsEmpty)
+	}
+	return v.Err()
+}
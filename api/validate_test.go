@@ -0,0 +1,33 @@
+package api
+
+import (
+	"errors"
+	"github.com/celeskyking/go-nacos/err"
+	"testing"
+)
+
+func TestServerOptions_Validate(t *testing.T) {
+	if er := (&ServerOptions{Addresses: []string{"127.0.0.1:8848"}}).Validate(); er != nil {
+		t.Fatalf("expect valid options, got:%v", er)
+	}
+	er := (&ConfigOptions{ServerOptions: &ServerOptions{
+		Addresses:   []string{"127.0.0.1:abc"},
+		LBStrategy:  LBStrategy(10),
+		Push:        &PushOptions{PortRangeStart: 46000, PortRangeEnd: 45000},
+		RetryPolicy: &RetryPolicy{MaxAttempts: -1},
+	}}).Validate()
+	var v *err.ValidationError
+	if !errors.As(er, &v) || len(v.Errors) != 5 {
+		t.Fatalf("expect all problems reported, got:%v", er)
+	}
+	if !errors.Is(er, err.ErrInvalidOptions) || !errors.Is(er, ErrSnapshotDirIsEmpty) {
+		t.Fatalf("unexpected error:%v", er)
+	}
+	er = (&ConfigOptions{SnapshotDir: "/tmp"}).Validate()
+	if !errors.Is(er, ErrServersNotConfigured) {
+		t.Fatalf("expect servers not configured, got:%v", er)
+	}
+	if er := (&ServerOptions{}).Validate(); er == nil {
+		t.Fatal("expect error without server address, endpoint and provider")
+	}
+}
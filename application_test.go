@@ -0,0 +1,222 @@
+package nacos
+
+import (
+	"errors"
+	"github.com/celeskyking/go-nacos/api"
+	"github.com/celeskyking/go-nacos/client/loadbalancer"
+	"github.com/celeskyking/go-nacos/internal/nacostest"
+	"github.com/celeskyking/go-nacos/naming"
+	"github.com/celeskyking/go-nacos/pkg/logger"
+	"github.com/celeskyking/go-nacos/pkg/stats"
+	"github.com/celeskyking/go-nacos/types"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestApplication_UpdateServers(t *testing.T) {
+	first, second := nacostest.NewServer(), nacostest.NewServer()
+	defer first.Close()
+	defer second.Close()
+	second.SetInstances("", "dev", "demo", toHost(t, "10.0.0.1:8080"))
+	app := NewApplication(&api.AppConfig{})
+	app.SetNamingServers(&api.ServerOptions{
+		Addresses:  []string{first.Addr()},
+		LBStrategy: api.RoundRobin,
+		Push:       &api.PushOptions{Disabled: true},
+	})
+	ns := app.NewNamingService()
+	defer ns.Stop()
+	var events []*loadbalancer.ServerChangeEvent
+	ns.HttpClient().LoadBalance().(loadbalancer.Observable).OnServersChange(func(event *loadbalancer.ServerChangeEvent) {
+		events = append(events, event)
+	})
+	if er := app.UpdateServers(nil); er == nil {
+		t.Fatal("expect error for empty server list")
+	}
+	if er := app.UpdateServers([]string{second.Addr()}); er != nil {
+		t.Fatalf("update servers error:%+v", er)
+	}
+	if len(events) != 1 || len(events[0].Added) != 1 || len(events[0].Removed) != 1 {
+		t.Fatalf("expect one server change event, got:%d", len(events))
+	}
+	//地址相同的时候不会触发事件
+	if er := app.UpdateServers([]string{second.Addr()}); er != nil || len(events) != 1 {
+		t.Fatal("expect no event for unchanged server list")
+	}
+	sl, er := ns.GetInstances("demo", &naming.QueryOptions{Group: "dev"})
+	if er != nil {
+		t.Fatalf("get instances error:%+v", er)
+	}
+	defer sl.StopListen()
+	if len(sl.GetAll()) != 1 {
+		t.Fatal("expect instances from updated server")
+	}
+	if app.namingServers.Addresses[0] != second.Addr() {
+		t.Fatal("expect options updated for services created later")
+	}
+}
+
+func TestApplication_NewServiceE(t *testing.T) {
+	app := NewApplication(&api.AppConfig{})
+	if _, er := app.NewNamingServiceE(); !errors.Is(er, ErrServersNotConfigured) {
+		t.Fatalf("expect servers not configured, got:%v", er)
+	}
+	if _, er := app.NewConfigServiceE(""); !errors.Is(er, ErrServersNotConfigured) || !errors.Is(er, ErrSnapshotDirIsEmpty) {
+		t.Fatalf("expect all problems reported, got:%v", er)
+	}
+
+	//没有配置server的时候不会退出进程,UpdateServers之后可以正常请求
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	nacos.SetInstances("", "dev", "demo", toHost(t, "10.0.0.1:8080"))
+	ns := app.NewNamingService()
+	defer ns.Stop()
+	option := &types.ServiceInstanceListOption{ServiceName: "dev@@demo"}
+	if _, er := ns.HttpClient().ListServiceInstance(option); !errors.Is(er, loadbalancer.ErrNoServerAvailable) {
+		t.Fatalf("expect no server available, got:%v", er)
+	}
+	if er := app.UpdateServers([]string{nacos.Addr()}); er != nil {
+		t.Fatal(er)
+	}
+	result, er := ns.HttpClient().ListServiceInstance(option)
+	if er != nil || len(result.Hosts) != 1 {
+		t.Fatalf("expect instances after UpdateServers, got:%v", er)
+	}
+}
+
+type failingUpdater struct {
+	calls int
+}
+
+func (f *failingUpdater) UpdateServers(addrs []string) error {
+	f.calls++
+	return errors.New("update failed")
+}
+
+func TestApplication_UpdateSeparateServers(t *testing.T) {
+	configServers := &api.ServerOptions{Addresses: []string{"10.0.0.1:8848"}, LBStrategy: api.RoundRobin}
+	namingServers := &api.ServerOptions{Addresses: []string{"10.0.1.1:8848"}, LBStrategy: api.RoundRobin, Push: &api.PushOptions{Disabled: true}}
+	app := NewApplication(&api.AppConfig{})
+	app.SetConfigServers(configServers)
+	app.SetNamingServers(namingServers)
+	cs := app.NewConfigService(t.TempDir())
+	defer cs.HttpClient().Stop()
+	ns := app.NewNamingService()
+	defer ns.Stop()
+
+	if er := app.UpdateNamingServers([]string{"10.0.1.2:8848"}); er != nil {
+		t.Fatal(er)
+	}
+	if host := cs.HttpClient().LoadBalance().GetServers()[0].URL.Host; host != "10.0.0.1:8848" {
+		t.Fatalf("expect config servers unchanged, got:%s", host)
+	}
+	if host := ns.HttpClient().LoadBalance().GetServers()[0].URL.Host; host != "10.0.1.2:8848" {
+		t.Fatalf("expect naming servers updated, got:%s", host)
+	}
+	if namingServers.Addresses[0] != "10.0.1.1:8848" || app.namingServers.Addresses[0] != "10.0.1.2:8848" {
+		t.Fatal("expect options copied before update")
+	}
+
+	failed, other := &failingUpdater{}, &failingUpdater{}
+	app.track(&app.configUpdaters, failed)
+	app.track(&app.configUpdaters, other)
+	er := app.UpdateConfigServers([]string{"10.0.0.2:8848"})
+	var u *UpdateServersError
+	if !errors.As(er, &u) || len(u.Errors) != 2 || other.calls != 1 {
+		t.Fatalf("expect every client updated and errors collected, got:%v", er)
+	}
+	if host := cs.HttpClient().LoadBalance().GetServers()[0].URL.Host; host != "10.0.0.2:8848" {
+		t.Fatalf("expect config servers updated, got:%s", host)
+	}
+}
+
+type recordLogger struct {
+	lock sync.Mutex
+
+	msgs []string
+}
+
+func (r *recordLogger) log(msg string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.msgs = append(r.msgs, msg)
+}
+
+func (r *recordLogger) count() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return len(r.msgs)
+}
+
+func (r *recordLogger) Debug(msg string, fields ...logger.Field) { r.log(msg) }
+
+func (r *recordLogger) Info(msg string, fields ...logger.Field) { r.log(msg) }
+
+func (r *recordLogger) Warn(msg string, fields ...logger.Field) { r.log(msg) }
+
+func (r *recordLogger) Error(msg string, fields ...logger.Field) { r.log(msg) }
+
+func TestApplication_SetLogger(t *testing.T) {
+	defer logger.SetLogger(logger.NewLogrus(nil))
+	global, first, second := &recordLogger{}, &recordLogger{}, &recordLogger{}
+	logger.SetLogger(global)
+	options := &api.ServerOptions{LBStrategy: api.RoundRobin, Push: &api.PushOptions{Disabled: true}}
+	a1, a2 := NewApplication(&api.AppConfig{}), NewApplication(&api.AppConfig{})
+	a1.SetNamingServers(options)
+	a2.SetNamingServers(options)
+	a1.SetLogger(first)
+	a2.SetLogger(second)
+	//没有配置server的时候输出错误日志,每个服务使用各自的Logger
+	ns1 := a1.NewNamingService()
+	defer ns1.Stop()
+	ns2 := a2.NewNamingService()
+	defer ns2.Stop()
+	if first.count() == 0 || second.count() == 0 || global.count() != 0 {
+		t.Fatalf("expect logs written to each application's logger, got:%v, %v, %v", first.msgs, second.msgs, global.msgs)
+	}
+	if options.Logger != nil {
+		t.Fatal("expect options not modified")
+	}
+}
+
+type countRecorder struct {
+	stats.Nop
+
+	requests int32
+}
+
+func (c *countRecorder) Request(endpoint, server string, code int, latency time.Duration) {
+	atomic.AddInt32(&c.requests, 1)
+}
+
+func TestApplication_SetRecorder(t *testing.T) {
+	defer stats.SetRecorder(nil)
+	global, first, second := &countRecorder{}, &countRecorder{}, &countRecorder{}
+	stats.SetRecorder(global)
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	nacos.SetInstances("", "dev", "demo", toHost(t, "10.0.0.1:8080"))
+	options := &api.ServerOptions{Addresses: []string{nacos.Addr()}, LBStrategy: api.RoundRobin, Push: &api.PushOptions{Disabled: true}}
+	a1, a2 := NewApplication(&api.AppConfig{}), NewApplication(&api.AppConfig{})
+	a1.SetNamingServers(options)
+	a2.SetNamingServers(options)
+	a1.SetRecorder(first)
+	a2.SetRecorder(second)
+	//每个Application创建的服务使用各自的Recorder,不会互相覆盖
+	option := &types.ServiceInstanceListOption{ServiceName: "dev@@demo"}
+	for _, a := range []*Application{a1, a2} {
+		ns := a.NewNamingService()
+		if _, er := ns.HttpClient().ListServiceInstance(option); er != nil {
+			t.Fatalf("list instances error:%+v", er)
+		}
+		ns.Stop()
+	}
+	if atomic.LoadInt32(&first.requests) != 1 || atomic.LoadInt32(&second.requests) != 1 || atomic.LoadInt32(&global.requests) != 0 {
+		t.Fatalf("expect requests recorded by each application's recorder, got:%d, %d, %d", first.requests, second.requests, global.requests)
+	}
+	if options.Recorder != nil {
+		t.Fatal("expect options not modified")
+	}
+}
@@ -24,6 +24,14 @@ func NewNamingHttp() *gorequest.SuperAgent {
 		Set("Request-Module", "Naming")
 }
 
+//NewConfigHttp 配置中心使用的请求,带有RequestId,请求失败的时候记录在NacosError中
+func NewConfigHttp() *gorequest.SuperAgent {
+	return Client.Clone().Set("User-Agent", "nacos-go-sdk:v1.0.1").
+		Set("Client-Version", "nacos-go-sdk:v1.0.1").
+		Set("RequestId", uid()).
+		Set("Request-Module", "Config")
+}
+
 func New() *gorequest.SuperAgent {
 	return Client.Clone()
 }
@@ -0,0 +1,175 @@
+package loadbalancer
+
+import (
+	"fmt"
+	"github.com/celeskyking/go-nacos/client/http"
+	"github.com/celeskyking/go-nacos/pkg/logger"
+	"github.com/celeskyking/go-nacos/pkg/stats"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Probe 检查server是否健康,返回nil表示健康
+type Probe func(server *Server, timeout time.Duration) error
+
+//HTTPProbe 请求server的HealthPath,返回200的时候表示健康
+func HTTPProbe(server *Server, timeout time.Duration) error {
+	u := strings.TrimSuffix(server.URL.String(), "/") + "/" + strings.TrimPrefix(server.HealthPath, "/")
+	resp, _, errs := http.New().Timeout(timeout).Get(u).End()
+	if len(errs) != 0 {
+		return errs[0]
+	}
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("health check status code:%d", resp.StatusCode)
+	}
+	return nil
+}
+
+//HealthCheckOptions server的健康检查配置
+type HealthCheckOptions struct {
+	//检查的间隔
+	Interval time.Duration
+	//每次检查的超时时间
+	Timeout time.Duration
+	//检查的方式,为空的时候使用HTTPProbe
+	Probe Probe
+	//负载均衡和健康检查输出日志使用的Logger,为空的时候使用全局的Logger
+	Logger logger.Logger
+	//记录server健康状态变化使用的Recorder,为空的时候使用全局的Recorder
+	Recorder stats.Recorder
+}
+
+//DefaultHealthCheckOptions 默认每10s检查一次,超时时间为3s
+func DefaultHealthCheckOptions() *HealthCheckOptions {
+	return &HealthCheckOptions{
+		Interval: DefaultInterval,
+		Timeout:  DefaultTimeout,
+		Probe:    HTTPProbe,
+	}
+}
+
+type HealthCheck interface {
+	//Check 定时检查server,状态变化的时候回调,stop关闭之后返回
+	Check(stop <-chan struct{}, server *Server, callback func(state ServerHealthState))
+}
+
+//NewHealthCheck 创建健康检查,options为nil的时候使用DefaultHealthCheckOptions
+func NewHealthCheck(options *HealthCheckOptions) HealthCheck {
+	o := DefaultHealthCheckOptions()
+	if options != nil {
+		c := *options
+		o = &c
+		if o.Interval <= 0 {
+			o.Interval = DefaultInterval
+		}
+		if o.Timeout <= 0 {
+			o.Timeout = DefaultTimeout
+		}
+		if o.Probe == nil {
+			o.Probe = HTTPProbe
+		}
+	}
+	return &healthCheck{options: o}
+}
+
+type healthCheck struct {
+	options *HealthCheckOptions
+}
+
+func (h *healthCheck) Check(stop <-chan struct{}, server *Server, callback func(state ServerHealthState)) {
+	ticker := time.NewTicker(h.options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			state := Passing
+			start := time.Now()
+			if er := h.options.Probe(server, h.options.Timeout); er != nil {
+				state = Critical
+			} else {
+				//探测的耗时也作为延迟的样本,没有业务请求的server也能够更新延迟
+				server.observeLatency(time.Since(start))
+			}
+			if server.GetState() != state {
+				callback(state)
+			}
+		}
+	}
+}
+
+//checkGroup 为一批server分别启动检查的goroutine,替换server的时候停止上一批的检查
+type checkGroup struct {
+	checker HealthCheck
+	//状态变化之后的回调
+	onChange func()
+
+	lock sync.Mutex
+
+	stop chan struct{}
+
+	closed bool
+
+	log logger.Logger
+
+	rec stats.Recorder
+}
+
+func newCheckGroup(options *HealthCheckOptions, onChange func()) *checkGroup {
+	g := &checkGroup{
+		checker:  NewHealthCheck(options),
+		onChange: onChange,
+		log:      optionsLogger(options),
+		rec:      stats.With(nil),
+	}
+	if options != nil {
+		g.rec = stats.With(options.Recorder)
+	}
+	return g
+}
+
+//optionsLogger 返回options中的Logger,options为nil的时候使用全局的Logger
+func optionsLogger(options *HealthCheckOptions) logger.Logger {
+	if options == nil {
+		return logger.With(nil)
+	}
+	return logger.With(options.Logger)
+}
+
+//restart 停止当前的检查,为servers重新启动检查,close之后不再启动
+func (g *checkGroup) restart(servers []*Server) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.closed {
+		return
+	}
+	if g.stop != nil {
+		close(g.stop)
+	}
+	g.stop = make(chan struct{})
+	for _, s := range servers {
+		go g.check(g.stop, s)
+	}
+}
+
+func (g *checkGroup) check(stop <-chan struct{}, server *Server) {
+	g.checker.Check(stop, server, func(state ServerHealthState) {
+		if server.SetState(state) {
+			g.log.Info("nacos server health state changed", logger.F("server", server.URL.Host), logger.F("state", state))
+			g.rec.ServerHealth(server.URL.Host, state == Passing)
+			g.onChange()
+		}
+	})
+}
+
+func (g *checkGroup) close() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.closed = true
+	if g.stop != nil {
+		close(g.stop)
+		g.stop = nil
+	}
+}
@@ -0,0 +1,79 @@
+package loadbalancer
+
+import (
+	"sync"
+)
+
+//ServerChangeEvent server列表的变化
+type ServerChangeEvent struct {
+	//变化之后的server列表
+	Servers []*Server
+	//新增的server
+	Added []*Server
+	//移除的server
+	Removed []*Server
+}
+
+//ServerListener 监听server列表的变化
+type ServerListener func(event *ServerChangeEvent)
+
+//Observable 支持监听server列表变化的负载均衡,DirectProxy,RoundRobin,EWMA和LeastOutstanding都实现了该接口
+type Observable interface {
+	//OnServersChange 添加监听器,RefreshServers导致server列表变化的时候回调
+	OnServersChange(listener ServerListener)
+}
+
+type listeners struct {
+	lock sync.Mutex
+
+	list []ServerListener
+}
+
+func (l *listeners) OnServersChange(listener ServerListener) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.list = append(l.list, listener)
+}
+
+func (l *listeners) notify(event *ServerChangeEvent) {
+	l.lock.Lock()
+	list := l.list
+	l.lock.Unlock()
+	for _, listener := range list {
+		listener(event)
+	}
+}
+
+//mergeServers 按照地址比较current和next,地址相同的server保留current中的对象,保留延迟和健康状态。
+//没有新增和移除的server的时候event为nil
+func mergeServers(current, next []*Server) (merged []*Server, event *ServerChangeEvent) {
+	existing := make(map[string]*Server, len(current))
+	for _, s := range current {
+		existing[s.URL.String()] = s
+	}
+	event = &ServerChangeEvent{}
+	kept := make(map[string]struct{}, len(next))
+	for _, s := range next {
+		key := s.URL.String()
+		if _, ok := kept[key]; ok {
+			continue
+		}
+		kept[key] = struct{}{}
+		if old, ok := existing[key]; ok {
+			merged = append(merged, old)
+			continue
+		}
+		merged = append(merged, s)
+		event.Added = append(event.Added, s)
+	}
+	for _, s := range current {
+		if _, ok := kept[s.URL.String()]; !ok {
+			event.Removed = append(event.Removed, s)
+		}
+	}
+	if len(event.Added) == 0 && len(event.Removed) == 0 {
+		return merged, nil
+	}
+	event.Servers = merged
+	return merged, event
+}
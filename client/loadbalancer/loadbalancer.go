@@ -1,21 +1,27 @@
 package loadbalancer
 
 import (
-	"github.com/celeskyking/go-nacos/client/http"
-	"github.com/parnurzeal/gorequest"
-	"github.com/sirupsen/logrus"
+	"github.com/celeskyking/go-nacos/pkg/logger"
+	"github.com/pkg/errors"
 	"net/url"
-	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
 	DefaultTimeout  = 3 * time.Second
 	DefaultInterval = 10 * time.Second
+	//DefaultSuspectDuration 请求失败之后server被标记为可疑的时间,期间不会被选中
+	DefaultSuspectDuration = DefaultInterval
+	//EWMAAlpha 每个新的延迟样本的权重
+	EWMAAlpha = 0.3
 )
 
+//ErrNoServerAvailable 没有可以选择的server
+var ErrNoServerAvailable = errors.New("no nacos server available")
+
 type ServerHealthState int
 
 const (
@@ -33,65 +39,72 @@ type LB interface {
 	GetServers() []*Server
 }
 
+//FailoverLB 支持故障转移的负载均衡,请求失败的时候可以立即把server标记为可疑,重试的时候选择其他的server
+type FailoverLB interface {
+	LB
+	//MarkSuspect 在DefaultSuspectDuration内不再选择该server,不需要等待健康检查
+	MarkSuspect(server *Server)
+	//SelectOneExcept 选择一个不在excluded中的server,健康的server都被排除的时候降级选择可疑的server
+	SelectOneExcept(excluded []*Server) *Server
+}
+
 type DirectProxy struct {
 	Server *Server
+
+	lock sync.Mutex
+
+	listeners
 }
 
 func (d *DirectProxy) SelectOne() *Server {
+	d.lock.Lock()
+	defer d.lock.Unlock()
 	return d.Server
 }
 
 func (d *DirectProxy) GetServers() []*Server {
+	d.lock.Lock()
+	defer d.lock.Unlock()
 	return []*Server{d.Server}
 }
 
+//RefreshServers 使用servers中的第一个server,地址变化的时候通知监听器
 func (d *DirectProxy) RefreshServers(servers []*Server) {
 	if len(servers) == 0 {
 		return
 	}
-	d.Server = servers[0]
-}
-
-func NewDirectProxy(server []*Server) LB {
-	if len(server) == 0 {
-		return &DirectProxy{}
+	d.lock.Lock()
+	var current []*Server
+	if d.Server != nil {
+		current = []*Server{d.Server}
 	}
-	return &DirectProxy{
-		Server: server[0],
+	merged, event := mergeServers(current, servers[:1])
+	d.Server = merged[0]
+	d.lock.Unlock()
+	if event != nil {
+		d.notify(event)
 	}
 }
 
-type HealthCheck interface {
-	//
-	Check(stop <-chan struct{}, server *Server, callback func(state ServerHealthState))
+func (d *DirectProxy) MarkSuspect(server *Server) {
+	server.markSuspect()
 }
 
-type healthCheck struct {
+func (d *DirectProxy) SelectOneExcept(excluded []*Server) *Server {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.Server == nil || containsServer(excluded, d.Server) {
+		return nil
+	}
+	return d.Server
 }
 
-func (h *healthCheck) Check(stop <-chan struct{}, server *Server, callback func(state ServerHealthState)) {
-	ticker := time.NewTicker(DefaultInterval)
-	currentState := Passing
-	for {
-		select {
-		case <-stop:
-		case <-ticker.C:
-			http.New().Clone().Get(server.URL.String() + server.HealthPath).End(func(response gorequest.Response, body string, errs []error) {
-				if len(errs) != 0 || response == nil || response.StatusCode != 200 {
-					if currentState != Critical {
-						callback(Critical)
-						currentState = Critical
-					}
-					return
-				}
-				if response.StatusCode == 200 {
-					if currentState == Critical {
-						callback(Passing)
-						currentState = Passing
-					}
-				}
-			})
-		}
+func NewDirectProxy(server []*Server) LB {
+	if len(server) == 0 {
+		return &DirectProxy{}
+	}
+	return &DirectProxy{
+		Server: server[0],
 	}
 }
 
@@ -110,6 +123,19 @@ type RoundRobin struct {
 	Stop chan struct{}
 	//设置是否开启健康监测
 	HealthCheckEnabled bool
+
+	//server的健康检查,RefreshServers的时候重新启动
+	checks *checkGroup
+
+	stopOnce sync.Once
+	//降级的时候轮询的索引
+	fallbackIndex int
+
+	fallback fallbackState
+	//为空的时候使用全局的Logger
+	log logger.Logger
+
+	listeners
 }
 
 // 最大公约数
@@ -121,17 +147,25 @@ func greaterCommonDivisor(i, j int) int {
 }
 
 func (r *RoundRobin) GetServers() []*Server {
+	r.lock.Lock()
+	defer r.lock.Unlock()
 	return r.Servers
 }
 
+//SelectOne 加权轮询选择一个健康的server,所有的server都不健康的时候降级为在全部的server中轮询
 func (r *RoundRobin) SelectOne() *Server {
 	r.lock.Lock()
 	defer r.lock.Unlock()
 	servers := r.selectHealthyServers()
 	if len(servers) == 0 {
-		//todo 降级server
-		return nil
+		if len(r.Servers) == 0 {
+			return nil
+		}
+		r.fallback.set(logger.With(r.log), true)
+		r.fallbackIndex = (r.fallbackIndex + 1) % len(r.Servers)
+		return r.Servers[r.fallbackIndex]
 	}
+	r.fallback.set(logger.With(r.log), false)
 	if len(servers) == 1 {
 		return servers[0]
 	}
@@ -146,16 +180,56 @@ func (r *RoundRobin) SelectOne() *Server {
 				}
 			}
 		}
-		if weight := servers[r.CurrentIndex].Weight; weight > r.CurrentWeight {
+		if weight := servers[r.CurrentIndex].Weight; weight >= r.CurrentWeight {
 			return servers[r.CurrentIndex]
 		}
 	}
 }
 
+//MarkSuspect 把server标记为可疑,并且重新计算权重
+func (r *RoundRobin) MarkSuspect(server *Server) {
+	server.markSuspect()
+	r.refresh()
+}
+
+//SelectOneExcept 按照加权轮询选择一个不在excluded中的server
+func (r *RoundRobin) SelectOneExcept(excluded []*Server) *Server {
+	r.lock.Lock()
+	n := len(r.Servers)
+	r.lock.Unlock()
+	for i := 0; i < n; i++ {
+		s := r.SelectOne()
+		if s == nil {
+			break
+		}
+		if !containsServer(excluded, s) {
+			return s
+		}
+	}
+	//健康的server都已经尝试过,降级到可疑或者不健康的server
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, s := range r.Servers {
+		if !containsServer(excluded, s) {
+			return s
+		}
+	}
+	return nil
+}
+
+func containsServer(servers []*Server, server *Server) bool {
+	for _, s := range servers {
+		if s == server {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *RoundRobin) selectHealthyServers() []*Server {
 	var result []*Server
 	for _, s := range r.Servers {
-		if s.State == Passing {
+		if s.GetState() == Passing && !s.IsSuspect() {
 			result = append(result, s)
 		}
 	}
@@ -180,37 +254,59 @@ func (r *RoundRobin) getGcdForServers() int {
 		if i == 0 {
 			m = servers[i].Weight
 		} else {
-			m = greaterCommonDivisor(servers[i].Weight, servers[i+1].Weight)
+			m = greaterCommonDivisor(m, servers[i].Weight)
 		}
 	}
 	return m
 }
 
-//NewRoundRobin 加权轮询服务器
+//NewRoundRobin 加权轮询服务器,healthCheckEnabled为true的时候使用默认配置检查server的健康状态
 func NewRoundRobin(servers []*Server, healthCheckEnabled bool) LB {
+	if healthCheckEnabled {
+		return NewRoundRobinWithHealthCheck(servers, DefaultHealthCheckOptions())
+	}
+	return NewRoundRobinWithHealthCheck(servers, nil)
+}
+
+//NewRoundRobinWithHealthCheck 加权轮询服务器,options为nil的时候不开启健康检查
+func NewRoundRobinWithHealthCheck(servers []*Server, options *HealthCheckOptions) LB {
 	r := &RoundRobin{
-		Servers: servers,
-		Stop:    make(chan struct{}, 0),
+		Servers:            servers,
+		Stop:               make(chan struct{}, 0),
+		HealthCheckEnabled: options != nil,
+		log:                optionsLogger(options),
 	}
-	if len(servers) > 0 && healthCheckEnabled {
-		r.Start(r.Stop)
+	if options != nil {
+		r.checks = newCheckGroup(options, r.refresh)
+		r.checks.restart(servers)
 	}
 	r.refresh()
 	return r
 }
 
+//Start 兼容之前的接口,使用默认配置检查当前的server,stop关闭的时候停止
 func (r *RoundRobin) Start(stop <-chan struct{}) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.checks == nil {
+		r.checks = newCheckGroup(nil, r.refresh)
+	}
 	for _, s := range r.Servers {
-		go func(stop <-chan struct{}) {
-			checker := &healthCheck{}
-			checker.Check(stop, s, func(state ServerHealthState) {
-				s.State = state
-				r.refresh()
-			})
-		}(stop)
+		go r.checks.check(stop, s)
 	}
 }
 
+//Close 停止所有的健康检查,可以重复调用
+func (r *RoundRobin) Close() error {
+	r.stopOnce.Do(func() {
+		if r.checks != nil {
+			r.checks.close()
+		}
+		close(r.Stop)
+	})
+	return nil
+}
+
 func (r *RoundRobin) refresh() {
 	r.lock.Lock()
 	defer r.lock.Unlock()
@@ -219,15 +315,54 @@ func (r *RoundRobin) refresh() {
 	r.CurrentIndex = -1
 }
 
+//RefreshServers 替换server列表,地址相同的server保留原来的健康状态。列表变化的时候重新启动健康检查并且通知监听器
 func (r *RoundRobin) RefreshServers(servers []*Server) {
 	if len(servers) == 0 {
-		logrus.Errorf("no working servers")
+		logger.With(r.log).Error("no working servers")
 	}
-	if !reflect.DeepEqual(r.Servers, servers) {
-		r.Servers = servers
-		r.refresh()
+	r.lock.Lock()
+	merged, event := mergeServers(r.Servers, servers)
+	if event == nil {
+		r.lock.Unlock()
+		return
 	}
+	r.Servers = merged
+	if r.checks != nil && r.HealthCheckEnabled {
+		r.checks.restart(merged)
+	}
+	r.lock.Unlock()
+	r.refresh()
+	logServerChange(logger.With(r.log), event)
+	r.notify(event)
+}
+
+func logServerChange(log logger.Logger, event *ServerChangeEvent) {
+	var added, removed []string
+	for _, s := range event.Added {
+		added = append(added, s.URL.Host)
+	}
+	for _, s := range event.Removed {
+		removed = append(removed, s.URL.Host)
+	}
+	log.Info("nacos servers changed", logger.F("added", added), logger.F("removed", removed))
+}
+
+//fallbackState 是否降级为在全部的server中选择,只在状态变化的时候输出日志,避免nacos server全部不可用的时候每次请求都输出日志
+type fallbackState struct {
+	fallback bool
+}
 
+//set 更新降级状态,调用方需要持有负载均衡的锁
+func (f *fallbackState) set(log logger.Logger, fallback bool) {
+	if f.fallback == fallback {
+		return
+	}
+	f.fallback = fallback
+	if fallback {
+		log.Warn("no healthy nacos server, fallback to all servers")
+	} else {
+		log.Info("healthy nacos server available, stop fallback")
+	}
 }
 
 type Server struct {
@@ -240,6 +375,80 @@ type Server struct {
 
 	//健康监测的地址
 	HealthPath string
+
+	lock sync.Mutex
+	//可疑状态的截止时间
+	suspectUntil time.Time
+	//正在进行的请求数
+	outstanding int64
+	//请求耗时的指数加权移动平均
+	latency time.Duration
+}
+
+//GetState 返回健康检查的状态
+func (s *Server) GetState() ServerHealthState {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.State
+}
+
+//SetState 更新健康检查的状态,状态发生变化的时候返回true
+func (s *Server) SetState(state ServerHealthState) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.State == state {
+		return false
+	}
+	s.State = state
+	return true
+}
+
+func (s *Server) markSuspect() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.suspectUntil = time.Now().Add(DefaultSuspectDuration)
+}
+
+//IsSuspect 最近请求失败过并且还在可疑的时间内
+func (s *Server) IsSuspect() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return time.Now().Before(s.suspectUntil)
+}
+
+//Begin 开始请求server,和End成对调用
+func (s *Server) Begin() {
+	atomic.AddInt64(&s.outstanding, 1)
+}
+
+//End 请求结束,observe为false的时候不记录耗时,例如长轮询的耗时不能反映server的延迟
+func (s *Server) End(latency time.Duration, observe bool) {
+	atomic.AddInt64(&s.outstanding, -1)
+	if observe {
+		s.observeLatency(latency)
+	}
+}
+
+//Outstanding 正在进行的请求数
+func (s *Server) Outstanding() int64 {
+	return atomic.LoadInt64(&s.outstanding)
+}
+
+//Latency 请求耗时的指数加权移动平均,没有样本的时候为0
+func (s *Server) Latency() time.Duration {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.latency
+}
+
+func (s *Server) observeLatency(latency time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.latency == 0 {
+		s.latency = latency
+		return
+	}
+	s.latency = time.Duration(EWMAAlpha*float64(latency) + (1-EWMAAlpha)*float64(s.latency))
 }
 
 func (s *Server) GetHost() string {
@@ -0,0 +1,214 @@
+package loadbalancer
+
+import (
+	"errors"
+	"github.com/celeskyking/go-nacos/pkg/logger"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T, rawURL string, weight int) *Server {
+	u, er := url.Parse(rawURL)
+	if er != nil {
+		t.Fatal(er)
+	}
+	return NewServer(u, weight, "/liveness")
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not satisfied in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRoundRobin_HealthCheck(t *testing.T) {
+	var healthy int32 = 1
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer flaky.Close()
+	alive := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer alive.Close()
+	a, b := newTestServer(t, flaky.URL, 100), newTestServer(t, alive.URL, 100)
+	lb := NewRoundRobinWithHealthCheck([]*Server{a, b}, &HealthCheckOptions{
+		Interval: 10 * time.Millisecond,
+		Timeout:  time.Second,
+	}).(*RoundRobin)
+	defer lb.Close()
+
+	atomic.StoreInt32(&healthy, 0)
+	waitFor(t, func() bool { return a.GetState() == Critical })
+	for i := 0; i < 10; i++ {
+		if s := lb.SelectOne(); s != b {
+			t.Fatalf("expect only passing server selected, got:%v", s.URL)
+		}
+	}
+	if b.GetState() != Passing {
+		t.Fatal("expect alive server passing")
+	}
+
+	atomic.StoreInt32(&healthy, 1)
+	waitFor(t, func() bool { return a.GetState() == Passing })
+}
+
+func TestRoundRobin_Close(t *testing.T) {
+	var probes int32
+	probe := func(server *Server, timeout time.Duration) error {
+		atomic.AddInt32(&probes, 1)
+		return nil
+	}
+	before := runtime.NumGoroutine()
+	lb := NewRoundRobinWithHealthCheck([]*Server{
+		newTestServer(t, "http://127.0.0.1:1", 1),
+		newTestServer(t, "http://127.0.0.1:2", 1),
+	}, &HealthCheckOptions{Interval: 5 * time.Millisecond, Probe: probe}).(*RoundRobin)
+	waitFor(t, func() bool { return atomic.LoadInt32(&probes) > 0 })
+	_ = lb.Close()
+	_ = lb.Close()
+	waitFor(t, func() bool { return runtime.NumGoroutine() <= before })
+	//其他测试遗留的goroutine可能让上面的条件提前满足,等待Close之前已经开始的探测结束
+	time.Sleep(20 * time.Millisecond)
+	n := atomic.LoadInt32(&probes)
+	time.Sleep(30 * time.Millisecond)
+	if atomic.LoadInt32(&probes) != n {
+		t.Fatal("expect probes stopped after Close")
+	}
+	//Close之后刷新server不会再启动检查
+	lb.RefreshServers([]*Server{newTestServer(t, "http://127.0.0.1:3", 1)})
+	time.Sleep(30 * time.Millisecond)
+	if atomic.LoadInt32(&probes) != n {
+		t.Fatal("expect no probes after Close")
+	}
+}
+
+func TestRoundRobin_Weights(t *testing.T) {
+	servers := []*Server{
+		newTestServer(t, "http://127.0.0.1:1", 30),
+		newTestServer(t, "http://127.0.0.1:2", 20),
+		newTestServer(t, "http://127.0.0.1:3", 10),
+	}
+	lb := NewRoundRobin(servers, false).(*RoundRobin)
+	if lb.GcdWeight != 10 {
+		t.Fatalf("expect gcd 10, got:%d", lb.GcdWeight)
+	}
+	counts := make(map[*Server]int)
+	for i := 0; i < 60; i++ {
+		counts[lb.SelectOne()]++
+	}
+	if counts[servers[0]] != 30 || counts[servers[1]] != 20 || counts[servers[2]] != 10 {
+		t.Fatalf("unexpected weighted distribution:%v,%v,%v", counts[servers[0]], counts[servers[1]], counts[servers[2]])
+	}
+}
+
+func TestRoundRobin_AllCritical(t *testing.T) {
+	servers := []*Server{
+		newTestServer(t, "http://127.0.0.1:1", 1),
+		newTestServer(t, "http://127.0.0.1:2", 1),
+	}
+	log := &warnLogger{}
+	lb := NewRoundRobinWithHealthCheck(servers, &HealthCheckOptions{
+		Interval: 5 * time.Millisecond,
+		Probe: func(server *Server, timeout time.Duration) error {
+			return errors.New("down")
+		},
+		Logger: log,
+	}).(*RoundRobin)
+	defer lb.Close()
+	waitFor(t, func() bool { return servers[0].GetState() == Critical && servers[1].GetState() == Critical })
+	selected := make(map[*Server]bool)
+	for i := 0; i < 4; i++ {
+		s := lb.SelectOne()
+		if s == nil {
+			t.Fatal("expect fallback server when all servers critical")
+		}
+		selected[s] = true
+	}
+	if len(selected) != 2 {
+		t.Fatalf("expect fallback rotates all servers, got:%d", len(selected))
+	}
+	//只在降级开始的时候输出一次日志
+	if n := log.count(); n != 1 {
+		t.Fatalf("expect fallback warned once, got:%d", n)
+	}
+}
+
+//warnLogger 记录Warn日志的次数
+type warnLogger struct {
+	logger.Nop
+
+	warns int32
+}
+
+func (l *warnLogger) Warn(msg string, fields ...logger.Field) {
+	atomic.AddInt32(&l.warns, 1)
+}
+
+func (l *warnLogger) count() int32 {
+	return atomic.LoadInt32(&l.warns)
+}
+
+func TestRoundRobin_ConcurrentRefresh(t *testing.T) {
+	var flip int32
+	probe := func(server *Server, timeout time.Duration) error {
+		if atomic.AddInt32(&flip, 1)%2 == 0 {
+			return errors.New("down")
+		}
+		return nil
+	}
+	lb := NewRoundRobinWithHealthCheck([]*Server{newTestServer(t, "http://127.0.0.1:1", 10)},
+		&HealthCheckOptions{Interval: time.Millisecond, Probe: probe}).(*RoundRobin)
+	defer lb.Close()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				lb.RefreshServers([]*Server{
+					newTestServer(t, "http://127.0.0.1:1", 10+i),
+					newTestServer(t, "http://127.0.0.1:2", 20+j),
+				})
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				if s := lb.SelectOne(); s != nil {
+					_ = s.GetState()
+				}
+				lb.MarkSuspect(lb.GetServers()[0])
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRefreshServers_Notify(t *testing.T) {
+	a, b := newTestServer(t, "http://127.0.0.1:1", 1), newTestServer(t, "http://127.0.0.1:2", 1)
+	for _, lb := range []LB{NewDirectProxy([]*Server{a}), NewRoundRobin([]*Server{a}, false), NewEWMA([]*Server{a}, nil)} {
+		var events []*ServerChangeEvent
+		lb.(Observable).OnServersChange(func(event *ServerChangeEvent) {
+			events = append(events, event)
+		})
+		lb.RefreshServers([]*Server{newTestServer(t, "http://127.0.0.1:1", 1)})
+		if len(events) != 0 || lb.GetServers()[0] != a {
+			t.Fatal("expect same address kept without event")
+		}
+		lb.RefreshServers([]*Server{b})
+		if len(events) != 1 || events[0].Added[0] != b || events[0].Removed[0] != a {
+			t.Fatalf("expect change event, got:%d", len(events))
+		}
+	}
+}
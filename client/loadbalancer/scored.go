@@ -0,0 +1,148 @@
+package loadbalancer
+
+import (
+	"github.com/celeskyking/go-nacos/pkg/logger"
+	"sync"
+)
+
+//scoreFunc 计算server的分数,分数越低越优先
+type scoreFunc func(server *Server) float64
+
+//ewmaScore 延迟乘以正在进行的请求数,优先选择最近的server,最近的server负载高的时候分散到其他的server
+func ewmaScore(server *Server) float64 {
+	return float64(server.Latency()) * float64(server.Outstanding()+1)
+}
+
+func leastOutstandingScore(server *Server) float64 {
+	return float64(server.Outstanding())
+}
+
+//NewEWMA 按照请求延迟的指数加权移动平均选择server,延迟由请求的耗时和健康检查的耗时更新。
+//options为nil的时候不开启健康检查,只依靠请求的耗时
+func NewEWMA(servers []*Server, options *HealthCheckOptions) LB {
+	return newScored(servers, options, ewmaScore)
+}
+
+//NewLeastOutstanding 选择正在进行的请求最少的server,options为nil的时候不开启健康检查
+func NewLeastOutstanding(servers []*Server, options *HealthCheckOptions) LB {
+	return newScored(servers, options, leastOutstandingScore)
+}
+
+func newScored(servers []*Server, options *HealthCheckOptions, score scoreFunc) *scored {
+	s := &scored{
+		servers: servers,
+		score:   score,
+		log:     optionsLogger(options),
+	}
+	if options != nil {
+		s.checks = newCheckGroup(options, func() {})
+		s.checks.restart(servers)
+	}
+	return s
+}
+
+//scored 选择分数最低的健康server,分数相同的时候轮流选择
+type scored struct {
+	servers []*Server
+
+	score scoreFunc
+
+	lock sync.Mutex
+	//分数相同的时候开始比较的位置
+	offset int
+
+	checks *checkGroup
+
+	closeOnce sync.Once
+
+	log logger.Logger
+
+	fallback fallbackState
+
+	listeners
+}
+
+func (s *scored) SelectOne() *Server {
+	return s.SelectOneExcept(nil)
+}
+
+//SelectOneExcept 选择不在excluded中的分数最低的server,健康的server都被排除的时候降级选择可疑和不健康的server
+func (s *scored) SelectOneExcept(excluded []*Server) *Server {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if server := s.selectBest(excluded, true); server != nil {
+		s.fallback.set(s.log, false)
+		return server
+	}
+	if server := s.selectBest(excluded, false); server != nil {
+		//健康的server只是被重试排除的时候不算降级
+		if s.selectBest(nil, true) == nil {
+			s.fallback.set(s.log, true)
+		}
+		return server
+	}
+	return nil
+}
+
+func (s *scored) selectBest(excluded []*Server, healthyOnly bool) *Server {
+	n := len(s.servers)
+	if n == 0 {
+		return nil
+	}
+	s.offset = (s.offset + 1) % n
+	var best *Server
+	var bestScore float64
+	for i := 0; i < n; i++ {
+		server := s.servers[(s.offset+i)%n]
+		if containsServer(excluded, server) {
+			continue
+		}
+		if healthyOnly && (server.GetState() != Passing || server.IsSuspect()) {
+			continue
+		}
+		if score := s.score(server); best == nil || score < bestScore {
+			best, bestScore = server, score
+		}
+	}
+	return best
+}
+
+func (s *scored) MarkSuspect(server *Server) {
+	server.markSuspect()
+}
+
+func (s *scored) GetServers() []*Server {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.servers
+}
+
+//RefreshServers 替换server列表,地址相同的server保留原来的延迟和健康状态,列表变化的时候通知监听器
+func (s *scored) RefreshServers(servers []*Server) {
+	if len(servers) == 0 {
+		s.log.Error("no working servers")
+	}
+	s.lock.Lock()
+	merged, event := mergeServers(s.servers, servers)
+	if event == nil {
+		s.lock.Unlock()
+		return
+	}
+	s.servers = merged
+	s.lock.Unlock()
+	if s.checks != nil {
+		s.checks.restart(merged)
+	}
+	logServerChange(s.log, event)
+	s.notify(event)
+}
+
+//Close 停止健康检查,可以重复调用
+func (s *scored) Close() error {
+	s.closeOnce.Do(func() {
+		if s.checks != nil {
+			s.checks.close()
+		}
+	})
+	return nil
+}
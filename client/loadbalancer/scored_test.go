@@ -0,0 +1,98 @@
+package loadbalancer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEWMA_PrefersLowLatency(t *testing.T) {
+	near, far := newTestServer(t, "http://127.0.0.1:1", 1), newTestServer(t, "http://127.0.0.1:2", 1)
+	lb := NewEWMA([]*Server{near, far}, nil)
+	for i := 0; i < 5; i++ {
+		near.Begin()
+		near.End(2*time.Millisecond, true)
+		far.Begin()
+		far.End(40*time.Millisecond, true)
+	}
+	for i := 0; i < 10; i++ {
+		if s := lb.SelectOne(); s != near {
+			t.Fatalf("expect near server, got:%s", s.URL.Host)
+		}
+	}
+	//near的请求堆积之后分散到far
+	for i := 0; i < 30; i++ {
+		near.Begin()
+	}
+	if s := lb.SelectOne(); s != far {
+		t.Fatalf("expect far server when near overloaded, got:%s", s.URL.Host)
+	}
+	for i := 0; i < 30; i++ {
+		near.End(0, false)
+	}
+	//near变慢之后切换到far
+	for i := 0; i < 10; i++ {
+		near.Begin()
+		near.End(200*time.Millisecond, true)
+	}
+	if s := lb.SelectOne(); s != far {
+		t.Fatalf("expect far server after near slowed down, got:%s", s.URL.Host)
+	}
+}
+
+func TestEWMA_SkipsUnhealthy(t *testing.T) {
+	near, far := newTestServer(t, "http://127.0.0.1:1", 1), newTestServer(t, "http://127.0.0.1:2", 1)
+	near.End(time.Millisecond, true)
+	far.End(50*time.Millisecond, true)
+	lb := NewEWMA([]*Server{near, far}, nil).(FailoverLB)
+	lb.MarkSuspect(near)
+	if s := lb.SelectOne(); s != far {
+		t.Fatalf("expect suspect server skipped, got:%s", s.URL.Host)
+	}
+	if s := lb.SelectOneExcept([]*Server{far}); s != near {
+		t.Fatal("expect fallback to suspect server")
+	}
+	if s := lb.SelectOneExcept([]*Server{near, far}); s != nil {
+		t.Fatal("expect nil when all servers excluded")
+	}
+}
+
+func TestLeastOutstanding(t *testing.T) {
+	servers := []*Server{
+		newTestServer(t, "http://127.0.0.1:1", 1),
+		newTestServer(t, "http://127.0.0.1:2", 1),
+		newTestServer(t, "http://127.0.0.1:3", 1),
+	}
+	lb := NewLeastOutstanding(servers, nil)
+	//没有请求的时候轮流选择
+	selected := make(map[*Server]bool)
+	for i := 0; i < 3; i++ {
+		selected[lb.SelectOne()] = true
+	}
+	if len(selected) != 3 {
+		t.Fatalf("expect ties spread across servers, got:%d", len(selected))
+	}
+	//每次选择之后开始请求,请求数保持均衡
+	for i := 0; i < 30; i++ {
+		lb.SelectOne().Begin()
+	}
+	for _, s := range servers {
+		if s.Outstanding() != 10 {
+			t.Fatalf("expect balanced outstanding requests, got:%d", s.Outstanding())
+		}
+	}
+	servers[1].End(0, false)
+	if s := lb.SelectOne(); s != servers[1] {
+		t.Fatalf("expect server with least outstanding requests, got:%s", s.URL.Host)
+	}
+}
+
+func TestScored_RefreshKeepsStats(t *testing.T) {
+	a := newTestServer(t, "http://127.0.0.1:1", 1)
+	a.End(10*time.Millisecond, true)
+	lb := NewEWMA([]*Server{a}, nil)
+	lb.RefreshServers([]*Server{newTestServer(t, "http://127.0.0.1:1", 1), newTestServer(t, "http://127.0.0.1:2", 1)})
+	servers := lb.GetServers()
+	if len(servers) != 2 || servers[0] != a || a.Latency() != 10*time.Millisecond {
+		t.Fatal("expect existing server and latency kept after refresh")
+	}
+}
@@ -39,12 +39,12 @@ func GetConverter(name string) FileConverter {
 }
 
 type FileConverter interface {
-	//转换器
-	Convert(desc *types.FileDesc, content []byte) cs.FileMirror
+	//转换器,内容不合法的时候返回错误
+	Convert(desc *types.FileDesc, content []byte) (cs.FileMirror, error)
 }
 
-type FileConverterFunc func(desc *types.FileDesc, content []byte) cs.FileMirror
+type FileConverterFunc func(desc *types.FileDesc, content []byte) (cs.FileMirror, error)
 
-func (f FileConverterFunc) Convert(desc *types.FileDesc, content []byte) cs.FileMirror {
+func (f FileConverterFunc) Convert(desc *types.FileDesc, content []byte) (cs.FileMirror, error) {
 	return f(desc, content)
 }
@@ -2,24 +2,27 @@ package loader
 
 import (
 	"github.com/celeskyking/go-nacos/err"
+	"github.com/celeskyking/go-nacos/pkg/logger"
 	"github.com/celeskyking/go-nacos/pkg/util"
 	"github.com/celeskyking/go-nacos/types"
 	"github.com/pkg/errors"
-	"github.com/sirupsen/logrus"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 )
 
+//ErrSnapshotDisabled 没有指定快照目录的时候不读写快照
+var ErrSnapshotDisabled = errors.New("快照目录为空,不读写快照")
+
 type LocalLoader struct {
-	//快照的目录
+	//快照的目录,为空的时候不读写快照
 	SnapshotDir string
+	//输出日志使用的Logger,为空的时候使用全局的Logger
+	Logger logger.Logger
 }
 
+//NewLocalLoader 创建读写本地快照的loader,snapshotDir为空的时候Load和Write都返回错误
 func NewLocalLoader(snapshotDir string) Loader {
-	if snapshotDir == "" {
-		panic(errors.New("快照目录不能够为空"))
-	}
 	return &LocalLoader{
 		SnapshotDir: snapshotDir,
 	}
@@ -27,6 +30,9 @@ func NewLocalLoader(snapshotDir string) Loader {
 
 //加载
 func (ll *LocalLoader) Load(desc *types.FileDesc) ([]byte, error) {
+	if ll.SnapshotDir == "" {
+		return nil, ErrSnapshotDisabled
+	}
 	parts := []string{ll.SnapshotDir, desc.Namespace, desc.Group, desc.Name}
 	p := filepath.Join(parts...)
 	if r, er := util.PathExists(p); er != nil {
@@ -39,7 +45,7 @@ func (ll *LocalLoader) Load(desc *types.FileDesc) ([]byte, error) {
 			}
 			return data, nil
 		} else {
-			logrus.Errorf("file not found:%s", p)
+			logger.With(ll.Logger).Error("file not found", logger.F("path", p))
 			return nil, err.ErrFileNotFound
 		}
 	}
@@ -47,6 +53,9 @@ func (ll *LocalLoader) Load(desc *types.FileDesc) ([]byte, error) {
 
 //向文件中写入内容
 func (ll *LocalLoader) Write(desc *types.FileDesc, content []byte) error {
+	if ll.SnapshotDir == "" {
+		return ErrSnapshotDisabled
+	}
 	parts := []string{ll.SnapshotDir, desc.Namespace, desc.Group}
 	p := filepath.Join(parts...)
 	if e, er := util.PathExists(p); er != nil {
@@ -57,6 +66,6 @@ func (ll *LocalLoader) Write(desc *types.FileDesc, content []byte) error {
 			return errors.Wrap(er, "create snapshot dir")
 		}
 	}
-	logrus.Infof("flush config file to %s", p)
+	logger.With(ll.Logger).Info("flush config file", logger.F("path", p), logger.F("file", desc.Name))
 	return ioutil.WriteFile(filepath.Join(p, desc.Name), content, 0666)
 }
@@ -10,22 +10,20 @@ import (
 	"github.com/celeskyking/go-nacos/config/converter"
 	"github.com/celeskyking/go-nacos/config/listener"
 	"github.com/celeskyking/go-nacos/err"
+	"github.com/celeskyking/go-nacos/pkg/logger"
 	"github.com/celeskyking/go-nacos/pkg/pool"
 	"github.com/celeskyking/go-nacos/types"
-	"github.com/sirupsen/logrus"
-	"os"
 	"strconv"
 	"strings"
 )
 
 func init() {
-	converter.RegisterConverter("properties", func(desc *types.FileDesc, content []byte) cs.FileMirror {
+	converter.RegisterConverter("properties", func(desc *types.FileDesc, content []byte) (cs.FileMirror, error) {
 		f, er := NewMapFile(desc, content)
 		if er != nil {
-			logrus.Errorf("load map file converter failed:%+v", er)
-			os.Exit(1)
+			return nil, er
 		}
-		return f
+		return f, nil
 	})
 }
 
@@ -81,6 +79,7 @@ func diffParam(file *MapFile, oldValue, newValue map[string]string) {
 	changes = append(changes, diffAdded(oldValue, newValue)...)
 	for _, c := range changes {
 		if v, ok := file.valueListeners[c.Key]; ok {
+			c := c
 			pool.Go(func(context context.Context) {
 				v.OnChange(c.Key, c.OldValue, c.NewValue, file.Desc())
 			})
@@ -93,6 +92,7 @@ func diffFile(file *MapFile, oldContent, newContent []byte) {
 		return
 	}
 	for _, l := range file.fileListeners {
+		l := l
 		pool.Go(func(i context.Context) {
 			l.OnChange(oldContent, newContent, file.Desc())
 		})
@@ -212,13 +212,21 @@ type MapFile struct {
 
 	//是否是初始化
 	Init bool
+
+	log logger.Logger
+}
+
+//SetLogger 设置输出日志使用的Logger,为nil的时候使用全局的Logger,需要在OnChanged之前调用
+func (m *MapFile) SetLogger(l logger.Logger) {
+	m.log = l
 }
 
 func (m *MapFile) OnChanged(notifyC <-chan []byte) {
+	log := logger.With(m.log)
 	for data := range notifyC {
 		er := refresh(m, data)
 		if er != nil {
-			logrus.Errorf("接受nacos 配置文件更新失败,error:%+v", er)
+			log.Error("接受nacos 配置文件更新失败", logger.F("file", m.desc.Name), logger.Err(er))
 		}
 	}
 }
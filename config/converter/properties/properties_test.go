@@ -9,11 +9,15 @@ import (
 
 func TestMapFile_Desc(t *testing.T) {
 	c := converter.GetConverter("properties")
-	mapfile := c.Convert(&types.FileDesc{
+	f, er := c.Convert(&types.FileDesc{
 		Name:      "demo.properties",
 		Group:     "app1",
 		Namespace: "demo",
-	}, []byte("name=tianqing.wang")).(*MapFile)
+	}, []byte("name=tianqing.wang"))
+	if er != nil {
+		t.Fatal(er)
+	}
+	mapfile := f.(*MapFile)
 	fmt.Println(mapfile.MD5())
 	fmt.Println(mapfile.MustGet("name"))
 }
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"errors"
+	"github.com/celeskyking/go-nacos/api"
+	"github.com/celeskyking/go-nacos/err"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewConfigServiceE(t *testing.T) {
+	_, er := NewConfigServiceE(&api.ConfigOptions{ServerOptions: &api.ServerOptions{}})
+	if !errors.Is(er, err.ErrInvalidOptions) || !errors.Is(er, api.ErrSnapshotDirIsEmpty) {
+		t.Fatalf("expect validation error, got:%v", er)
+	}
+	//不合法的配置不会退出进程,获取配置的时候返回错误
+	c := NewConfigService(&api.ConfigOptions{ServerOptions: &api.ServerOptions{}})
+	defer c.HttpClient().Stop()
+	if _, er := c.Properties(DefaultGroup, "demo.properties"); er == nil {
+		t.Fatal("expect error without nacos server")
+	}
+}
+
+func TestConfigService_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/nacos/v1/cs/configs/listener" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte("not a properties file"))
+	}))
+	defer server.Close()
+	errC := make(chan error, 10)
+	c, er := NewConfigServiceE(&api.ConfigOptions{
+		ServerOptions: &api.ServerOptions{Addresses: []string{server.URL}},
+		SnapshotDir:   t.TempDir(),
+		OnError: func(er error) {
+			errC <- er
+		},
+	})
+	if er != nil {
+		t.Fatal(er)
+	}
+	defer c.HttpClient().Stop()
+	if _, er := c.Properties(DefaultGroup, "demo.properties"); !errors.Is(er, err.ErrNotPropertiesFile) {
+		t.Fatalf("expect converter error returned, got:%v", er)
+	}
+
+	s := c.(*configService)
+	s.fileNotifier[buildFileKey("", DefaultGroup, "demo.properties")] = make(chan []byte, 1)
+	s.Watch()
+	defer s.StopWatch()
+	select {
+	case er := <-errC:
+		if !errors.Is(er, err.ErrForbidden) {
+			t.Fatalf("expect forbidden error reported, got:%v", er)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expect listen error reported")
+	}
+}
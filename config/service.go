@@ -9,11 +9,12 @@ import (
 	"github.com/celeskyking/go-nacos/config/converter/loader"
 	"github.com/celeskyking/go-nacos/config/converter/properties"
 	"github.com/celeskyking/go-nacos/err"
+	"github.com/celeskyking/go-nacos/pkg/logger"
 	"github.com/celeskyking/go-nacos/pkg/pool"
+	"github.com/celeskyking/go-nacos/pkg/stats"
 	"github.com/celeskyking/go-nacos/pkg/util"
 	"github.com/celeskyking/go-nacos/types"
-	"github.com/sirupsen/logrus"
-	"os"
+	"github.com/pkg/errors"
 	"sync"
 	"time"
 )
@@ -35,13 +36,59 @@ type ConfigService interface {
 	HttpClient() v1.ConfigHttpClient
 }
 
+//NewConfigService 创建配置中心的服务,配置不合法的时候只输出错误日志,没有可用的nacos server的时候获取配置返回错误,
+//没有指定SnapshotDir的时候不读写本地快照。推荐使用NewConfigServiceE
 func NewConfigService(options *api.ConfigOptions) ConfigService {
-	httpOption := api.DefaultOption()
-	httpOption.Servers = options.Addresses
-	httpOption.LBStrategy = options.LBStrategy
-	httpClient := v1.NewConfigHttpClient(httpOption)
+	o := httpOption(options)
+	if er := options.Validate(); er != nil {
+		logger.With(o.Logger).Error("config options not valid", logger.Err(er))
+	}
+	return newConfigService(options, v1.NewConfigHttpClient(o))
+}
+
+//NewConfigServiceE 创建配置中心的服务,配置不合法或者没有可用的nacos server的时候返回错误
+func NewConfigServiceE(options *api.ConfigOptions) (ConfigService, error) {
+	o := httpOption(options)
+	if er := options.Validate(); er != nil {
+		return nil, er
+	}
+	httpClient, er := v1.NewConfigHttpClientE(o)
+	if er != nil {
+		return nil, er
+	}
+	return newConfigService(options, httpClient), nil
+}
+
+//httpOption 转换为http客户端的配置
+func httpOption(options *api.ConfigOptions) *api.HttpConfigOption {
+	o := api.DefaultOption()
+	if options.ServerOptions == nil {
+		return o
+	}
+	o.Logger = options.Logger
+	o.Recorder = options.Recorder
+	o.Servers = options.Addresses
+	o.LBStrategy = options.LBStrategy
+	o.RetryPolicy = options.RetryPolicy
+	o.HealthCheck = options.HealthCheck
+	o.Endpoint = options.Endpoint
+	o.EndpointEnabled = options.EndpointEnabled
+	o.EndpointOptions = options.EndpointOptions
+	o.ServerProvider = options.ServerProvider
+	return o
+}
+
+func newConfigService(options *api.ConfigOptions, httpClient v1.ConfigHttpClient) *configService {
+	var namespaceID string
+	var l logger.Logger
+	var r stats.Recorder
+	if options.ServerOptions != nil {
+		namespaceID = options.NamespaceID
+		l = options.Logger
+		r = options.Recorder
+	}
 	var loaders []loader.Loader
-	localLoader := loader.NewLocalLoader(options.SnapshotDir)
+	localLoader := &loader.LocalLoader{SnapshotDir: options.SnapshotDir, Logger: l}
 	loaders = append(loaders, loader.NewRemoteLoader(httpClient))
 	loaders = append(loaders, localLoader)
 	return &configService{
@@ -51,8 +98,11 @@ func NewConfigService(options *api.ConfigOptions) ConfigService {
 		SnapshotDir:    options.SnapshotDir,
 		loaders:        loaders,
 		httpClient:     httpClient,
-		NameSpaceID:    options.NamespaceID,
-		snapshotWriter: localLoader.(loader.SnapshotWriter),
+		NameSpaceID:    namespaceID,
+		snapshotWriter: localLoader,
+		onError:        options.OnError,
+		log:            logger.With(l),
+		rec:            stats.With(r),
 	}
 }
 
@@ -79,6 +129,12 @@ type configService struct {
 	watched bool
 
 	NameSpaceID string
+	//后台失败的回调
+	onError func(er error)
+
+	log logger.Logger
+
+	rec stats.Recorder
 }
 
 func (c *configService) Properties(group, file string) (*properties.MapFile, error) {
@@ -104,19 +160,17 @@ func (c *configService) getFile(group, file string) ([]byte, error) {
 		if er == nil {
 			switch l.(type) {
 			case *loader.LocalLoader:
-				logrus.Infof("loader: localLoader")
+				c.log.Info("load config from snapshot", logger.F("group", group), logger.F("dataId", file))
+				c.rec.SnapshotFallback(group, file)
 			case *loader.RemoteLoader:
-				logrus.Info("loader: remoteLoader")
+				c.log.Info("load config from nacos server", logger.F("group", group), logger.F("dataId", file))
 				pool.Go(func(ctx context.Context) {
-					er = c.snapshotWriter.Write(desc, data)
-					if er != nil {
-						logrus.Errorf("flush snapshot error:%+v", er)
-					}
+					c.flushSnapshot(desc, data)
 				})
 			}
 			return data, nil
 		} else {
-			logrus.Errorf("file not found, file:%+v", desc)
+			c.log.Error("file not found", logger.F("namespace", desc.Namespace), logger.F("group", group), logger.F("dataId", file), logger.Err(er))
 			continue
 		}
 	}
@@ -132,11 +186,14 @@ func (c *configService) Custom(group, file string, converter converter.FileConve
 	if er != nil {
 		return nil, er
 	}
-	f := converter.Convert(&types.FileDesc{
+	f, er := converter.Convert(&types.FileDesc{
 		Namespace: c.NameSpaceID,
 		Group:     g,
 		Name:      file,
 	}, bs)
+	if er != nil {
+		return nil, errors.Wrapf(er, "convert config file, group:%s, dataId:%s", g, file)
+	}
 	m := util.MD5(bs)
 	k := buildFileKey(c.NameSpaceID, g, file)
 	if _, ok := c.fileNotifier[file]; !ok {
@@ -144,6 +201,9 @@ func (c *configService) Custom(group, file string, converter converter.FileConve
 		c.fileNotifier[k] = make(chan []byte, 100)
 
 	}
+	if s, ok := f.(cs.LoggerSetter); ok {
+		s.SetLogger(c.log)
+	}
 	go f.OnChanged(c.fileNotifier[file])
 	c.fileVersion[k] = m
 	c.lock.Lock()
@@ -166,8 +226,10 @@ func (c *configService) Watch() {
 		for c.status {
 			list, er := c.listenKeys()
 			if er != nil {
-				logrus.Errorf("listen nacos file error:%+v", er)
-				os.Exit(1)
+				c.reportError(errors.Wrap(er, "listen nacos file"))
+				reties = reties + 1
+				time.Sleep(time.Duration(util.Min(reties*5, maxDelay)) * time.Second)
+				continue
 			}
 			if len(list) == 0 {
 				time.Sleep(5 * time.Second)
@@ -176,15 +238,16 @@ func (c *configService) Watch() {
 				ListeningConfigs: list,
 			})
 			if er != nil {
-				logrus.Errorf("listen to nacos error:%+v", er)
+				c.reportError(errors.Wrap(er, "listen to nacos"))
 				reties = reties + 1
 				time.Sleep(time.Duration(util.Min(reties*5, maxDelay)) * time.Second)
-				return
+				continue
 			}
 			for _, change := range changes {
 				k := change.Key
 				v := change.NewValue
 				if v != "" {
+					c.rec.ConfigChanged(k.Group, k.DataID)
 					k.ContentMD5 = ""
 					vb := []byte(v)
 					desc := &types.FileDesc{
@@ -196,11 +259,14 @@ func (c *configService) Watch() {
 						c.flushSnapshot(desc, vb)
 					})
 					if notifyC, ok := c.fileNotifier[k.Line()]; ok {
+						_, span := api.StartSpan(context.Background(), "nacos config change",
+							api.AttrDataID.String(k.DataID), api.AttrGroup.String(k.Group), api.AttrTenant.String(k.Tenant))
 						tmp := make([]byte, len(vb))
 						copy(tmp, vb)
 						m := util.MD5(tmp)
 						c.fileVersion[k.Line()] = m
 						notifyC <- vb
+						span.End()
 					}
 				}
 			}
@@ -210,9 +276,20 @@ func (c *configService) Watch() {
 }
 
 func (c *configService) flushSnapshot(desc *types.FileDesc, content []byte) {
+	if c.SnapshotDir == "" {
+		return
+	}
 	er := c.snapshotWriter.Write(desc, content)
 	if er != nil {
-		logrus.Errorf("snapshot flush content error:%+v", er)
+		c.reportError(errors.Wrapf(er, "flush snapshot, group:%s, dataId:%s", desc.Group, desc.Name))
+	}
+}
+
+//reportError 输出日志并回调OnError
+func (c *configService) reportError(er error) {
+	c.log.Error("config service error", logger.Err(er))
+	if c.onError != nil {
+		c.onError(er)
 	}
 }
 
@@ -3,13 +3,14 @@ package err
 import (
 	"fmt"
 	"github.com/pkg/errors"
+	"strings"
 )
 
 var ErrBadRequest = errors.New("客户端请求中的语法错误")
 
 var ErrForbidden = errors.New("没有权限")
 
-var ErrNotFound = errors.New("无法找到配置文件资源")
+var ErrNotFound = errors.New("资源不存在")
 
 var ErrInternalServerError = errors.New("服务器内部错误")
 
@@ -27,10 +28,19 @@ var ErrLoaderNotWork = errors.New("loader not work")
 
 var ErrNamingService = errors.New("register service error")
 
+var ErrSelectorNotValid = errors.New("selector表达式不合法")
+
+var ErrHealthCheckerNotValid = errors.New("health checker参数不合法")
+
+//HttpClientError 请求失败的错误
+//
+//Deprecated: 客户端已经使用NacosError,保留该类型只是为了兼容
 type HttpClientError struct {
 	Errors []error
 
 	Message string
+	//服务端返回的状态码,没有收到响应的时候为0
+	StatusCode int
 }
 
 func NewHttpClientError(message string, errors ...error) *HttpClientError {
@@ -43,3 +53,37 @@ func NewHttpClientError(message string, errors ...error) *HttpClientError {
 func (h *HttpClientError) Error() string {
 	return fmt.Sprintf("http client apply failed, message:%s, errors:%+v", h.Message, h.Errors)
 }
+
+var ErrInvalidOptions = errors.New("配置不合法")
+
+//ValidationError 配置校验失败的所有原因,可以通过errors.Is和ErrInvalidOptions以及每个原因比较
+type ValidationError struct {
+	Errors []error
+}
+
+//Add 添加一个校验失败的原因
+func (v *ValidationError) Add(er error) {
+	if er != nil {
+		v.Errors = append(v.Errors, er)
+	}
+}
+
+//Err 没有校验失败的原因的时候返回nil
+func (v *ValidationError) Err() error {
+	if len(v.Errors) == 0 {
+		return nil
+	}
+	return v
+}
+
+func (v *ValidationError) Error() string {
+	messages := make([]string, 0, len(v.Errors))
+	for _, er := range v.Errors {
+		messages = append(messages, er.Error())
+	}
+	return fmt.Sprintf("%s: %s", ErrInvalidOptions.Error(), strings.Join(messages, "; "))
+}
+
+func (v *ValidationError) Unwrap() []error {
+	return append([]error{ErrInvalidOptions}, v.Errors...)
+}
@@ -0,0 +1,188 @@
+package err
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var ErrUnauthorized = errors.New("未认证")
+
+var ErrConflict = errors.New("资源冲突")
+
+var ErrThrottled = errors.New("请求过于频繁,被服务端限流")
+
+var ErrUnavailable = errors.New("nacos server不可用")
+
+//maxMessageLength 非json的响应体作为错误信息的时候截断的长度
+const maxMessageLength = 512
+
+//NacosError 请求nacos server失败的详细信息,可以通过errors.Is和状态码对应的错误(例如ErrNotFound,ErrThrottled)比较,
+//也可以和连接失败的错误比较,通过errors.As获取详细信息
+type NacosError struct {
+	//HTTP状态码,没有收到响应的时候为0
+	StatusCode int
+	//nacos返回的错误码,响应体中没有错误码的时候为0
+	Code int
+	//nacos返回的错误信息
+	Message string
+	//请求的nacos server的地址
+	Server string
+	//请求的RequestId,服务端没有返回的时候使用请求头中的RequestId
+	RequestID string
+	//是否可以重试,连接失败,限流以及502,503,504的时候为true
+	Retryable bool
+	//状态码对应的错误,参考StatusError
+	Cause error
+	//发送请求或者读取响应的时候的错误
+	Errors []error
+}
+
+//NewNacosError 根据响应创建错误,resp为nil的时候表示没有收到响应,body为已经读取的响应体
+func NewNacosError(resp *http.Response, body []byte, errs ...error) *NacosError {
+	e := &NacosError{Errors: errs}
+	if resp == nil {
+		e.Server = errorServer(errs)
+		e.Retryable = len(errs) > 0
+		return e
+	}
+	e.StatusCode = resp.StatusCode
+	e.Cause = StatusError(resp.StatusCode)
+	e.Retryable = RetryableStatus(resp.StatusCode)
+	e.RequestID = resp.Header.Get("RequestId")
+	if resp.Request != nil {
+		if resp.Request.URL != nil {
+			e.Server = resp.Request.URL.Host
+		}
+		if e.RequestID == "" {
+			e.RequestID = resp.Request.Header.Get("RequestId")
+		}
+	}
+	e.Code, e.Message = parseBody(body)
+	return e
+}
+
+//StatusError 返回状态码对应的错误,200的时候为nil
+func StatusError(code int) error {
+	switch code {
+	case http.StatusOK:
+		return nil
+	case http.StatusBadRequest:
+		return ErrBadRequest
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusTooManyRequests:
+		return ErrThrottled
+	case http.StatusInternalServerError:
+		return ErrInternalServerError
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return ErrUnavailable
+	default:
+		return ErrUnSupportStatusCode
+	}
+}
+
+//RetryableStatus 状态码是否可以换一个server重试
+func RetryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+//IsRetryable er是否为可以重试的NacosError
+func IsRetryable(er error) bool {
+	var e *NacosError
+	return errors.As(er, &e) && e.Retryable
+}
+
+func (e *NacosError) Error() string {
+	var b strings.Builder
+	b.WriteString("nacos request failed")
+	if e.Server != "" {
+		fmt.Fprintf(&b, ", server:%s", e.Server)
+	}
+	if e.StatusCode != 0 {
+		fmt.Fprintf(&b, ", status:%d", e.StatusCode)
+	}
+	if e.Code != 0 {
+		fmt.Fprintf(&b, ", code:%d", e.Code)
+	}
+	if e.Message != "" {
+		fmt.Fprintf(&b, ", message:%s", e.Message)
+	}
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, ", requestId:%s", e.RequestID)
+	}
+	if e.Cause != nil {
+		fmt.Fprintf(&b, ", cause:%s", e.Cause.Error())
+	}
+	if len(e.Errors) != 0 {
+		fmt.Fprintf(&b, ", errors:%v", e.Errors)
+	}
+	return b.String()
+}
+
+//Unwrap 返回状态码对应的错误以及请求的错误,供errors.Is和errors.As使用
+func (e *NacosError) Unwrap() []error {
+	var result []error
+	if e.Cause != nil {
+		result = append(result, e.Cause)
+	}
+	for _, er := range e.Errors {
+		if er != nil {
+			result = append(result, er)
+		}
+	}
+	return result
+}
+
+//parseBody 解析nacos的错误响应,json格式的响应体读取code和message,否则整个响应体作为错误信息
+func parseBody(body []byte) (int, string) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return 0, ""
+	}
+	if body[0] == '{' {
+		var r struct {
+			Code int `json:"code"`
+
+			Message string `json:"message"`
+
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &r) == nil {
+			if r.Message == "" {
+				r.Message = r.Error
+			}
+			return r.Code, r.Message
+		}
+	}
+	if len(body) > maxMessageLength {
+		return 0, string(body[:maxMessageLength]) + "..."
+	}
+	return 0, string(body)
+}
+
+func errorServer(errs []error) string {
+	for _, er := range errs {
+		var ue *url.Error
+		if errors.As(er, &ue) {
+			if u, e := url.Parse(ue.URL); e == nil {
+				return u.Host
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package err
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func response(code int, header http.Header) *http.Response {
+	req := &http.Request{URL: &url.URL{Scheme: "http", Host: "10.0.0.1:8848"}, Header: http.Header{"Requestid": {"req-1"}}}
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{StatusCode: code, Header: header, Request: req}
+}
+
+func TestNacosError_Is(t *testing.T) {
+	cases := map[int]error{
+		http.StatusBadRequest:         ErrBadRequest,
+		http.StatusForbidden:          ErrForbidden,
+		http.StatusNotFound:           ErrNotFound,
+		http.StatusConflict:           ErrConflict,
+		http.StatusTooManyRequests:    ErrThrottled,
+		http.StatusServiceUnavailable: ErrUnavailable,
+		http.StatusTeapot:             ErrUnSupportStatusCode,
+	}
+	for code, sentinel := range cases {
+		var er error = NewNacosError(response(code, nil), []byte("caused: boom"))
+		if !errors.Is(er, sentinel) {
+			t.Fatalf("expect status %d is %v, got:%v", code, sentinel, er)
+		}
+		if errors.Is(er, ErrInternalServerError) {
+			t.Fatalf("expect status %d not internal error", code)
+		}
+	}
+}
+
+func TestNewNacosError(t *testing.T) {
+	e := NewNacosError(response(http.StatusTooManyRequests, http.Header{"Requestid": {"req-2"}}), []byte(`{"code":22001,"message":"too many requests"}`))
+	if e.Code != 22001 || e.Message != "too many requests" || e.Server != "10.0.0.1:8848" || e.RequestID != "req-2" || !e.Retryable {
+		t.Fatalf("unexpected error:%+v", e)
+	}
+	if !IsRetryable(e) {
+		t.Fatal("expect throttled error retryable")
+	}
+	e = NewNacosError(response(http.StatusNotFound, nil), []byte("config data not exist\n"))
+	if e.Message != "config data not exist" || e.RequestID != "req-1" || e.Retryable {
+		t.Fatalf("unexpected error:%+v", e)
+	}
+
+	dial := errors.New("connection refused")
+	e = NewNacosError(nil, nil, &url.Error{Op: "Get", URL: "http://10.0.0.2:8848/nacos", Err: dial})
+	if e.StatusCode != 0 || e.Server != "10.0.0.2:8848" || !e.Retryable || !errors.Is(e, dial) {
+		t.Fatalf("unexpected transport error:%+v", e)
+	}
+	var target *NacosError
+	if !errors.As(errors.Join(errors.New("wrapped"), e), &target) || target != e {
+		t.Fatal("expect errors.As finds NacosError")
+	}
+}
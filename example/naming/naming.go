@@ -27,10 +27,10 @@ func main() {
 		ServiceName: "local-2",
 		IP:          "10.10.10.15",
 		Port:        8080,
-		Metadata: util.MapToString(map[string]string{
+		Metadata: map[string]string{
 			"name": "go-nacos",
 			"age":  "30",
-		}),
+		},
 		Weight:    1.0,
 		Healthy:   true,
 		Enable:    true,
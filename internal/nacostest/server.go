@@ -0,0 +1,375 @@
+//Package nacostest 提供一个内存版的nacos server,只实现了测试用到的naming接口
+package nacostest
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/celeskyking/go-nacos/types"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultGroup = "DEFAULT_GROUP"
+	//CodeOK 心跳成功
+	CodeOK = 10200
+	//CodeResourceNotFound 心跳的时候服务端找不到实例
+	CodeResourceNotFound = 20404
+)
+
+//Server 内存版的nacos server
+type Server struct {
+	*httptest.Server
+
+	lock sync.Mutex
+	//key为namespace@@group@@service
+	services map[string]map[string]*types.Host
+
+	protectThreshold map[string]float64
+	//实例列表的缓存时间
+	CacheMillis int
+	//返回给客户端的心跳间隔
+	BeatInterval int
+	//是否开启light beat
+	LightBeatEnabled bool
+
+	requests map[string]int
+
+	beats []url.Values
+
+	rejected map[string]bool
+	//查询服务的时候返回的状态码
+	serviceStatus map[string]int
+	//key为ServiceKey + "@@" + clusterName
+	clusters map[string]url.Values
+}
+
+//NewServer 启动一个nacos server,使用完之后需要调用Close
+func NewServer() *Server {
+	s := &Server{
+		services:         make(map[string]map[string]*types.Host),
+		protectThreshold: make(map[string]float64),
+		requests:         make(map[string]int),
+		rejected:         make(map[string]bool),
+		serviceStatus:    make(map[string]int),
+		clusters:         make(map[string]url.Values),
+		CacheMillis:      100,
+		BeatInterval:     100,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/nacos/v1/ns/instance/list", s.list)
+	mux.HandleFunc("/nacos/v1/ns/instance/beat", s.beat)
+	mux.HandleFunc("/nacos/v1/ns/instance", s.instance)
+	mux.HandleFunc("/nacos/v1/ns/health/instance", s.health)
+	mux.HandleFunc("/nacos/v1/ns/service", s.service)
+	mux.HandleFunc("/nacos/v1/ns/cluster", s.cluster)
+	mux.HandleFunc("/nacos/v1/ns/catalog/services", s.catalog)
+	mux.HandleFunc("/nacos/v1/console/health/liveness", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.lock.Lock()
+		s.requests[r.Method+" "+r.URL.Path]++
+		s.lock.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	return s
+}
+
+//Addr 返回server的地址,host:port
+func (s *Server) Addr() string {
+	return strings.TrimPrefix(s.URL, "http://")
+}
+
+func key(namespace, serviceName string) string {
+	if !strings.Contains(serviceName, "@@") {
+		serviceName = DefaultGroup + "@@" + serviceName
+	}
+	return namespace + "@@" + serviceName
+}
+
+//ServiceKey 返回服务在server中的key, group为空的时候使用DEFAULT_GROUP
+func ServiceKey(namespace, group, service string) string {
+	if group == "" {
+		group = DefaultGroup
+	}
+	return key(namespace, group+"@@"+service)
+}
+
+//SetInstances 覆盖服务的实例列表
+func (s *Server) SetInstances(namespace, group, service string, hosts ...*types.Host) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	m := make(map[string]*types.Host)
+	for _, h := range hosts {
+		m[hostKey(h.IP, h.Port)] = h
+	}
+	s.services[ServiceKey(namespace, group, service)] = m
+}
+
+//SetProtectThreshold 设置服务的保护阈值
+func (s *Server) SetProtectThreshold(namespace, group, service string, threshold float64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.protectThreshold[ServiceKey(namespace, group, service)] = threshold
+}
+
+//Reject 之后服务的实例注册,更新,注销以及健康状态的更新都返回500
+func (s *Server) Reject(namespace, group, service string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.rejected[ServiceKey(namespace, group, service)] = true
+}
+
+//Accept 取消Reject
+func (s *Server) Accept(namespace, group, service string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.rejected, ServiceKey(namespace, group, service))
+}
+
+//SetServiceStatus 查询服务的时候返回status,例如http.StatusNotFound表示服务不存在
+func (s *Server) SetServiceStatus(namespace, group, service string, status int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.serviceStatus[ServiceKey(namespace, group, service)] = status
+}
+
+//Cluster 返回PatchCluster收到的集群参数,没有更新过的时候返回nil
+func (s *Server) Cluster(namespace, group, service, cluster string) url.Values {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.clusters[ServiceKey(namespace, group, service)+"@@"+cluster]
+}
+
+//Instances 返回服务的实例列表
+func (s *Server) Instances(namespace, group, service string) []*types.Host {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var hosts []*types.Host
+	for _, h := range s.services[ServiceKey(namespace, group, service)] {
+		c := *h
+		hosts = append(hosts, &c)
+	}
+	return hosts
+}
+
+//RemoveService 删除服务
+func (s *Server) RemoveService(namespace, group, service string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.services, ServiceKey(namespace, group, service))
+}
+
+//Drop 模拟server重启之后丢失全部的临时实例
+func (s *Server) Drop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.services = make(map[string]map[string]*types.Host)
+}
+
+//Requests 返回请求的次数,key为 method + " " + path
+func (s *Server) Requests(key string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.requests[key]
+}
+
+//Beats 返回收到的心跳请求的参数
+func (s *Server) Beats() []url.Values {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	beats := make([]url.Values, len(s.beats))
+	copy(beats, s.beats)
+	return beats
+}
+
+func hostKey(ip string, port int) string {
+	return ip + ":" + strconv.Itoa(port)
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	s.lock.Lock()
+	var hosts []*types.Host
+	k := key(q.Get("namespaceId"), q.Get("serviceName"))
+	for _, h := range s.services[k] {
+		c := *h
+		hosts = append(hosts, &c)
+	}
+	s.lock.Unlock()
+	writeJSON(w, &types.ServiceInstanceListResult{
+		Name:        q.Get("serviceName"),
+		Clusters:    q.Get("clusters"),
+		CacheMillis: s.CacheMillis,
+		Hosts:       hosts,
+		LastRefTime: time.Now().UnixNano() / int64(time.Millisecond),
+	})
+}
+
+func (s *Server) instance(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	port, _ := strconv.Atoi(q.Get("port"))
+	serviceName := q.Get("serviceName")
+	if group := q.Get("groupName"); group != "" {
+		serviceName = group + "@@" + serviceName
+	}
+	k := key(q.Get("namespaceId"), serviceName)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.rejected[k] {
+		http.Error(w, "rejected", http.StatusInternalServerError)
+		return
+	}
+	switch r.Method {
+	case http.MethodPost, http.MethodPut:
+		weight, _ := strconv.ParseFloat(q.Get("weight"), 64)
+		var metadata map[string]string
+		if m := q.Get("metadata"); m != "" {
+			_ = json.Unmarshal([]byte(m), &metadata)
+		}
+		h := &types.Host{
+			IP:          q.Get("ip"),
+			Port:        port,
+			Weight:      weight,
+			Healthy:     q.Get("healthy") == "true",
+			Enabled:     q.Get("enable") == "true",
+			Ephemeral:   q.Get("ephemeral") == "true",
+			ClusterName: q.Get("clusterName"),
+			ServiceName: serviceName,
+			Metadata:    metadata,
+			Valid:       true,
+			InstanceID:  fmt.Sprintf("%s#%d#%s#%s", q.Get("ip"), port, q.Get("clusterName"), serviceName),
+		}
+		if _, ok := s.services[k]; !ok {
+			s.services[k] = make(map[string]*types.Host)
+		}
+		s.services[k][hostKey(h.IP, port)] = h
+	case http.MethodDelete:
+		delete(s.services[k], hostKey(q.Get("ip"), port))
+	}
+	_, _ = w.Write([]byte("ok"))
+}
+
+//health 更新实例的健康状态
+func (s *Server) health(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	port, _ := strconv.Atoi(q.Get("port"))
+	serviceName := q.Get("serviceName")
+	if group := q.Get("groupName"); group != "" {
+		serviceName = group + "@@" + serviceName
+	}
+	k := key(q.Get("namespaceId"), serviceName)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.rejected[k] {
+		http.Error(w, "rejected", http.StatusInternalServerError)
+		return
+	}
+	h, ok := s.services[k][hostKey(q.Get("ip"), port)]
+	if !ok {
+		http.Error(w, "instance not found", http.StatusBadRequest)
+		return
+	}
+	h.Healthy = q.Get("healthy") == "true"
+	_, _ = w.Write([]byte("ok"))
+}
+
+//beat 处理心跳,实例不存在的时候返回20404
+func (s *Server) beat(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	serviceName := q.Get("serviceName")
+	if group := q.Get("groupName"); group != "" && !strings.Contains(serviceName, "@@") {
+		serviceName = group + "@@" + serviceName
+	}
+	ip := q.Get("ip")
+	port, _ := strconv.Atoi(q.Get("port"))
+	if b := q.Get("beat"); b != "" {
+		var beat types.Beat
+		_ = json.Unmarshal([]byte(b), &beat)
+		ip, port = beat.IP, beat.Port
+	}
+	code := CodeOK
+	s.lock.Lock()
+	s.beats = append(s.beats, q)
+	if _, ok := s.services[key(q.Get("namespaceId"), serviceName)][hostKey(ip, port)]; !ok {
+		code = CodeResourceNotFound
+	}
+	result := &types.HeartBeatResult{
+		ClientBeatInterval: s.BeatInterval,
+		Code:               code,
+		LightBeatEnabled:   s.LightBeatEnabled,
+	}
+	s.lock.Unlock()
+	writeJSON(w, result)
+}
+
+func (s *Server) service(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	serviceName := q.Get("serviceName")
+	group := q.Get("groupName")
+	s.lock.Lock()
+	threshold := s.protectThreshold[ServiceKey(q.Get("namespaceId"), group, serviceName)]
+	status := s.serviceStatus[ServiceKey(q.Get("namespaceId"), group, serviceName)]
+	s.lock.Unlock()
+	if r.Method != http.MethodGet {
+		_, _ = w.Write([]byte("ok"))
+		return
+	}
+	if status != 0 {
+		http.Error(w, "service status", status)
+		return
+	}
+	writeJSON(w, &types.ServiceDetail{
+		Name:             serviceName,
+		GroupName:        group,
+		NamespaceID:      q.Get("namespaceId"),
+		ProtectThreshold: threshold,
+	})
+}
+
+func (s *Server) cluster(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	s.lock.Lock()
+	s.clusters[ServiceKey(q.Get("namespaceId"), q.Get("groupName"), q.Get("serviceName"))+"@@"+q.Get("clusterName")] = q
+	s.lock.Unlock()
+	_, _ = w.Write([]byte("ok"))
+}
+
+//catalog 按照group和服务名排序之后分页返回namespace下的服务
+func (s *Server) catalog(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	pageNo, _ := strconv.Atoi(q.Get("pageNo"))
+	pageSize, _ := strconv.Atoi(q.Get("pageSize"))
+	prefix := q.Get("namespaceId") + "@@"
+	if group := q.Get("groupName"); group != "" {
+		prefix += group + "@@"
+	}
+	s.lock.Lock()
+	var keys []string
+	for k := range s.services {
+		if strings.HasPrefix(k, prefix) && len(strings.Split(k, "@@")) == 3 {
+			keys = append(keys, k)
+		}
+	}
+	s.lock.Unlock()
+	sort.Strings(keys)
+	services := make([]*types.CatalogServiceDetail, 0)
+	start, end := (pageNo-1)*pageSize, pageNo*pageSize
+	for i := start; i >= 0 && i < end && i < len(keys); i++ {
+		parts := strings.Split(keys[i], "@@")
+		services = append(services, &types.CatalogServiceDetail{GroupName: parts[1], ServiceName: parts[2]})
+	}
+	writeJSON(w, services)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,189 @@
+package metrics
+
+import (
+	"github.com/celeskyking/go-nacos/pkg/stats"
+	"github.com/prometheus/client_golang/prometheus"
+	"strconv"
+	"time"
+)
+
+//Namespace 指标名的前缀
+const Namespace = "nacos_client"
+
+//Metrics 基于prometheus的stats.Recorder,同时实现了prometheus.Collector
+type Metrics struct {
+	//请求nacos server的次数
+	requests *prometheus.CounterVec
+	//请求nacos server的耗时
+	requestDuration *prometheus.HistogramVec
+	//配置长轮询的结果
+	longPolls *prometheus.CounterVec
+	//配置变化的通知
+	configChanges *prometheus.CounterVec
+	//使用本地快照的次数
+	snapshotFallbacks *prometheus.CounterVec
+
+	pushReceived prometheus.Counter
+
+	pushAcked prometheus.Counter
+
+	heartbeatFailures *prometheus.CounterVec
+	//nacos server是否健康,1为健康
+	serverHealthy *prometheus.GaugeVec
+	//订阅的服务的实例个数
+	instances *prometheus.GaugeVec
+	//服务是否处于保护阈值的保护状态,1为保护状态
+	serviceProtected *prometheus.GaugeVec
+	//服务进入保护状态的次数
+	serviceProtects *prometheus.CounterVec
+}
+
+//New 创建指标,需要注册到prometheus.Registerer并且通过Application.SetRecorder,ServerOptions.Recorder或者stats.SetRecorder生效
+func New() *Metrics {
+	return &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "requests_total",
+			Help:      "Requests sent to nacos servers, code is error for connection failures and timeouts.",
+		}, []string{"endpoint", "server", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "request_duration_seconds",
+			Help:      "Latency of requests sent to nacos servers.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"endpoint", "server"}),
+		longPolls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "config_long_polls_total",
+			Help:      "Config long polling results.",
+		}, []string{"outcome"}),
+		configChanges: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "config_changes_total",
+			Help:      "Config change notifications received.",
+		}, []string{"group", "data_id"}),
+		snapshotFallbacks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "config_snapshot_fallbacks_total",
+			Help:      "Configs loaded from local snapshots because nacos servers were not available.",
+		}, []string{"group", "data_id"}),
+		pushReceived: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "push_received_total",
+			Help:      "UDP push packets received from nacos servers.",
+		}),
+		pushAcked: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "push_acked_total",
+			Help:      "UDP push packets acknowledged.",
+		}),
+		heartbeatFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "heartbeat_failures_total",
+			Help:      "Failed heartbeats of ephemeral instances.",
+		}, []string{"service"}),
+		serverHealthy: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "server_healthy",
+			Help:      "Health check state of nacos servers, 1 for passing and 0 for critical.",
+		}, []string{"server"}),
+		instances: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "service_instances",
+			Help:      "Instances of subscribed services.",
+		}, []string{"namespace", "group", "service"}),
+		serviceProtected: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "service_protected",
+			Help:      "Whether subscribed services select from all instances because of the protect threshold, 1 for protected.",
+		}, []string{"namespace", "group", "service"}),
+		serviceProtects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "service_protects_total",
+			Help:      "Times subscribed services entered the protected state.",
+		}, []string{"namespace", "group", "service"}),
+	}
+}
+
+//Register 创建指标并且注册到registerer,不会修改全局的stats.Recorder,需要通过Application.SetRecorder等方式生效
+func Register(registerer prometheus.Registerer) (*Metrics, error) {
+	m := New()
+	if er := registerer.Register(m); er != nil {
+		return nil, er
+	}
+	return m, nil
+}
+
+func (m *Metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.requests, m.requestDuration, m.longPolls, m.configChanges, m.snapshotFallbacks,
+		m.pushReceived, m.pushAcked, m.heartbeatFailures, m.serverHealthy, m.instances,
+		m.serviceProtected, m.serviceProtects,
+	}
+}
+
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range m.collectors() {
+		c.Describe(ch)
+	}
+}
+
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range m.collectors() {
+		c.Collect(ch)
+	}
+}
+
+func (m *Metrics) Request(endpoint, server string, code int, latency time.Duration) {
+	c := "error"
+	if code != 0 {
+		c = strconv.Itoa(code)
+	}
+	m.requests.WithLabelValues(endpoint, server, c).Inc()
+	m.requestDuration.WithLabelValues(endpoint, server).Observe(latency.Seconds())
+}
+
+func (m *Metrics) LongPoll(outcome stats.LongPollOutcome) {
+	m.longPolls.WithLabelValues(string(outcome)).Inc()
+}
+
+func (m *Metrics) ConfigChanged(group, dataID string) {
+	m.configChanges.WithLabelValues(group, dataID).Inc()
+}
+
+func (m *Metrics) SnapshotFallback(group, dataID string) {
+	m.snapshotFallbacks.WithLabelValues(group, dataID).Inc()
+}
+
+func (m *Metrics) PushReceived() {
+	m.pushReceived.Inc()
+}
+
+func (m *Metrics) PushAcked() {
+	m.pushAcked.Inc()
+}
+
+func (m *Metrics) HeartbeatFailed(service string) {
+	m.heartbeatFailures.WithLabelValues(service).Inc()
+}
+
+func (m *Metrics) ServerHealth(server string, healthy bool) {
+	v := 0.0
+	if healthy {
+		v = 1
+	}
+	m.serverHealthy.WithLabelValues(server).Set(v)
+}
+
+func (m *Metrics) InstanceCount(namespace, group, service string, count int) {
+	m.instances.WithLabelValues(namespace, group, service).Set(float64(count))
+}
+
+func (m *Metrics) ServiceProtected(namespace, group, service string, protected bool) {
+	v := 0.0
+	if protected {
+		v = 1
+		m.serviceProtects.WithLabelValues(namespace, group, service).Inc()
+	}
+	m.serviceProtected.WithLabelValues(namespace, group, service).Set(v)
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"github.com/celeskyking/go-nacos/api"
+	"github.com/celeskyking/go-nacos/internal/nacostest"
+	"github.com/celeskyking/go-nacos/naming"
+	"github.com/celeskyking/go-nacos/pkg/stats"
+	"github.com/celeskyking/go-nacos/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegister(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m, er := Register(registry)
+	if er != nil {
+		t.Fatalf("register error:%+v", er)
+	}
+	if _, er := Register(registry); er == nil {
+		t.Fatal("expect duplicate registration error")
+	}
+
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	nacos.SetInstances("", "dev", "demo",
+		&types.Host{IP: "10.0.0.1", Port: 8080, Weight: 1, Healthy: true, Enabled: true},
+		&types.Host{IP: "10.0.0.2", Port: 8080, Weight: 1, Healthy: true, Enabled: true})
+	ns := naming.NewNamingService(&api.ServerOptions{
+		Addresses:  []string{nacos.Addr()},
+		LBStrategy: api.Direct,
+		Push:       &api.PushOptions{Disabled: true},
+		Recorder:   m,
+	})
+	defer ns.Stop()
+	sl, er := ns.GetInstances("demo", &naming.QueryOptions{Group: "dev"})
+	if er != nil {
+		t.Fatalf("get instances error:%+v", er)
+	}
+	defer sl.StopListen()
+
+	if v := testutil.ToFloat64(m.instances.WithLabelValues("", "dev", "demo")); v != 2 {
+		t.Fatalf("expect 2 instances, got:%v", v)
+	}
+	endpoint := "GET nacos/v1/ns/instance/list"
+	if v := testutil.ToFloat64(m.requests.WithLabelValues(endpoint, nacos.Addr(), "200")); v < 1 {
+		t.Fatalf("expect instance list requests recorded, got:%v", v)
+	}
+	if n := testutil.CollectAndCount(m.requestDuration); n < 1 {
+		t.Fatal("expect request latency recorded")
+	}
+}
+
+func TestMetrics_Recorder(t *testing.T) {
+	m := New()
+	var r stats.Recorder = m
+	r.Request("GET nacos/v1/cs/configs", "10.0.0.1:8848", 0, time.Millisecond)
+	r.LongPoll(stats.LongPollChanged)
+	r.LongPoll(stats.LongPollUnchanged)
+	r.LongPoll(stats.LongPollUnchanged)
+	r.ConfigChanged("DEFAULT_GROUP", "app.properties")
+	r.SnapshotFallback("DEFAULT_GROUP", "app.properties")
+	r.PushReceived()
+	r.PushAcked()
+	r.HeartbeatFailed("demo")
+	r.ServerHealth("10.0.0.1:8848", false)
+	r.ServiceProtected("", "dev", "demo", true)
+	r.ServiceProtected("", "dev", "demo", false)
+	r.ServiceProtected("", "dev", "demo", true)
+
+	expected := `
+# HELP nacos_client_config_long_polls_total Config long polling results.
+# TYPE nacos_client_config_long_polls_total counter
+nacos_client_config_long_polls_total{outcome="changed"} 1
+nacos_client_config_long_polls_total{outcome="unchanged"} 2
+# HELP nacos_client_requests_total Requests sent to nacos servers, code is error for connection failures and timeouts.
+# TYPE nacos_client_requests_total counter
+nacos_client_requests_total{code="error",endpoint="GET nacos/v1/cs/configs",server="10.0.0.1:8848"} 1
+# HELP nacos_client_server_healthy Health check state of nacos servers, 1 for passing and 0 for critical.
+# TYPE nacos_client_server_healthy gauge
+nacos_client_server_healthy{server="10.0.0.1:8848"} 0
+# HELP nacos_client_service_protected Whether subscribed services select from all instances because of the protect threshold, 1 for protected.
+# TYPE nacos_client_service_protected gauge
+nacos_client_service_protected{group="dev",namespace="",service="demo"} 1
+# HELP nacos_client_service_protects_total Times subscribed services entered the protected state.
+# TYPE nacos_client_service_protects_total counter
+nacos_client_service_protects_total{group="dev",namespace="",service="demo"} 2
+`
+	if er := testutil.CollectAndCompare(m, strings.NewReader(expected),
+		"nacos_client_config_long_polls_total", "nacos_client_requests_total", "nacos_client_server_healthy",
+		"nacos_client_service_protected", "nacos_client_service_protects_total"); er != nil {
+		t.Fatal(er)
+	}
+	for _, c := range []prometheus.Collector{m.configChanges, m.snapshotFallbacks, m.pushReceived, m.pushAcked, m.heartbeatFailures} {
+		if testutil.ToFloat64(c) != 1 {
+			t.Fatal("expect event counted once")
+		}
+	}
+}
@@ -1,16 +1,22 @@
 package nacos
 
 import (
+	"fmt"
 	"github.com/celeskyking/go-nacos/api"
 	"github.com/celeskyking/go-nacos/config"
 	"github.com/celeskyking/go-nacos/naming"
 	"github.com/celeskyking/go-nacos/naming/discovery"
+	"github.com/celeskyking/go-nacos/pkg/logger"
+	"github.com/celeskyking/go-nacos/pkg/stats"
 	"github.com/celeskyking/go-nacos/pkg/util"
 	"github.com/pkg/errors"
-	"github.com/sirupsen/logrus"
+	"strings"
+	"sync"
 )
 
-var ErrSnapshotDirIsEmpty = errors.New("未指定config service的snapshot目录")
+var ErrSnapshotDirIsEmpty = api.ErrSnapshotDirIsEmpty
+
+var ErrServersNotConfigured = api.ErrServersNotConfigured
 
 //NacosFactory nacos工厂
 type Factory struct {
@@ -20,24 +26,45 @@ func NewFactory() *Factory {
 	return &Factory{}
 }
 
+//NewConfigService 配置不合法的时候只输出错误日志,参考config.NewConfigService
 func (f *Factory) NewConfigService(options *api.ConfigOptions) config.ConfigService {
-	if options.SnapshotDir == "" {
-		logrus.Errorf("未指定config service的snapshot的目录")
-		panic(ErrSnapshotDirIsEmpty)
-	}
 	return config.NewConfigService(options)
 }
 
+//NewConfigServiceE 配置不合法或者没有可用的nacos server的时候返回错误
+func (f *Factory) NewConfigServiceE(options *api.ConfigOptions) (config.ConfigService, error) {
+	return config.NewConfigServiceE(options)
+}
+
 func (f *Factory) NewNamingService(options *api.ServerOptions) naming.NamingService {
 	return naming.NewNamingService(options)
 }
 
+//NewNamingServiceE 配置不合法或者没有可用的nacos server的时候返回错误
+func (f *Factory) NewNamingServiceE(options *api.ServerOptions) (naming.NamingService, error) {
+	return naming.NewNamingServiceE(options)
+}
+
 type Application struct {
 	Config *api.AppConfig
 
 	configServers *api.ServerOptions
 
 	namingServers *api.ServerOptions
+
+	lock sync.Mutex
+	//已经创建的config服务的http客户端,UpdateConfigServers的时候更新
+	configUpdaters []serverUpdater
+	//已经创建的naming服务的http客户端,UpdateNamingServers的时候更新
+	namingUpdaters []serverUpdater
+	//SetLogger设置的Logger,ServerOptions没有设置Logger的时候使用
+	log logger.Logger
+	//SetRecorder设置的Recorder,ServerOptions没有设置Recorder的时候使用
+	recorder stats.Recorder
+}
+
+type serverUpdater interface {
+	UpdateServers(addrs []string) error
 }
 
 func NewApplication(appConfig *api.AppConfig) *Application {
@@ -47,45 +74,223 @@ func NewApplication(appConfig *api.AppConfig) *Application {
 }
 
 func (a *Application) SetConfigServers(options *api.ServerOptions) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
 	a.configServers = options
 }
 
 func (a *Application) SetNamingServers(options *api.ServerOptions) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
 	a.namingServers = options
 }
 
 func (a *Application) SetServers(options *api.ServerOptions) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
 	a.namingServers = options
 	a.configServers = options
 }
 
+func (a *Application) servers() (configServers, namingServers *api.ServerOptions) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.withDefaults(a.configServers), a.withDefaults(a.namingServers)
+}
+
+//withDefaults ServerOptions没有设置Logger或者Recorder的时候返回使用SetLogger和SetRecorder设置的值的副本,调用方需要持有锁
+func (a *Application) withDefaults(options *api.ServerOptions) *api.ServerOptions {
+	if options == nil {
+		return nil
+	}
+	o := *options
+	if o.Logger == nil {
+		o.Logger = a.log
+	}
+	if o.Recorder == nil {
+		o.Recorder = a.recorder
+	}
+	return &o
+}
+
+//NewConfigService 没有配置nacos server或者配置不合法的时候只输出错误日志,之后可以通过UpdateServers设置地址
 func (a *Application) NewConfigService(snapshotDir string) config.ConfigService {
-	if a.configServers == nil {
-		panic(errors.New("未配置nacos server信息"))
+	cs := config.NewConfigService(a.configOptions(snapshotDir))
+	a.track(&a.configUpdaters, cs.HttpClient())
+	return cs
+}
+
+//NewConfigServiceE 没有配置nacos server,配置不合法或者没有可用的nacos server的时候返回错误
+func (a *Application) NewConfigServiceE(snapshotDir string) (config.ConfigService, error) {
+	cs, er := config.NewConfigServiceE(a.configOptions(snapshotDir))
+	if er != nil {
+		return nil, er
 	}
-	return config.NewConfigService(&api.ConfigOptions{
-		ServerOptions: a.configServers,
+	a.track(&a.configUpdaters, cs.HttpClient())
+	return cs, nil
+}
+
+func (a *Application) configOptions(snapshotDir string) *api.ConfigOptions {
+	configServers, _ := a.servers()
+	return &api.ConfigOptions{
+		ServerOptions: configServers,
 		SnapshotDir:   snapshotDir,
-	})
+	}
 }
 
+//NewNamingService 没有配置nacos server或者配置不合法的时候只输出错误日志,之后可以通过UpdateServers设置地址
 func (a *Application) NewNamingService() naming.NamingService {
-	if a.namingServers == nil {
-		panic(errors.New("未配置nacos server信息"))
+	_, namingServers := a.servers()
+	ns := naming.NewNamingService(namingServers)
+	a.track(&a.namingUpdaters, ns.HttpClient())
+	return ns
+}
+
+//NewNamingServiceE 没有配置nacos server,配置不合法或者没有可用的nacos server的时候返回错误
+func (a *Application) NewNamingServiceE() (naming.NamingService, error) {
+	_, namingServers := a.servers()
+	ns, er := naming.NewNamingServiceE(namingServers)
+	if er != nil {
+		return nil, er
+	}
+	a.track(&a.namingUpdaters, ns.HttpClient())
+	return ns, nil
+}
+
+func (a *Application) track(updaters *[]serverUpdater, updater serverUpdater) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	*updaters = append(*updaters, updater)
+}
+
+//UpdateServersError 部分服务更新nacos server的地址失败,其他的服务已经使用新的地址
+type UpdateServersError struct {
+	Errors []error
+}
+
+func (u *UpdateServersError) Error() string {
+	msgs := make([]string, 0, len(u.Errors))
+	for _, er := range u.Errors {
+		msgs = append(msgs, er.Error())
 	}
-	return naming.NewNamingService(a.namingServers)
+	return fmt.Sprintf("update nacos servers failed for %d service(s): [%s]", len(u.Errors), strings.Join(msgs, "; "))
+}
+
+func (u *UpdateServersError) Unwrap() []error {
+	return u.Errors
+}
+
+//UpdateServers 运行时同时替换config和naming的nacos server的地址,适用于SetServers配置的同一个集群。
+//config和naming使用不同集群的时候使用UpdateConfigServers和UpdateNamingServers。
+//可以通过LoadBalance()返回的loadbalancer.Observable监听server列表的变化
+func (a *Application) UpdateServers(addrs []string) error {
+	if er := validateAddrs(addrs); er != nil {
+		return er
+	}
+	u := &UpdateServersError{}
+	a.updateServers(&a.configServers, &a.configUpdaters, addrs, u)
+	a.updateServers(&a.namingServers, &a.namingUpdaters, addrs, u)
+	return u.errOrNil()
+}
+
+//UpdateConfigServers 运行时替换config服务的nacos server的地址,已经创建的config服务立即生效,之后创建的config服务也使用新的地址
+func (a *Application) UpdateConfigServers(addrs []string) error {
+	if er := validateAddrs(addrs); er != nil {
+		return er
+	}
+	u := &UpdateServersError{}
+	a.updateServers(&a.configServers, &a.configUpdaters, addrs, u)
+	return u.errOrNil()
+}
+
+//UpdateNamingServers 运行时替换naming服务的nacos server的地址,已经创建的naming服务立即生效,之后创建的naming服务也使用新的地址
+func (a *Application) UpdateNamingServers(addrs []string) error {
+	if er := validateAddrs(addrs); er != nil {
+		return er
+	}
+	u := &UpdateServersError{}
+	a.updateServers(&a.namingServers, &a.namingUpdaters, addrs, u)
+	return u.errOrNil()
+}
+
+func validateAddrs(addrs []string) error {
+	if len(addrs) == 0 {
+		return errors.New("nacos server list is empty")
+	}
+	for _, addr := range addrs {
+		if _, er := api.ToURL(addr); er != nil {
+			return errors.Wrapf(er, "invalid nacos server address:%s", addr)
+		}
+	}
+	return nil
+}
+
+//updateServers 复制配置之后替换地址,不修改调用方传入的配置,更新所有的客户端并且收集错误
+func (a *Application) updateServers(options **api.ServerOptions, updaters *[]serverUpdater, addrs []string, u *UpdateServersError) {
+	a.lock.Lock()
+	if *options != nil {
+		c := **options
+		c.Addresses = append([]string(nil), addrs...)
+		*options = &c
+	}
+	list := *updaters
+	a.lock.Unlock()
+	for _, updater := range list {
+		if er := updater.UpdateServers(addrs); er != nil {
+			u.Errors = append(u.Errors, er)
+		}
+	}
+}
+
+func (u *UpdateServersError) errOrNil() error {
+	if len(u.Errors) == 0 {
+		return nil
+	}
+	return u
+}
+
+//SetRecorder 设置之后通过当前Application创建的服务记录内部事件使用的Recorder,例如metrics.New()返回的prometheus指标,
+//为nil的时候使用全局的Recorder。ServerOptions中设置了Recorder的时候优先使用ServerOptions.Recorder,不会修改全局的Recorder
+func (a *Application) SetRecorder(recorder stats.Recorder) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.recorder = recorder
+}
+
+//SetLogger 设置之后通过当前Application创建的服务输出日志使用的Logger,例如logger.NewSlog(slog.Default()),
+//为nil的时候不输出日志。ServerOptions中设置了Logger的时候优先使用ServerOptions.Logger,不会修改全局的Logger
+func (a *Application) SetLogger(l logger.Logger) {
+	if l == nil {
+		l = logger.Nop{}
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.log = l
 }
 
 func (a *Application) NewDiscoveryClient() *discovery.Client {
+	return discovery.NewDiscoveryClient(a.NewNamingService(), a.discoveryOptions())
+}
+
+//NewDiscoveryClientE 和NewDiscoveryClient相同,创建naming服务失败的时候返回错误
+func (a *Application) NewDiscoveryClientE() (*discovery.Client, error) {
+	ns, er := a.NewNamingServiceE()
+	if er != nil {
+		return nil, er
+	}
+	return discovery.NewDiscoveryClient(ns, a.discoveryOptions()), nil
+}
+
+func (a *Application) discoveryOptions() *api.DiscoveryOptions {
 	if a.Config.IP == "" {
 		a.Config.IP = util.LocalIP()
 	}
-	return discovery.NewDiscoveryClient(a.NewNamingService(), &api.DiscoveryOptions{
+	return &api.DiscoveryOptions{
 		IP:        a.Config.IP,
 		Namespace: a.Config.Namespace,
 		AppName:   a.Config.AppName,
 		Cluster:   a.Config.Cluster,
 		Group:     a.Config.Group,
 		Port:      a.Config.Port,
-	})
+	}
 }
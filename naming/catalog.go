@@ -0,0 +1,173 @@
+package naming
+
+import (
+	"github.com/celeskyking/go-nacos/pkg/logger"
+	"github.com/celeskyking/go-nacos/types"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+const (
+	//DefaultCatalogPageSize 遍历服务目录的时候每页的大小
+	DefaultCatalogPageSize = 100
+	//DefaultCatalogWatchInterval 服务目录的轮询间隔
+	DefaultCatalogWatchInterval = 10 * time.Second
+)
+
+//CatalogEventType 服务目录的变化类型
+type CatalogEventType int
+
+const (
+	ServiceAdded CatalogEventType = iota
+
+	ServiceRemoved
+)
+
+func (t CatalogEventType) String() string {
+	switch t {
+	case ServiceAdded:
+		return "ServiceAdded"
+	case ServiceRemoved:
+		return "ServiceRemoved"
+	}
+	return "Unknown"
+}
+
+//CatalogEvent 服务的新增或者删除
+type CatalogEvent struct {
+	Type CatalogEventType
+
+	Service *types.CatalogServiceDetail
+}
+
+//GetAllServices 分页遍历namespace下所有分组的服务,返回服务的个数和服务列表
+func (n *namingService) GetAllServices(namespaceID string) (int, []*types.CatalogServiceDetail, error) {
+	var services []*types.CatalogServiceDetail
+	seen := make(map[string]bool)
+	for pageNo := 1; ; pageNo++ {
+		page, er := n.httpClient.GetCatalogServices(&types.ServiceListOption{
+			NamespaceID: namespaceID,
+			PageNo:      pageNo,
+			PageSize:    DefaultCatalogPageSize,
+		})
+		if er != nil {
+			return 0, nil, errors.Wrapf(er, "list catalog services, pageNo:%d", pageNo)
+		}
+		for _, s := range page {
+			//遍历的过程中服务发生变化可能导致分页错位,按照group和服务名去重
+			k := Key(s.GroupName, s.ServiceName)
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			services = append(services, s)
+		}
+		if len(page) < DefaultCatalogPageSize {
+			break
+		}
+	}
+	return len(services), services, nil
+}
+
+//CatalogWatcher 轮询服务目录,通过Events返回服务的新增和删除
+type CatalogWatcher struct {
+	ns *namingService
+
+	namespaceID string
+
+	interval time.Duration
+
+	services map[string]*types.CatalogServiceDetail
+
+	eventC chan *CatalogEvent
+
+	stopC chan struct{}
+
+	stopOnce sync.Once
+}
+
+//WatchCatalog 监听namespace下服务目录的变化,interval小于等于0的时候使用DefaultCatalogWatchInterval。
+//第一次查询同步执行,已经存在的服务会以ServiceAdded事件返回
+func (n *namingService) WatchCatalog(namespaceID string, interval time.Duration) (*CatalogWatcher, error) {
+	if interval <= 0 {
+		interval = DefaultCatalogWatchInterval
+	}
+	_, services, er := n.GetAllServices(namespaceID)
+	if er != nil {
+		return nil, er
+	}
+	w := &CatalogWatcher{
+		ns:          n,
+		namespaceID: namespaceID,
+		interval:    interval,
+		services:    make(map[string]*types.CatalogServiceDetail),
+		eventC:      make(chan *CatalogEvent, 100),
+		stopC:       make(chan struct{}),
+	}
+	go w.run(services)
+	return w, nil
+}
+
+//Events 服务目录的变化事件,Stop之后关闭
+func (w *CatalogWatcher) Events() <-chan *CatalogEvent {
+	return w.eventC
+}
+
+//Stop 停止监听,可以重复调用
+func (w *CatalogWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stopC)
+	})
+}
+
+func (w *CatalogWatcher) run(services []*types.CatalogServiceDetail) {
+	defer close(w.eventC)
+	if !w.diff(services) {
+		return
+	}
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stopC:
+			return
+		case <-ticker.C:
+			_, services, er := w.ns.GetAllServices(w.namespaceID)
+			if er != nil {
+				w.ns.log.Error("watch catalog failed", logger.F("namespace", w.namespaceID), logger.Err(er))
+				continue
+			}
+			if !w.diff(services) {
+				return
+			}
+		}
+	}
+}
+
+//diff 对比上一次的服务列表并且发送事件,Stop的时候返回false
+func (w *CatalogWatcher) diff(services []*types.CatalogServiceDetail) bool {
+	current := make(map[string]*types.CatalogServiceDetail, len(services))
+	var events []*CatalogEvent
+	for _, s := range services {
+		k := Key(s.GroupName, s.ServiceName)
+		current[k] = s
+		if _, ok := w.services[k]; !ok {
+			events = append(events, &CatalogEvent{Type: ServiceAdded, Service: s})
+		}
+	}
+	for k, s := range w.services {
+		if _, ok := current[k]; !ok {
+			events = append(events, &CatalogEvent{Type: ServiceRemoved, Service: s})
+		}
+	}
+	w.services = current
+	for _, e := range events {
+		select {
+		case w.eventC <- e:
+		case <-w.stopC:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package naming
+
+import (
+	"fmt"
+	"github.com/celeskyking/go-nacos/api"
+	"github.com/celeskyking/go-nacos/internal/nacostest"
+	"github.com/celeskyking/go-nacos/types"
+	"testing"
+	"time"
+)
+
+func newTestNamingService(nacos *nacostest.Server) NamingService {
+	return NewNamingService(&api.ServerOptions{
+		Addresses:  []string{nacos.Addr()},
+		LBStrategy: api.RoundRobin,
+		Push:       &api.PushOptions{Disabled: true},
+	})
+}
+
+func TestNamingService_GetAllServices(t *testing.T) {
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	host := &types.Host{IP: "10.0.0.1", Port: 8080}
+	for i := 0; i < DefaultCatalogPageSize+5; i++ {
+		nacos.SetInstances("ns", fmt.Sprintf("group-%d", i%3), fmt.Sprintf("service-%d", i), host)
+	}
+	nacos.SetInstances("other", "dev", "demo", host)
+	ns := newTestNamingService(nacos)
+	defer ns.Stop()
+	n, services, er := ns.GetAllServices("ns")
+	if er != nil {
+		t.Fatalf("get all services error:%+v", er)
+	}
+	if n != DefaultCatalogPageSize+5 || len(services) != n {
+		t.Fatalf("expect %d services across groups, got:%d", DefaultCatalogPageSize+5, n)
+	}
+	count, er := ns.GetAllServicesCount("ns")
+	if er != nil || count != n {
+		t.Fatalf("unexpected count:%d, error:%+v", count, er)
+	}
+	page, er := ns.GetServices(&types.ServiceListOption{NamespaceID: "ns", GroupName: "group-1", PageNo: 1, PageSize: 10})
+	if er != nil {
+		t.Fatalf("get services error:%+v", er)
+	}
+	for _, s := range page {
+		if s.GroupName != "group-1" {
+			t.Fatalf("expect services in group-1 only, got:%s", s.GroupName)
+		}
+	}
+}
+
+func TestNamingService_WatchCatalog(t *testing.T) {
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	host := &types.Host{IP: "10.0.0.1", Port: 8080}
+	nacos.SetInstances("", "dev", "a", host)
+	ns := newTestNamingService(nacos)
+	defer ns.Stop()
+	w, er := ns.WatchCatalog("", 20*time.Millisecond)
+	if er != nil {
+		t.Fatalf("watch catalog error:%+v", er)
+	}
+	next := func() *CatalogEvent {
+		select {
+		case e := <-w.Events():
+			return e
+		case <-time.After(time.Second):
+			t.Fatalf("expect catalog event")
+		}
+		return nil
+	}
+	if e := next(); e.Type != ServiceAdded || e.Service.ServiceName != "a" {
+		t.Fatalf("expect existing service added, got:%s %+v", e.Type, e.Service)
+	}
+	nacos.SetInstances("", "beta", "b", host)
+	if e := next(); e.Type != ServiceAdded || e.Service.ServiceName != "b" || e.Service.GroupName != "beta" {
+		t.Fatalf("expect service b added, got:%s %+v", e.Type, e.Service)
+	}
+	nacos.RemoveService("", "dev", "a")
+	if e := next(); e.Type != ServiceRemoved || e.Service.ServiceName != "a" {
+		t.Fatalf("expect service a removed, got:%s %+v", e.Type, e.Service)
+	}
+	w.Stop()
+	for range w.Events() {
+	}
+}
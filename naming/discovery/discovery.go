@@ -3,10 +3,13 @@ package discovery
 import (
 	"github.com/celeskyking/go-nacos/api"
 	"github.com/celeskyking/go-nacos/naming"
+	"github.com/celeskyking/go-nacos/naming/health"
 	beat "github.com/celeskyking/go-nacos/naming/heartbeat"
 	"github.com/celeskyking/go-nacos/pkg/util"
 	"github.com/celeskyking/go-nacos/types"
 	"github.com/pkg/errors"
+	"sync"
+	"time"
 )
 
 type Client struct {
@@ -21,32 +24,47 @@ type Client struct {
 	AppName string
 	//环境名
 	Group string
-	//心跳服务
-	heartBeatService beat.HeartBeatService
 
 	naming naming.NamingService
 
 	Ephemeral bool
 
-	instance *types.ServiceInstance
+	//当前Client注册的实例
+	instances []*types.ServiceInstance
 	//初始化的健康状态
 	InitHealthy bool
 
-	stopC chan struct{}
+	beatListeners []beat.EventListener
+	//Shutdown的时候禁用实例之后等待的时间
+	DrainPeriod time.Duration
+
+	lock sync.Mutex
+	//通过当前Client订阅的服务列表,Shutdown的时候停止
+	serverLists []*naming.ServerList
+	//当前Client注册的临时实例的心跳key,用来过滤共享调度器的事件
+	beatKeys map[string]struct{}
+
+	shutdownOnce sync.Once
+
+	shutdownErr error
+
+	checker *health.Checker
+	//持久化实例的配置,通过SetPersistent设置
+	persistent *PersistentOptions
 }
 
 func NewDiscoveryClient(naming naming.NamingService, config *api.DiscoveryOptions) *Client {
 	ip := util.LocalIP()
 	c := &Client{
-		IP:        ip,
-		Port:      config.Port,
-		Namespace: config.Namespace,
-		Cluster:   config.Cluster,
-		AppName:   config.AppName,
-		Group:     config.Group,
-		stopC:     make(chan struct{}, 0),
-		naming:    naming,
-		Ephemeral: true,
+		IP:          ip,
+		Port:        config.Port,
+		Namespace:   config.Namespace,
+		Cluster:     config.Cluster,
+		AppName:     config.AppName,
+		Group:       config.Group,
+		naming:      naming,
+		Ephemeral:   true,
+		DrainPeriod: DefaultDrainPeriod,
 	}
 	return c
 }
@@ -63,51 +81,170 @@ func (c *Client) SetEphemeral(ephemeral bool) {
 	c.Ephemeral = ephemeral
 }
 
+//OnHeartBeatEvent 监听心跳的事件,例如服务端丢失实例之后的重新注册,需要在Register之前调用
+func (c *Client) OnHeartBeatEvent(listener beat.EventListener) {
+	c.beatListeners = append(c.beatListeners, listener)
+}
+
+//SetHealthy 设置当前Client注册的全部实例的健康状态。临时实例的健康状态由心跳决定,所以通过enabled摘除和恢复流量,
+//持久化实例直接更新健康状态
 func (c *Client) SetHealthy(healthy bool) error {
-	return c.naming.SetInstanceHealthy(c.AppName, &naming.QueryOptions{
-		Namespace: c.Namespace,
-		Group:     c.Group,
-		Cluster:   c.Cluster,
-		Healthy:   healthy,
-	})
+	batch := &BatchError{Op: "set healthy"}
+	for _, instance := range c.Instances() {
+		var er error
+		if instance.Ephemeral {
+			i := *instance
+			i.Enable = healthy
+			er = c.naming.UpdateInstance(&i)
+		} else {
+			er = c.naming.UpdateInstanceHealthy(instance, healthy)
+		}
+		if er != nil {
+			batch.add(registrationOf(instance), instance, er)
+		}
+	}
+	return batch.errOrNil()
 }
 
+//Register 注册当前应用的实例,服务名为AppName
 func (c *Client) Register() error {
-	req := &types.ServiceInstance{
-		IP:          c.IP,
+	er := c.RegisterAll(&Registration{
+		ServiceName: c.AppName,
 		Port:        c.Port,
+	})
+	if be, ok := er.(*BatchError); ok && len(be.Failures) == 1 {
+		return errors.Wrap(be.Failures[0].Err, " register service")
+	}
+	return er
+}
+
+//RegisterAll 在同一个Client下注册多个实例,例如同一个进程的http,grpc和admin端口分别注册为不同的服务,
+//临时实例共用NamingService的心跳调度器。会尝试注册全部的实例,部分失败的时候返回*BatchError,注册成功的实例保持注册状态
+func (c *Client) RegisterAll(registrations ...*Registration) error {
+	batch := &BatchError{Op: "register"}
+	for _, r := range registrations {
+		instance := c.buildInstance(r)
+		if !c.Ephemeral && c.persistent != nil {
+			if er := c.preparePersistent(instance); er != nil {
+				batch.add(r, instance, er)
+				continue
+			}
+		}
+		if er := c.naming.RegisterInstance(instance); er != nil {
+			batch.add(r, instance, er)
+			continue
+		}
+		c.lock.Lock()
+		c.instances = append(c.instances, instance)
+		c.lock.Unlock()
+		if c.Ephemeral {
+			c.startBeat(instance)
+		}
+	}
+	return batch.errOrNil()
+}
+
+func (c *Client) buildInstance(r *Registration) *types.ServiceInstance {
+	instance := &types.ServiceInstance{
+		IP:          c.IP,
+		Port:        r.Port,
 		NamespaceID: c.Namespace,
-		ServiceName: c.AppName,
-		GroupName:   c.Group,
-		ClusterName: c.Cluster,
+		ServiceName: r.ServiceName,
+		GroupName:   r.Group,
+		ClusterName: r.Cluster,
+		Metadata:    r.Metadata,
 		Ephemeral:   c.Ephemeral,
-		Weight:      1.0,
+		Weight:      r.Weight,
 		Healthy:     c.InitHealthy,
 		Enable:      true,
 	}
-	er := c.naming.RegisterInstance(req)
-	if er != nil {
-		return errors.Wrap(er, " register service")
+	if instance.GroupName == "" {
+		instance.GroupName = c.Group
 	}
-	if c.Ephemeral {
-		h := beat.NewHeartBeatService(c.naming.HttpClient(), req)
-		go h.Start(c.stopC)
-		c.heartBeatService = h
+	if instance.ClusterName == "" {
+		instance.ClusterName = c.Cluster
 	}
-	c.instance = req
-	return nil
+	if instance.Weight <= 0 {
+		instance.Weight = 1.0
+	}
+	return instance
 }
 
+func (c *Client) startBeat(instance *types.ServiceInstance) {
+	scheduler := c.naming.BeatScheduler()
+	c.lock.Lock()
+	if c.beatKeys == nil {
+		c.beatKeys = make(map[string]struct{})
+		if len(c.beatListeners) > 0 {
+			scheduler.AddListener(c.dispatchBeatEvent)
+		}
+	}
+	c.beatKeys[beat.TaskKey(instance)] = struct{}{}
+	c.lock.Unlock()
+	scheduler.Add(instance)
+}
+
+//dispatchBeatEvent 共享的调度器会收到所有实例的事件,只转发当前Client注册的实例
+func (c *Client) dispatchBeatEvent(event *beat.Event) {
+	c.lock.Lock()
+	_, ok := c.beatKeys[beat.TaskKey(event.Instance)]
+	c.lock.Unlock()
+	if !ok {
+		return
+	}
+	for _, l := range c.beatListeners {
+		l(event)
+	}
+}
+
+//Instances 返回当前Client已经注册的实例
+func (c *Client) Instances() []*types.ServiceInstance {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	instances := make([]*types.ServiceInstance, len(c.instances))
+	copy(instances, c.instances)
+	return instances
+}
+
+//Deregister 停止心跳并且注销当前Client注册的全部实例,不会停止共享的NamingService。
+//部分失败的时候返回*BatchError,失败的实例保留,可以再次调用Deregister重试
 func (c *Client) Deregister() error {
-	c.stopC <- struct{}{}
-	er := c.naming.DeRegisterInstance(c.instance)
-	if er != nil {
-		return errors.Wrap(er, "deregister service")
+	batch := &BatchError{Op: "deregister"}
+	var remains []*types.ServiceInstance
+	for _, instance := range c.Instances() {
+		if c.Ephemeral {
+			c.naming.BeatScheduler().Remove(instance)
+		}
+		if er := c.naming.DeRegisterInstance(instance); er != nil {
+			batch.add(registrationOf(instance), instance, er)
+			remains = append(remains, instance)
+			continue
+		}
+		c.lock.Lock()
+		delete(c.beatKeys, beat.TaskKey(instance))
+		c.lock.Unlock()
 	}
-	c.naming.Stop()
-	return nil
+	c.lock.Lock()
+	c.instances = remains
+	c.lock.Unlock()
+	return batch.errOrNil()
 }
 
+//GetInstances 获取服务的实例列表,开启同区域优先但是没有指定Zone的时候,使用当前实例的Cluster作为Zone
 func (c *Client) GetInstances(serviceName string, options *naming.QueryOptions) (*naming.ServerList, error) {
-	return c.naming.GetInstances(serviceName, options)
+	if options.ZoneAffinity != nil && options.ZoneAffinity.Zone == "" {
+		affinity := *options.ZoneAffinity
+		affinity.Zone = c.Cluster
+		o := *options
+		o.ZoneAffinity = &affinity
+		options = &o
+	}
+	sl, er := c.naming.GetInstances(serviceName, options)
+	if er != nil {
+		return nil, er
+	}
+	c.lock.Lock()
+	c.serverLists = append(c.serverLists, sl)
+	c.lock.Unlock()
+	return sl, nil
 }
@@ -1,3 +1,6 @@
+//go:build integration
+
+//需要本地运行的nacos server(127.0.0.1:8848),通过 go test -tags integration 运行
 package discovery
 
 import (
@@ -0,0 +1,71 @@
+package discovery
+
+import (
+	"github.com/celeskyking/go-nacos/naming/health"
+	"github.com/celeskyking/go-nacos/pkg/logger"
+	"sync"
+)
+
+//StartHealthCheck 使用探针检查当前进程的健康状态,状态变化的时候通过SetHealthy同步到Nacos,同步失败的时候在之后的每次检查重试,
+//直到Nacos中的状态和检查的结果一致。options为nil的时候使用health.DefaultOptions,初始状态与InitHealthy保持一致。需要在Register之后调用
+func (c *Client) StartHealthCheck(options *health.Options, probes map[string]health.Probe) *health.Checker {
+	if options == nil {
+		options = health.DefaultOptions()
+		options.InitialHealthy = c.InitHealthy || c.Ephemeral
+	}
+	if options.Logger == nil {
+		o := *options
+		o.Logger = c.naming.Logger()
+		options = &o
+	}
+	checker := health.NewChecker(options)
+	for name, p := range probes {
+		checker.AddProbe(name, p)
+	}
+	checker.OnChange(func(healthy bool, cause error) {
+		c.naming.Logger().Info("instance health changed", logger.F("app", c.AppName), logger.F("healthy", healthy), logger.F("cause", cause))
+	})
+	s := &healthSync{client: c, synced: checker.Healthy()}
+	checker.OnCheck(s.sync)
+	c.lock.Lock()
+	old := c.checker
+	c.checker = checker
+	c.lock.Unlock()
+	if old != nil {
+		old.Stop()
+	}
+	checker.Start()
+	return checker
+}
+
+func (c *Client) stopHealthCheck() {
+	c.lock.Lock()
+	checker := c.checker
+	c.checker = nil
+	c.lock.Unlock()
+	if checker != nil {
+		checker.Stop()
+	}
+}
+
+//healthSync 记录已经同步到Nacos的健康状态,和检查的结果不一致的时候重新同步
+type healthSync struct {
+	client *Client
+
+	lock sync.Mutex
+
+	synced bool
+}
+
+func (s *healthSync) sync(healthy bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.synced == healthy {
+		return
+	}
+	if er := s.client.SetHealthy(healthy); er != nil {
+		s.client.naming.Logger().Error("sync instance health to nacos failed, retry on next check", logger.F("healthy", healthy), logger.Err(er))
+		return
+	}
+	s.synced = healthy
+}
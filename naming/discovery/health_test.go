@@ -0,0 +1,111 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"github.com/celeskyking/go-nacos/api"
+	"github.com/celeskyking/go-nacos/internal/nacostest"
+	"github.com/celeskyking/go-nacos/naming"
+	"github.com/celeskyking/go-nacos/naming/health"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_StartHealthCheck(t *testing.T) {
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	ns := naming.NewNamingService(&api.ServerOptions{
+		Addresses:  []string{nacos.Addr()},
+		LBStrategy: api.RoundRobin,
+	})
+	defer ns.Stop()
+	for _, ephemeral := range []bool{true, false} {
+		c := NewDiscoveryClient(ns, &api.DiscoveryOptions{AppName: "demo", Group: "dev", Port: 8080})
+		c.SetEphemeral(ephemeral)
+		c.SetInitHealthy(true)
+		if er := c.Register(); er != nil {
+			t.Fatalf("register error:%+v", er)
+		}
+		var failing atomic.Bool
+		checker := c.StartHealthCheck(&health.Options{
+			Interval:         10 * time.Millisecond,
+			FailureThreshold: 2,
+			SuccessThreshold: 2,
+			InitialHealthy:   true,
+		}, map[string]health.Probe{
+			"func": health.ProbeFunc(func(ctx context.Context) error {
+				if failing.Load() {
+					return errors.New("down")
+				}
+				return nil
+			}),
+		})
+		//临时实例通过enabled摘除流量,持久化实例通过healthy
+		available := func() bool {
+			hosts := nacos.Instances("", "dev", "demo")
+			if len(hosts) != 1 {
+				t.Fatalf("expect 1 instance, got:%d", len(hosts))
+			}
+			if ephemeral {
+				return hosts[0].Enabled
+			}
+			return hosts[0].Healthy
+		}
+		failing.Store(true)
+		time.Sleep(100 * time.Millisecond)
+		if checker.Healthy() || available() {
+			t.Fatalf("ephemeral:%v, expect instance unavailable after probe failures", ephemeral)
+		}
+		failing.Store(false)
+		time.Sleep(100 * time.Millisecond)
+		if !checker.Healthy() || !available() {
+			t.Fatalf("ephemeral:%v, expect instance available after probe recovered", ephemeral)
+		}
+		c.SetDrainPeriod(0)
+		if er := c.Shutdown(context.Background()); er != nil {
+			t.Fatalf("shutdown error:%+v", er)
+		}
+	}
+}
+
+func TestClient_StartHealthCheckRetry(t *testing.T) {
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	ns := naming.NewNamingService(&api.ServerOptions{
+		Addresses:  []string{nacos.Addr()},
+		LBStrategy: api.RoundRobin,
+	})
+	defer ns.Stop()
+	c := NewDiscoveryClient(ns, &api.DiscoveryOptions{AppName: "demo", Group: "dev", Port: 8080})
+	c.SetEphemeral(false)
+	c.SetInitHealthy(true)
+	if er := c.Register(); er != nil {
+		t.Fatalf("register error:%+v", er)
+	}
+	defer c.Deregister()
+	checker := c.StartHealthCheck(&health.Options{
+		Interval:         10 * time.Millisecond,
+		FailureThreshold: 1,
+		InitialHealthy:   true,
+	}, map[string]health.Probe{
+		"func": health.ProbeFunc(func(ctx context.Context) error {
+			return errors.New("down")
+		}),
+	})
+	defer checker.Stop()
+	//状态变化的时候同步失败,之后的检查会一直重试
+	nacos.Reject("", "dev", "demo")
+	time.Sleep(200 * time.Millisecond)
+	if checker.Healthy() || !nacos.Instances("", "dev", "demo")[0].Healthy {
+		t.Fatal("expect unhealthy state not synced while nacos rejects updates")
+	}
+	nacos.Accept("", "dev", "demo")
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) && nacos.Instances("", "dev", "demo")[0].Healthy {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if nacos.Instances("", "dev", "demo")[0].Healthy {
+		t.Fatal("expect unhealthy state synced after nacos recovered")
+	}
+}
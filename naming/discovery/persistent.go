@@ -0,0 +1,80 @@
+package discovery
+
+import (
+	"github.com/celeskyking/go-nacos/err"
+	"github.com/celeskyking/go-nacos/types"
+	"github.com/pkg/errors"
+)
+
+//DefaultClusterName nacos默认的集群名
+const DefaultClusterName = "DEFAULT"
+
+//PersistentOptions 持久化实例的配置,实例的健康状态由服务端按照HealthChecker主动探测,客户端不发送心跳
+type PersistentOptions struct {
+	//服务端的健康检查,例如types.NewTcpHealthChecker(),types.NewMySQLHealthChecker(user, pwd, cmd)
+	HealthChecker types.IHealthChecker
+	//健康检查的端口,UseInstancePort4Check为true的时候使用实例的端口
+	CheckPort int
+
+	UseInstancePort4Check bool
+	//集群的元数据
+	Metadata map[string]string
+}
+
+//SetPersistent 按照持久化实例注册,Register的时候会创建服务,并且通过PatchCluster设置集群的健康检查,
+//health checker的参数在这里提前校验
+func (c *Client) SetPersistent(options *PersistentOptions) error {
+	if options == nil {
+		return errors.New("persistent options is nil")
+	}
+	if er := types.ValidateHealthChecker(options.HealthChecker); er != nil {
+		return er
+	}
+	if !options.UseInstancePort4Check && (options.CheckPort <= 0 || options.CheckPort > 65535) {
+		return errors.Errorf("check port:%d not valid, or set UseInstancePort4Check", options.CheckPort)
+	}
+	c.Ephemeral = false
+	c.persistent = options
+	return nil
+}
+
+//preparePersistent 服务不存在的时候创建服务,然后更新集群的健康检查
+func (c *Client) preparePersistent(instance *types.ServiceInstance) error {
+	httpClient := c.naming.HttpClient()
+	service := &types.Service{
+		ServiceName: instance.ServiceName,
+		GroupName:   instance.GroupName,
+		NamespaceId: instance.NamespaceID,
+	}
+	if _, er := httpClient.GetService(service); er != nil {
+		//只有服务不存在的时候才创建,其他错误(例如超时或者没有权限)直接返回
+		if !errors.Is(er, err.ErrNotFound) {
+			return errors.Wrap(er, "get service")
+		}
+		if er := c.naming.CreateService(service); er != nil {
+			return errors.Wrap(er, "create service")
+		}
+	}
+	clusterName := instance.ClusterName
+	if clusterName == "" {
+		clusterName = DefaultClusterName
+	}
+	checkPort := c.persistent.CheckPort
+	if c.persistent.UseInstancePort4Check {
+		checkPort = instance.Port
+	}
+	er := c.naming.PatchCluster(&types.Cluster{
+		NamespaceID:           instance.NamespaceID,
+		ClusterName:           clusterName,
+		ServiceName:           instance.ServiceName,
+		GroupName:             instance.GroupName,
+		Metadata:              c.persistent.Metadata,
+		CheckPort:             checkPort,
+		HealthChecker:         c.persistent.HealthChecker,
+		UseInstancePort4Check: c.persistent.UseInstancePort4Check,
+	})
+	if er != nil {
+		return errors.Wrap(er, "patch cluster")
+	}
+	return nil
+}
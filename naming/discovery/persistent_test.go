@@ -0,0 +1,120 @@
+package discovery
+
+import (
+	"github.com/celeskyking/go-nacos/api"
+	"github.com/celeskyking/go-nacos/err"
+	"github.com/celeskyking/go-nacos/internal/nacostest"
+	"github.com/celeskyking/go-nacos/naming"
+	"github.com/celeskyking/go-nacos/types"
+	"github.com/pkg/errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestClient_SetPersistent(t *testing.T) {
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	nacos.BeatInterval = 20
+	ns := naming.NewNamingService(&api.ServerOptions{
+		Addresses:  []string{nacos.Addr()},
+		LBStrategy: api.RoundRobin,
+	})
+	defer ns.Stop()
+	c := NewDiscoveryClient(ns, &api.DiscoveryOptions{AppName: "mysql-proxy", Group: "dev", Port: 3306})
+	c.SetInitHealthy(true)
+	er := c.SetPersistent(&PersistentOptions{
+		HealthChecker: types.NewMySQLHealthChecker("", "", "select 1"),
+	})
+	if !errors.Is(er, err.ErrHealthCheckerNotValid) {
+		t.Fatalf("expect ErrHealthCheckerNotValid, got:%+v", er)
+	}
+	if !c.Ephemeral {
+		t.Fatalf("expect client unchanged when options not valid")
+	}
+	er = c.SetPersistent(&PersistentOptions{
+		HealthChecker:         types.NewTcpHealthChecker(),
+		UseInstancePort4Check: true,
+	})
+	if er != nil {
+		t.Fatalf("set persistent error:%+v", er)
+	}
+	if er := c.Register(); er != nil {
+		t.Fatalf("register error:%+v", er)
+	}
+	cluster := nacos.Cluster("", "dev", "mysql-proxy", DefaultClusterName)
+	if cluster == nil {
+		t.Fatalf("expect cluster patched")
+	}
+	if cluster.Get("healthChecker") != `{"type":"TCP"}` || cluster.Get("checkPort") != "3306" {
+		t.Fatalf("unexpected cluster:%+v", cluster)
+	}
+	hosts := nacos.Instances("", "dev", "mysql-proxy")
+	if len(hosts) != 1 || hosts[0].Ephemeral {
+		t.Fatalf("expect persistent instance registered, got:%+v", hosts)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := len(nacos.Beats()); n != 0 {
+		t.Fatalf("expect no beats for persistent instance, got:%d", n)
+	}
+}
+
+func TestValidateHealthChecker(t *testing.T) {
+	valid := []types.IHealthChecker{
+		types.NewNoneHealthChecker(),
+		types.NewTcpHealthChecker(),
+		types.NewHttpHealthChecker("/health"),
+		types.NewMySQLHealthChecker("root", "", "select 1"),
+	}
+	for _, c := range valid {
+		if er := types.ValidateHealthChecker(c); er != nil {
+			t.Errorf("checker:%s, unexpected error:%+v", c.GetType(), er)
+		}
+	}
+	h := types.NewHttpHealthChecker("/health")
+	h.SetHeaders("User-Agent")
+	invalid := []types.IHealthChecker{
+		nil,
+		&types.HttpHealthChecker{Path: "/health", ExpectedResponseCode: 200},
+		types.NewHttpHealthChecker("health"),
+		h,
+		&types.TCPHealthChecker{},
+	}
+	for i, c := range invalid {
+		if er := types.ValidateHealthChecker(c); !errors.Is(er, err.ErrHealthCheckerNotValid) {
+			t.Errorf("case %d: expect ErrHealthCheckerNotValid, got:%+v", i, er)
+		}
+	}
+}
+
+func TestClient_PreparePersistent(t *testing.T) {
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	ns := naming.NewNamingService(&api.ServerOptions{
+		Addresses:  []string{nacos.Addr()},
+		LBStrategy: api.RoundRobin,
+		Push:       &api.PushOptions{Disabled: true},
+	})
+	defer ns.Stop()
+	c := NewDiscoveryClient(ns, &api.DiscoveryOptions{Group: "dev", Port: 3306})
+	if er := c.SetPersistent(&PersistentOptions{HealthChecker: types.NewTcpHealthChecker(), UseInstancePort4Check: true}); er != nil {
+		t.Fatal(er)
+	}
+	//服务不存在的时候创建服务
+	nacos.SetServiceStatus("", "dev", "missing", http.StatusNotFound)
+	if er := c.RegisterAll(&Registration{ServiceName: "missing", Port: 3306}); er != nil {
+		t.Fatalf("register error:%+v", er)
+	}
+	if n := nacos.Requests("POST /nacos/v1/ns/service"); n != 1 {
+		t.Fatalf("expect service created once, got:%d", n)
+	}
+	//其他错误直接返回,不会创建服务
+	nacos.SetServiceStatus("", "dev", "forbidden", http.StatusForbidden)
+	er := c.RegisterAll(&Registration{ServiceName: "forbidden", Port: 3307})
+	if be, ok := er.(*BatchError); !ok || !errors.Is(be.Failures[0].Err, err.ErrForbidden) {
+		t.Fatalf("expect forbidden error, got:%v", er)
+	}
+	if n := nacos.Requests("POST /nacos/v1/ns/service"); n != 1 {
+		t.Fatalf("expect service not created on other errors, got:%d", n)
+	}
+}
@@ -0,0 +1,78 @@
+package discovery
+
+import (
+	"fmt"
+	"github.com/celeskyking/go-nacos/types"
+	"strings"
+)
+
+//Registration 注册的实例信息,同一个Client下的实例共用IP和Namespace
+type Registration struct {
+	//服务名
+	ServiceName string
+	//为空的时候使用Client的Group
+	Group string
+	//为空的时候使用Client的Cluster
+	Cluster string
+
+	Port int
+
+	Metadata map[string]string
+	//小于等于0的时候使用1.0
+	Weight float64
+}
+
+func registrationOf(instance *types.ServiceInstance) *Registration {
+	return &Registration{
+		ServiceName: instance.ServiceName,
+		Group:       instance.GroupName,
+		Cluster:     instance.ClusterName,
+		Port:        instance.Port,
+		Metadata:    instance.Metadata,
+		Weight:      instance.Weight,
+	}
+}
+
+//RegistrationError 单个实例的注册或者注销失败
+type RegistrationError struct {
+	Registration *Registration
+
+	Instance *types.ServiceInstance
+
+	Err error
+}
+
+func (r *RegistrationError) Error() string {
+	return fmt.Sprintf("service:%s, port:%d, error:%v", r.Registration.ServiceName, r.Registration.Port, r.Err)
+}
+
+func (r *RegistrationError) Unwrap() error {
+	return r.Err
+}
+
+//BatchError 批量注册或者注销的时候部分实例失败
+type BatchError struct {
+	//register或者deregister
+	Op string
+
+	Failures []*RegistrationError
+}
+
+func (b *BatchError) Error() string {
+	msgs := make([]string, 0, len(b.Failures))
+	for _, f := range b.Failures {
+		msgs = append(msgs, f.Error())
+	}
+	return fmt.Sprintf("%s %d instance(s) failed: [%s]", b.Op, len(b.Failures), strings.Join(msgs, "; "))
+}
+
+func (b *BatchError) add(r *Registration, instance *types.ServiceInstance, er error) {
+	b.Failures = append(b.Failures, &RegistrationError{Registration: r, Instance: instance, Err: er})
+}
+
+func (b *BatchError) errOrNil() error {
+	if len(b.Failures) == 0 {
+		return nil
+	}
+	return b
+}
@@ -0,0 +1,59 @@
+package discovery
+
+import (
+	"github.com/celeskyking/go-nacos/api"
+	"github.com/celeskyking/go-nacos/internal/nacostest"
+	"github.com/celeskyking/go-nacos/naming"
+	"testing"
+	"time"
+)
+
+func TestClient_RegisterAll(t *testing.T) {
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	nacos.BeatInterval = 20
+	nacos.Reject("", "dev", "gateway-admin")
+	ns := naming.NewNamingService(&api.ServerOptions{
+		Addresses:  []string{nacos.Addr()},
+		LBStrategy: api.RoundRobin,
+	})
+	defer ns.Stop()
+	c := NewDiscoveryClient(ns, &api.DiscoveryOptions{AppName: "gateway", Group: "dev", Cluster: "a"})
+	er := c.RegisterAll(
+		&Registration{ServiceName: "gateway-http", Port: 8080},
+		&Registration{ServiceName: "gateway-grpc", Port: 9090, Metadata: map[string]string{"protocol": "grpc"}},
+		&Registration{ServiceName: "gateway-admin", Port: 8081},
+	)
+	be, ok := er.(*BatchError)
+	if !ok {
+		t.Fatalf("expect *BatchError, got:%+v", er)
+	}
+	if len(be.Failures) != 1 || be.Failures[0].Registration.ServiceName != "gateway-admin" {
+		t.Fatalf("unexpected failures:%+v", be.Failures)
+	}
+	if len(c.Instances()) != 2 {
+		t.Fatalf("expect 2 registered instances, got:%d", len(c.Instances()))
+	}
+	grpc := nacos.Instances("", "dev", "gateway-grpc")
+	if len(grpc) != 1 || grpc[0].Port != 9090 || grpc[0].ClusterName != "a" || grpc[0].Metadata["protocol"] != "grpc" {
+		t.Fatalf("unexpected grpc instance:%+v", grpc)
+	}
+	time.Sleep(150 * time.Millisecond)
+	ports := make(map[string]bool)
+	for _, b := range nacos.Beats() {
+		ports[b.Get("port")] = true
+	}
+	if !ports["8080"] || !ports["9090"] || ports["8081"] {
+		t.Fatalf("expect beats for registered instances only, got:%+v", ports)
+	}
+
+	if er := c.Deregister(); er != nil {
+		t.Fatalf("deregister error:%+v", er)
+	}
+	if len(nacos.Instances("", "dev", "gateway-http")) != 0 || len(nacos.Instances("", "dev", "gateway-grpc")) != 0 {
+		t.Fatalf("expect all instances deregistered")
+	}
+	if len(c.Instances()) != 0 {
+		t.Fatalf("expect no instances left, got:%d", len(c.Instances()))
+	}
+}
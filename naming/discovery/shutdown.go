@@ -0,0 +1,92 @@
+package discovery
+
+import (
+	"context"
+	"github.com/celeskyking/go-nacos/pkg/logger"
+	"github.com/pkg/errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+//DefaultDrainPeriod 下线之前的默认等待时间,需要大于调用方的实例缓存刷新时间
+const DefaultDrainPeriod = 10 * time.Second
+
+//SetDrainPeriod 设置Shutdown的时候实例被禁用之后到注销之前的等待时间
+func (c *Client) SetDrainPeriod(period time.Duration) {
+	c.DrainPeriod = period
+}
+
+//Shutdown 优雅下线,先把实例设置为不可用,等待DrainPeriod让调用方摘除流量,然后注销实例,
+//最后停止心跳和通过当前Client订阅的服务列表。ctx结束的时候会跳过剩余的等待时间直接注销,重复调用只会执行一次
+func (c *Client) Shutdown(ctx context.Context) error {
+	c.shutdownOnce.Do(func() {
+		c.shutdownErr = c.shutdown(ctx)
+	})
+	return c.shutdownErr
+}
+
+func (c *Client) shutdown(ctx context.Context) error {
+	defer c.stopServerLists()
+	//先停止健康检查,避免下线的过程中实例又被重新启用
+	c.stopHealthCheck()
+	instances := c.Instances()
+	if len(instances) == 0 {
+		return nil
+	}
+	disabled := 0
+	for _, instance := range instances {
+		d := *instance
+		d.Enable = false
+		if er := c.naming.UpdateInstance(&d); er != nil {
+			//禁用失败的时候依然需要注销,只是调用方可能会有短暂的失败请求
+			c.naming.Logger().Error("disable instance failed before shutdown", logger.F("service", d.ServiceName), logger.F("port", d.Port), logger.Err(er))
+			continue
+		}
+		disabled++
+	}
+	if disabled > 0 {
+		timer := time.NewTimer(c.DrainPeriod)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			c.naming.Logger().Warn("shutdown context done before drain period elapsed, deregister now")
+		}
+	}
+	if er := c.Deregister(); er != nil {
+		return errors.Wrap(er, "shutdown")
+	}
+	return nil
+}
+
+//ShutdownOnSignal 收到信号之后执行Shutdown,默认监听SIGTERM和SIGINT,timeout为Shutdown的最长时间。
+//返回的chan在Shutdown完成之后收到结果,调用方可以等待之后再退出进程
+func (c *Client) ShutdownOnSignal(timeout time.Duration, signals ...os.Signal) <-chan error {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGTERM, syscall.SIGINT}
+	}
+	sigC := make(chan os.Signal, 1)
+	signal.Notify(sigC, signals...)
+	done := make(chan error, 1)
+	go func() {
+		sig := <-sigC
+		signal.Stop(sigC)
+		c.naming.Logger().Info("received signal, shutdown discovery client", logger.F("signal", sig.String()))
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		done <- c.Shutdown(ctx)
+	}()
+	return done
+}
+
+func (c *Client) stopServerLists() {
+	c.lock.Lock()
+	lists := c.serverLists
+	c.serverLists = nil
+	c.lock.Unlock()
+	for _, sl := range lists {
+		sl.StopListen()
+	}
+}
@@ -0,0 +1,75 @@
+package discovery
+
+import (
+	"context"
+	"github.com/celeskyking/go-nacos/api"
+	"github.com/celeskyking/go-nacos/internal/nacostest"
+	"github.com/celeskyking/go-nacos/naming"
+	"testing"
+	"time"
+)
+
+func TestClient_Shutdown(t *testing.T) {
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	ns := naming.NewNamingService(&api.ServerOptions{
+		Addresses:  []string{nacos.Addr()},
+		LBStrategy: api.RoundRobin,
+	})
+	defer ns.Stop()
+	c := NewDiscoveryClient(ns, &api.DiscoveryOptions{AppName: "demo", Group: "dev", Port: 8080})
+	c.SetInitHealthy(true)
+	c.SetDrainPeriod(200 * time.Millisecond)
+	if er := c.Register(); er != nil {
+		t.Fatalf("register error:%+v", er)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Shutdown(context.Background())
+	}()
+	time.Sleep(100 * time.Millisecond)
+	hosts := nacos.Instances("", "dev", "demo")
+	if len(hosts) != 1 || hosts[0].Enabled {
+		t.Fatalf("expect instance disabled while draining, got:%+v", hosts)
+	}
+	if er := <-done; er != nil {
+		t.Fatalf("shutdown error:%+v", er)
+	}
+	if hosts := nacos.Instances("", "dev", "demo"); len(hosts) != 0 {
+		t.Fatalf("expect instance deregistered, got:%+v", hosts)
+	}
+	beats := len(nacos.Beats())
+	time.Sleep(300 * time.Millisecond)
+	if len(nacos.Beats()) != beats {
+		t.Fatalf("expect beats stopped after shutdown")
+	}
+	if er := c.Shutdown(context.Background()); er != nil {
+		t.Fatalf("second shutdown error:%+v", er)
+	}
+}
+
+func TestClient_ShutdownContextDone(t *testing.T) {
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	ns := naming.NewNamingService(&api.ServerOptions{
+		Addresses:  []string{nacos.Addr()},
+		LBStrategy: api.RoundRobin,
+	})
+	defer ns.Stop()
+	c := NewDiscoveryClient(ns, &api.DiscoveryOptions{AppName: "demo", Group: "dev", Port: 8080})
+	if er := c.Register(); er != nil {
+		t.Fatalf("register error:%+v", er)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if er := c.Shutdown(ctx); er != nil {
+		t.Fatalf("shutdown error:%+v", er)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("expect shutdown to skip the drain period when context done")
+	}
+	if hosts := nacos.Instances("", "dev", "demo"); len(hosts) != 0 {
+		t.Fatalf("expect instance deregistered, got:%+v", hosts)
+	}
+}
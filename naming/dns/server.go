@@ -0,0 +1,353 @@
+//Package dns 把nacos的服务暴露为dns,支持A和SRV查询,域名格式为 service.group.namespace.nacos.
+//
+//namespace为空的时候使用public,SRV查询的域名前面可以带上 _http._tcp 这样的标签。
+//SRV记录的target为 ip.service.group.namespace.nacos. ,ip中的点替换为'-',对应的A记录放在Additional中。
+//
+//服务名,分组或者namespace中带有'.'的时候需要按照dns的规则转义为'\.',例如服务名为 demo.v2 的时候查询 demo\.v2.dev.public.nacos. ,
+//不转义的时候按照标签的个数解析,查询的是其他的服务。
+package dns
+
+import (
+	"github.com/celeskyking/go-nacos/naming"
+	"github.com/celeskyking/go-nacos/pkg/logger"
+	"github.com/celeskyking/go-nacos/types"
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	//Domain 默认的域名后缀
+	Domain = "nacos."
+	//PublicNamespace 域名中表示默认namespace的标签
+	PublicNamespace = "public"
+	//MinTTL 最小的ttl,单位为秒
+	MinTTL = 1
+	//DefaultMaxRecords 默认最多订阅的服务个数
+	DefaultMaxRecords = 1024
+	//DefaultIdleTimeout 默认的服务多久没有被查询之后取消订阅
+	DefaultIdleTimeout = 10 * time.Minute
+)
+
+//Server dns server,第一次查询某个服务的时候订阅服务的实例列表,之后通过订阅更新记录。
+//查询失败或者没有实例的服务不会订阅,超过IdleTimeout没有被查询的服务取消订阅,订阅的服务超过MaxRecords的时候取消最久没有被查询的服务
+type Server struct {
+	//最多订阅的服务个数,Start之前设置,默认为DefaultMaxRecords
+	MaxRecords int
+	//服务多久没有被查询之后取消订阅,Start之前设置,默认为DefaultIdleTimeout
+	IdleTimeout time.Duration
+
+	ns naming.NamingService
+	//域名后缀,默认为nacos.
+	domain string
+
+	lock sync.RWMutex
+
+	records map[string]*record
+	//Shutdown之后不再保存订阅
+	closed bool
+	//同一个服务同时只有一个订阅请求
+	group singleflight.Group
+
+	server *dns.Server
+
+	conn net.PacketConn
+}
+
+//record 一个服务的dns记录
+type record struct {
+	lock sync.RWMutex
+
+	serverList *naming.ServerList
+
+	instances []*types.ServiceInstance
+
+	ttl uint32
+	//最后一次查询的时间,UnixNano
+	accessed int64
+}
+
+//NewServer 创建dns server,domain为空的时候使用Domain
+func NewServer(ns naming.NamingService, domain string) *Server {
+	if domain == "" {
+		domain = Domain
+	}
+	return &Server{
+		MaxRecords:  DefaultMaxRecords,
+		IdleTimeout: DefaultIdleTimeout,
+		ns:          ns,
+		domain:      dns.Fqdn(strings.ToLower(domain)),
+		records:     make(map[string]*record),
+	}
+}
+
+//Start 监听udp地址并且在后台处理查询,addr例如 127.0.0.1:53
+func (s *Server) Start(addr string) error {
+	conn, er := net.ListenPacket("udp", addr)
+	if er != nil {
+		return errors.Wrapf(er, "listen dns server on %s", addr)
+	}
+	s.conn = conn
+	s.server = &dns.Server{PacketConn: conn, Handler: s}
+	go func() {
+		if er := s.server.ActivateAndServe(); er != nil {
+			s.ns.Logger().Error("dns server stopped", logger.Err(er))
+		}
+	}()
+	s.ns.Logger().Info("dns server started", logger.F("addr", conn.LocalAddr().String()), logger.F("domain", s.domain))
+	return nil
+}
+
+//Addr 返回监听的地址,Start之前返回nil
+func (s *Server) Addr() net.Addr {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.LocalAddr()
+}
+
+//Shutdown 停止dns server并且停止所有服务的订阅
+func (s *Server) Shutdown() error {
+	s.lock.Lock()
+	records := s.records
+	s.records = make(map[string]*record)
+	s.closed = true
+	s.lock.Unlock()
+	for _, r := range records {
+		r.serverList.StopListen()
+	}
+	if s.server == nil {
+		return nil
+	}
+	return s.server.Shutdown()
+}
+
+//ServeDNS 实现dns.Handler
+func (s *Server) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+	m.Authoritative = true
+	if len(req.Question) > 0 {
+		s.answer(m, req.Question[0])
+	}
+	if er := w.WriteMsg(m); er != nil {
+		s.ns.Logger().Error("write dns response failed", logger.Err(er))
+	}
+}
+
+func (s *Server) answer(m *dns.Msg, q dns.Question) {
+	//nacos的服务名,分组和namespace区分大小写,只有域名后缀不区分大小写
+	name := dns.Fqdn(q.Name)
+	if !dns.IsSubDomain(s.domain, strings.ToLower(name)) {
+		m.Rcode = dns.RcodeRefused
+		return
+	}
+	labels := dns.SplitDomainName(name[:len(name)-len(s.domain)])
+	for len(labels) > 0 && strings.HasPrefix(labels[0], "_") {
+		labels = labels[1:]
+	}
+	var host string
+	switch len(labels) {
+	case 3:
+	case 4:
+		host = strings.Replace(labels[0], "-", ".", -1)
+		labels = labels[1:]
+	default:
+		m.Rcode = dns.RcodeNameError
+		return
+	}
+	serviceName := dns.Fqdn(strings.Join(labels, ".") + "." + s.domain)
+	r, er := s.lookup(unescape(labels[0]), unescape(labels[1]), unescape(labels[2]))
+	if er != nil {
+		s.ns.Logger().Error("dns lookup failed", logger.F("name", name), logger.Err(er))
+		m.Rcode = dns.RcodeServerFailure
+		return
+	}
+	if r == nil {
+		m.Rcode = dns.RcodeNameError
+		return
+	}
+	instances, ttl := r.snapshot()
+	if host != "" {
+		instances = filterIP(instances, host)
+	}
+	if len(instances) == 0 {
+		m.Rcode = dns.RcodeNameError
+		return
+	}
+	switch q.Qtype {
+	case dns.TypeA:
+		m.Answer = aRecords(q.Name, instances, ttl)
+	case dns.TypeSRV:
+		if host != "" {
+			return
+		}
+		for _, i := range instances {
+			target := strings.Replace(i.IP, ".", "-", -1) + "." + serviceName
+			m.Answer = append(m.Answer, &dns.SRV{
+				Hdr:      dns.RR_Header{Name: q.Name, Rrtype: dns.TypeSRV, Class: dns.ClassINET, Ttl: ttl},
+				Priority: 0,
+				Weight:   srvWeight(i.Weight),
+				Port:     uint16(i.Port),
+				Target:   target,
+			})
+			m.Extra = append(m.Extra, aRecords(target, []*types.ServiceInstance{i}, ttl)...)
+		}
+	}
+}
+
+//lookup 返回服务的记录,第一次查询的时候在锁外订阅服务,服务不存在或者没有实例的时候返回nil
+func (s *Server) lookup(serviceName, group, namespace string) (*record, error) {
+	if strings.EqualFold(namespace, PublicNamespace) {
+		namespace = ""
+	}
+	key := naming.Key(namespace, group, serviceName)
+	now := time.Now()
+	s.lock.RLock()
+	r, ok := s.records[key]
+	s.lock.RUnlock()
+	if ok {
+		atomic.StoreInt64(&r.accessed, now.UnixNano())
+		return r, nil
+	}
+	v, er, _ := s.group.Do(key, func() (interface{}, error) {
+		s.lock.RLock()
+		r, ok := s.records[key]
+		s.lock.RUnlock()
+		if ok {
+			return r, nil
+		}
+		sl, er := s.ns.GetInstances(serviceName, &naming.QueryOptions{
+			Namespace: namespace,
+			Group:     group,
+			Watch:     true,
+		})
+		if er != nil {
+			return nil, er
+		}
+		r = &record{serverList: sl, accessed: now.UnixNano()}
+		r.update(sl.GetAll())
+		//没有实例的服务不订阅,避免任意的域名创建订阅
+		if instances, _ := r.snapshot(); len(instances) == 0 {
+			sl.StopListen()
+			return nil, nil
+		}
+		sl.Subscribe(r.update)
+		s.store(key, r, now)
+		return r, nil
+	})
+	if er != nil || v == nil {
+		return nil, er
+	}
+	return v.(*record), nil
+}
+
+//store 保存订阅的记录,同时取消过期的记录,超过MaxRecords的时候取消最久没有被查询的记录
+func (s *Server) store(key string, r *record, now time.Time) {
+	var evicted []*record
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		r.serverList.StopListen()
+		return
+	}
+	for k, old := range s.records {
+		if s.IdleTimeout > 0 && now.Sub(time.Unix(0, atomic.LoadInt64(&old.accessed))) > s.IdleTimeout {
+			delete(s.records, k)
+			evicted = append(evicted, old)
+		}
+	}
+	for s.MaxRecords > 0 && len(s.records) >= s.MaxRecords {
+		var oldestKey string
+		var oldest int64
+		for k, old := range s.records {
+			if a := atomic.LoadInt64(&old.accessed); oldestKey == "" || a < oldest {
+				oldestKey, oldest = k, a
+			}
+		}
+		evicted = append(evicted, s.records[oldestKey])
+		delete(s.records, oldestKey)
+	}
+	s.records[key] = r
+	s.lock.Unlock()
+	for _, old := range evicted {
+		old.serverList.StopListen()
+	}
+}
+
+//update 只保留健康并且可用的实例,没有健康实例的时候返回全部的实例
+func (r *record) update(instances []*types.ServiceInstance) {
+	var available []*types.ServiceInstance
+	for _, i := range instances {
+		if i.Healthy && i.Enable {
+			available = append(available, i)
+		}
+	}
+	if len(available) == 0 {
+		available = instances
+	}
+	ttl := uint32(r.serverList.GetCacheMillis() / 1000)
+	if ttl < MinTTL {
+		ttl = MinTTL
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.instances = available
+	r.ttl = ttl
+}
+
+func (r *record) snapshot() ([]*types.ServiceInstance, uint32) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.instances, r.ttl
+}
+
+func aRecords(name string, instances []*types.ServiceInstance, ttl uint32) []dns.RR {
+	var rrs []dns.RR
+	seen := make(map[string]bool)
+	for _, i := range instances {
+		ip := net.ParseIP(i.IP).To4()
+		if ip == nil || seen[i.IP] {
+			continue
+		}
+		seen[i.IP] = true
+		rrs = append(rrs, &dns.A{
+			Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: ttl},
+			A:   ip,
+		})
+	}
+	return rrs
+}
+
+func filterIP(instances []*types.ServiceInstance, ip string) []*types.ServiceInstance {
+	var r []*types.ServiceInstance
+	for _, i := range instances {
+		if i.IP == ip {
+			r = append(r, i)
+		}
+	}
+	return r
+}
+
+//unescape 还原标签中转义的'.'
+func unescape(label string) string {
+	return strings.Replace(label, "\\.", ".", -1)
+}
+
+//srvWeight nacos的权重为浮点数,乘以100之后作为SRV的权重,保留两位小数的精度
+func srvWeight(weight float64) uint16 {
+	w := math.Round(weight * 100)
+	if w < 0 {
+		return 0
+	}
+	if w > math.MaxUint16 {
+		return math.MaxUint16
+	}
+	return uint16(w)
+}
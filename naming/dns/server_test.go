@@ -0,0 +1,174 @@
+package dns
+
+import (
+	"github.com/celeskyking/go-nacos/api"
+	"github.com/celeskyking/go-nacos/internal/nacostest"
+	"github.com/celeskyking/go-nacos/naming"
+	"github.com/celeskyking/go-nacos/types"
+	"github.com/miekg/dns"
+	"sort"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T, nacos *nacostest.Server) (*Server, func(name string, qtype uint16) *dns.Msg) {
+	ns := naming.NewNamingService(&api.ServerOptions{
+		Addresses:  []string{nacos.Addr()},
+		LBStrategy: api.RoundRobin,
+		Push:       &api.PushOptions{Disabled: true},
+	})
+	s := NewServer(ns, "")
+	if er := s.Start("127.0.0.1:0"); er != nil {
+		t.Fatalf("start dns server error:%+v", er)
+	}
+	t.Cleanup(func() {
+		_ = s.Shutdown()
+		ns.Stop()
+	})
+	c := new(dns.Client)
+	return s, func(name string, qtype uint16) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetQuestion(name, qtype)
+		r, _, er := c.Exchange(m, s.Addr().String())
+		if er != nil {
+			t.Fatalf("dns exchange error:%+v", er)
+		}
+		return r
+	}
+}
+
+func TestServer_Query(t *testing.T) {
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	nacos.CacheMillis = 2000
+	nacos.SetInstances("", "dev", "demo",
+		&types.Host{IP: "10.0.0.1", Port: 8080, Weight: 1, Healthy: true, Enabled: true},
+		&types.Host{IP: "10.0.0.2", Port: 8081, Weight: 2.5, Healthy: true, Enabled: true},
+		&types.Host{IP: "10.0.0.3", Port: 8082, Weight: 1, Healthy: false, Enabled: true},
+	)
+	_, query := newTestServer(t, nacos)
+
+	r := query("demo.dev.public.nacos.", dns.TypeA)
+	if r.Rcode != dns.RcodeSuccess || len(r.Answer) != 2 {
+		t.Fatalf("expect 2 healthy A records, got:%s", r)
+	}
+	var ips []string
+	for _, rr := range r.Answer {
+		a := rr.(*dns.A)
+		ips = append(ips, a.A.String())
+		if a.Hdr.Ttl != 2 {
+			t.Fatalf("expect ttl from cacheMillis, got:%d", a.Hdr.Ttl)
+		}
+	}
+	sort.Strings(ips)
+	if ips[0] != "10.0.0.1" || ips[1] != "10.0.0.2" {
+		t.Fatalf("unexpected ips:%v", ips)
+	}
+
+	r = query("_http._tcp.demo.dev.public.nacos.", dns.TypeSRV)
+	if r.Rcode != dns.RcodeSuccess || len(r.Answer) != 2 || len(r.Extra) != 2 {
+		t.Fatalf("expect 2 SRV records, got:%s", r)
+	}
+	weights := make(map[uint16]uint16)
+	for _, rr := range r.Answer {
+		srv := rr.(*dns.SRV)
+		weights[srv.Port] = srv.Weight
+	}
+	if weights[8080] != 100 || weights[8081] != 250 {
+		t.Fatalf("unexpected SRV weights:%v", weights)
+	}
+	target := r.Answer[0].(*dns.SRV).Target
+	if a := query(target, dns.TypeA); len(a.Answer) != 1 {
+		t.Fatalf("expect SRV target resolvable, target:%s, got:%s", target, a)
+	}
+
+	nacos.SetInstances("", "DEFAULT_GROUP", "OrderService", &types.Host{IP: "10.0.0.4", Port: 8080, Weight: 1, Healthy: true, Enabled: true})
+	if r := query("OrderService.DEFAULT_GROUP.public.NACOS.", dns.TypeA); r.Rcode != dns.RcodeSuccess || len(r.Answer) != 1 {
+		t.Fatalf("expect case preserved for service and group, got:%s", r)
+	}
+	if r := query("orderservice.default_group.public.nacos.", dns.TypeA); r.Rcode != dns.RcodeNameError {
+		t.Fatalf("expect NXDOMAIN for different case, got:%s", dns.RcodeToString[r.Rcode])
+	}
+	nacos.SetInstances("", "dev", "demo.v2", &types.Host{IP: "10.0.0.5", Port: 8080, Weight: 1, Healthy: true, Enabled: true})
+	r = query(`_http._tcp.demo\.v2.dev.public.nacos.`, dns.TypeSRV)
+	if r.Rcode != dns.RcodeSuccess || len(r.Answer) != 1 {
+		t.Fatalf("expect escaped dot in service name, got:%s", r)
+	}
+	if a := query(r.Answer[0].(*dns.SRV).Target, dns.TypeA); len(a.Answer) != 1 {
+		t.Fatalf("expect SRV target with escaped service name resolvable, got:%s", a)
+	}
+	if r := query("unknown.dev.public.nacos.", dns.TypeA); r.Rcode != dns.RcodeNameError {
+		t.Fatalf("expect NXDOMAIN for unknown service, got:%s", dns.RcodeToString[r.Rcode])
+	}
+	if r := query("demo.dev.nacos.", dns.TypeA); r.Rcode != dns.RcodeNameError {
+		t.Fatalf("expect NXDOMAIN for short name, got:%s", dns.RcodeToString[r.Rcode])
+	}
+	if r := query("example.com.", dns.TypeA); r.Rcode != dns.RcodeRefused {
+		t.Fatalf("expect REFUSED outside domain, got:%s", dns.RcodeToString[r.Rcode])
+	}
+}
+
+func TestServer_Subscribe(t *testing.T) {
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	nacos.CacheMillis = 20
+	nacos.SetInstances("", "dev", "demo", &types.Host{IP: "10.0.0.1", Port: 8080, Weight: 1, Healthy: true, Enabled: true})
+	_, query := newTestServer(t, nacos)
+	if r := query("demo.dev.public.nacos.", dns.TypeA); len(r.Answer) != 1 {
+		t.Fatalf("expect 1 A record, got:%s", r)
+	}
+	nacos.SetInstances("", "dev", "demo",
+		&types.Host{IP: "10.0.0.1", Port: 8080, Weight: 1, Healthy: true, Enabled: true},
+		&types.Host{IP: "10.0.0.2", Port: 8080, Weight: 1, Healthy: true, Enabled: true},
+	)
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if r := query("demo.dev.public.nacos.", dns.TypeA); len(r.Answer) == 2 {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("expect records updated by subscription")
+}
+
+func TestServer_Records(t *testing.T) {
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	for _, name := range []string{"a", "b", "c"} {
+		nacos.SetInstances("", "dev", name, &types.Host{IP: "10.0.0.1", Port: 8080, Weight: 1, Healthy: true, Enabled: true})
+	}
+	s, query := newTestServer(t, nacos)
+	s.lock.Lock()
+	s.MaxRecords = 2
+	s.lock.Unlock()
+	count := func() int {
+		s.lock.RLock()
+		defer s.lock.RUnlock()
+		return len(s.records)
+	}
+	if r := query("unknown.dev.public.nacos.", dns.TypeA); r.Rcode != dns.RcodeNameError || count() != 0 {
+		t.Fatalf("expect unknown service not subscribed, got:%d", count())
+	}
+	query("a.dev.public.nacos.", dns.TypeA)
+	query("b.dev.public.nacos.", dns.TypeA)
+	query("a.dev.public.nacos.", dns.TypeA)
+	if r := query("c.dev.public.nacos.", dns.TypeA); len(r.Answer) != 1 || count() != 2 {
+		t.Fatalf("expect records bounded by MaxRecords, got:%d", count())
+	}
+	s.lock.RLock()
+	_, a := s.records[naming.Key("", "dev", "a")]
+	_, b := s.records[naming.Key("", "dev", "b")]
+	s.lock.RUnlock()
+	if !a || b {
+		t.Fatal("expect least recently queried service evicted")
+	}
+
+	s.lock.Lock()
+	s.IdleTimeout = time.Millisecond
+	s.lock.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	query("b.dev.public.nacos.", dns.TypeA)
+	if count() != 1 {
+		t.Fatalf("expect idle records expired, got:%d", count())
+	}
+}
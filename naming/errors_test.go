@@ -0,0 +1,37 @@
+package naming
+
+import (
+	"errors"
+	"github.com/celeskyking/go-nacos/err"
+	"github.com/celeskyking/go-nacos/internal/nacostest"
+	"github.com/celeskyking/go-nacos/types"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNacosError_Naming(t *testing.T) {
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	nacos.Reject("", "dev", "demo")
+	ns := newTestNamingService(nacos)
+	defer ns.Stop()
+
+	_, er := ns.HttpClient().RegisterServiceInstance(&types.ServiceInstance{
+		IP:          "10.0.0.1",
+		Port:        8080,
+		GroupName:   "dev",
+		ServiceName: "demo",
+		Ephemeral:   true,
+	})
+	var e *err.NacosError
+	if !errors.As(er, &e) {
+		t.Fatalf("expect NacosError, got:%v", er)
+	}
+	if !errors.Is(er, err.ErrInternalServerError) || e.StatusCode != http.StatusInternalServerError || e.Message != "rejected" {
+		t.Fatalf("unexpected error:%+v", e)
+	}
+	if !strings.HasSuffix(nacos.Addr(), e.Server) || e.RequestID == "" {
+		t.Fatalf("expect server and request id recorded, got:%+v", e)
+	}
+}
@@ -0,0 +1,91 @@
+package naming
+
+import (
+	"github.com/celeskyking/go-nacos/pkg/logger"
+	"github.com/celeskyking/go-nacos/types"
+)
+
+//ListGuard 实例列表的保护策略,推送和轮询共用。服务端异常的时候可能返回空列表或者大量实例突然消失,
+//这时候保留上一次的列表,连续确认多次之后才接受新的列表
+type ListGuard struct {
+	//是否开启空列表保护,默认关闭,和java客户端的pushEmptyProtection一致。关闭的时候服务缩容到0个实例之后立即接受空列表
+	EmptyProtection bool
+	//EmptyProtection为true的时候,连续多少次为空之后才接受空列表,小于等于0的时候一直保留上一次非空的列表
+	EmptyConfirmations int
+	//实例数量下降超过这个比例的时候需要确认,例如0.5表示一次少了一半以上的实例,0表示不检查
+	MaxDropRatio float64
+	//实例数量突然下降的时候,连续多少次之后才接受新的列表
+	DropConfirmations int
+}
+
+//DefaultListGuard 默认的保护策略,接受空列表,不检查实例数量的下降
+func DefaultListGuard() *ListGuard {
+	return &ListGuard{}
+}
+
+//guardState 记录连续的可疑列表的次数
+type guardState struct {
+	emptyTimes int
+
+	dropTimes int
+	//被拦截的次数
+	rejected int64
+}
+
+//SetListGuard 设置实例列表的保护策略,为nil的时候使用DefaultListGuard
+func (s *ServerList) SetListGuard(guard *ListGuard) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.guard = guard
+	s.guardState.emptyTimes, s.guardState.dropTimes = 0, 0
+}
+
+//GuardRejected 返回被保护策略拦截的列表的次数
+func (s *ServerList) GuardRejected() int64 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.guardState.rejected
+}
+
+//acceptList 判断是否接受新的实例列表,current为当前使用的列表
+func (s *ServerList) acceptList(current, instances []*types.ServiceInstance) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	guard := s.guard
+	if guard == nil {
+		guard = DefaultListGuard()
+	}
+	state := &s.guardState
+	//还没有可以保留的列表
+	if len(current) == 0 {
+		state.emptyTimes, state.dropTimes = 0, 0
+		return true
+	}
+	if len(instances) == 0 {
+		state.dropTimes = 0
+		state.emptyTimes++
+		if !guard.EmptyProtection || (guard.EmptyConfirmations > 0 && state.emptyTimes >= guard.EmptyConfirmations) {
+			state.emptyTimes = 0
+			return true
+		}
+		state.rejected++
+		s.log.Warn("ignore empty instance list, keep last instances", logger.F("service", s.ServiceName), logger.F("kept", len(current)), logger.F("times", state.emptyTimes))
+		return false
+	}
+	state.emptyTimes = 0
+	if guard.MaxDropRatio > 0 {
+		drop := float64(len(current)-len(instances)) / float64(len(current))
+		if drop > guard.MaxDropRatio {
+			state.dropTimes++
+			if state.dropTimes >= guard.DropConfirmations {
+				state.dropTimes = 0
+				return true
+			}
+			state.rejected++
+			s.log.Warn("instance count dropped, wait for confirmation", logger.F("service", s.ServiceName), logger.F("from", len(current)), logger.F("to", len(instances)), logger.F("times", state.dropTimes))
+			return false
+		}
+	}
+	state.dropTimes = 0
+	return true
+}
@@ -0,0 +1,92 @@
+package naming
+
+import (
+	"github.com/celeskyking/go-nacos/internal/nacostest"
+	"github.com/celeskyking/go-nacos/types"
+	"testing"
+	"time"
+)
+
+func instancesN(n int) []*types.ServiceInstance {
+	var r []*types.ServiceInstance
+	for i := 0; i < n; i++ {
+		r = append(r, &types.ServiceInstance{IP: "10.0.0.1", Port: 8080 + i, Healthy: true, Enable: true})
+	}
+	return r
+}
+
+func TestServerList_EmptyProtection(t *testing.T) {
+	sl := newTestServerList(instancesN(3)...)
+	sl.SetListGuard(&ListGuard{EmptyProtection: true})
+	sl.refresh(nil)
+	sl.refresh(nil)
+	if len(sl.GetAll()) != 3 {
+		t.Fatalf("expect last non-empty list kept without confirmations")
+	}
+	sl.SetListGuard(&ListGuard{EmptyProtection: true, EmptyConfirmations: 3})
+	sl.refresh(nil)
+	sl.refresh(nil)
+	if len(sl.GetAll()) != 3 {
+		t.Fatalf("expect empty list not accepted before confirmations")
+	}
+	//中间出现非空的列表会重新计数
+	sl.refresh(instancesN(3))
+	sl.refresh(nil)
+	sl.refresh(nil)
+	if len(sl.GetAll()) != 3 {
+		t.Fatalf("expect confirmations reset by non-empty list")
+	}
+	sl.refresh(nil)
+	if len(sl.GetAll()) != 0 {
+		t.Fatalf("expect empty list accepted after 3 confirmations")
+	}
+	if sl.GuardRejected() != 6 {
+		t.Fatalf("expect 6 rejected, got:%d", sl.GuardRejected())
+	}
+}
+
+func TestServerList_DropProtection(t *testing.T) {
+	sl := newTestServerList(instancesN(10)...)
+	sl.SetListGuard(&ListGuard{MaxDropRatio: 0.5, DropConfirmations: 2})
+	sl.refresh(instancesN(6))
+	if len(sl.GetAll()) != 6 {
+		t.Fatalf("expect drop within ratio accepted, got:%d", len(sl.GetAll()))
+	}
+	sl.refresh(instancesN(2))
+	if len(sl.GetAll()) != 6 {
+		t.Fatalf("expect sudden drop rejected, got:%d", len(sl.GetAll()))
+	}
+	sl.refresh(instancesN(2))
+	if len(sl.GetAll()) != 2 {
+		t.Fatalf("expect drop accepted after confirmation, got:%d", len(sl.GetAll()))
+	}
+	sl.refresh(instancesN(20))
+	if len(sl.GetAll()) != 20 {
+		t.Fatalf("expect growth accepted, got:%d", len(sl.GetAll()))
+	}
+}
+
+func TestServerList_ScaleToZero(t *testing.T) {
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	nacos.SetInstances("", "dev", "demo", &types.Host{IP: "10.0.0.1", Port: 8080, Healthy: true, Enabled: true})
+	ns := newTestNamingService(nacos)
+	defer ns.Stop()
+	sl, er := ns.GetInstances("demo", &QueryOptions{Group: "dev", ListGuard: &ListGuard{MaxDropRatio: 0.5, DropConfirmations: 2}})
+	if er != nil {
+		t.Fatalf("get instances error:%+v", er)
+	}
+	defer sl.StopListen()
+	if len(sl.GetAll()) != 1 {
+		t.Fatalf("expect 1 instance, got:%d", len(sl.GetAll()))
+	}
+	//服务缩容到0之后,默认的策略通过轮询接受空列表
+	nacos.SetInstances("", "dev", "demo")
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) && len(sl.GetAll()) != 0 {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if len(sl.GetAll()) != 0 || sl.SelectOne() != nil {
+		t.Fatalf("expect empty list accepted after scaling to zero, got:%d", len(sl.GetAll()))
+	}
+}
@@ -0,0 +1,223 @@
+//Package health 实例自身的健康检查,定时执行探针,按照连续失败和连续成功的次数切换健康状态
+package health
+
+import (
+	"context"
+	"github.com/celeskyking/go-nacos/pkg/logger"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultInterval = 5 * time.Second
+
+	DefaultTimeout = 2 * time.Second
+	//连续失败3次之后变为不健康
+	DefaultFailureThreshold = 3
+	//连续成功2次之后恢复健康
+	DefaultSuccessThreshold = 2
+)
+
+//Options 健康检查的配置
+type Options struct {
+	//检查间隔
+	Interval time.Duration
+	//单次检查所有探针的超时时间
+	Timeout time.Duration
+	//连续失败多少次之后变为不健康
+	FailureThreshold int
+	//连续成功多少次之后恢复健康
+	SuccessThreshold int
+	//初始的健康状态
+	InitialHealthy bool
+	//输出日志使用的Logger,为空的时候使用全局的Logger
+	Logger logger.Logger
+}
+
+//DefaultOptions 默认的配置,初始状态为健康
+func DefaultOptions() *Options {
+	return &Options{
+		Interval:         DefaultInterval,
+		Timeout:          DefaultTimeout,
+		FailureThreshold: DefaultFailureThreshold,
+		SuccessThreshold: DefaultSuccessThreshold,
+		InitialHealthy:   true,
+	}
+}
+
+//Listener 健康状态发生变化的时候回调, er为导致变为不健康的最后一次错误
+type Listener func(healthy bool, er error)
+
+//CheckListener 每次检查之后回调当前的健康状态,可以用来和外部保存的状态对账,同步失败的时候在下一次检查重试
+type CheckListener func(healthy bool)
+
+//Checker 定时执行注册的探针,所有的探针都通过才算一次成功
+type Checker struct {
+	options *Options
+
+	log logger.Logger
+
+	lock sync.Mutex
+
+	probes map[string]Probe
+
+	listeners []Listener
+
+	checkListeners []CheckListener
+
+	healthy bool
+
+	failures int
+
+	successes int
+
+	stopC chan struct{}
+
+	startOnce sync.Once
+
+	stopOnce sync.Once
+}
+
+//NewChecker 创建检查器,options为nil的时候使用默认配置
+func NewChecker(options *Options) *Checker {
+	o := DefaultOptions()
+	if options != nil {
+		o = options
+	}
+	if o.Interval <= 0 {
+		o.Interval = DefaultInterval
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultTimeout
+	}
+	if o.FailureThreshold <= 0 {
+		o.FailureThreshold = DefaultFailureThreshold
+	}
+	if o.SuccessThreshold <= 0 {
+		o.SuccessThreshold = DefaultSuccessThreshold
+	}
+	return &Checker{
+		log:     logger.With(o.Logger),
+		options: o,
+		probes:  make(map[string]Probe),
+		healthy: o.InitialHealthy,
+		stopC:   make(chan struct{}),
+	}
+}
+
+//AddProbe 添加探针,同名的探针会被覆盖
+func (c *Checker) AddProbe(name string, probe Probe) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.probes[name] = probe
+}
+
+//RemoveProbe 移除探针
+func (c *Checker) RemoveProbe(name string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.probes, name)
+}
+
+//OnChange 添加健康状态变化的监听
+func (c *Checker) OnChange(listener Listener) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.listeners = append(c.listeners, listener)
+}
+
+//OnCheck 添加每次检查之后的回调
+func (c *Checker) OnCheck(listener CheckListener) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.checkListeners = append(c.checkListeners, listener)
+}
+
+//Healthy 当前的健康状态
+func (c *Checker) Healthy() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.healthy
+}
+
+//Start 启动检查的goroutine,重复调用只会启动一次
+func (c *Checker) Start() {
+	c.startOnce.Do(func() {
+		go c.run()
+	})
+}
+
+//Stop 停止检查
+func (c *Checker) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stopC)
+	})
+}
+
+func (c *Checker) run() {
+	ticker := time.NewTicker(c.options.Interval)
+	defer ticker.Stop()
+	for {
+		c.CheckOnce()
+		select {
+		case <-c.stopC:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+//CheckOnce 执行一次所有的探针并且更新健康状态
+func (c *Checker) CheckOnce() {
+	c.lock.Lock()
+	probes := make(map[string]Probe, len(c.probes))
+	for name, p := range c.probes {
+		probes[name] = p
+	}
+	c.lock.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), c.options.Timeout)
+	defer cancel()
+	var failed error
+	for name, p := range probes {
+		if er := p.Check(ctx); er != nil {
+			c.log.Warn("health probe failed", logger.F("name", name), logger.Err(er))
+			failed = er
+			break
+		}
+	}
+	c.record(failed)
+}
+
+//record 按照连续失败和连续成功的次数切换状态,避免抖动
+func (c *Checker) record(failed error) {
+	c.lock.Lock()
+	changed := false
+	if failed != nil {
+		c.successes = 0
+		c.failures++
+		if c.healthy && c.failures >= c.options.FailureThreshold {
+			c.healthy = false
+			changed = true
+		}
+	} else {
+		c.failures = 0
+		c.successes++
+		if !c.healthy && c.successes >= c.options.SuccessThreshold {
+			c.healthy = true
+			changed = true
+		}
+	}
+	healthy := c.healthy
+	listeners := c.listeners
+	checkListeners := c.checkListeners
+	c.lock.Unlock()
+	if changed {
+		c.log.Info("health status changed", logger.F("healthy", healthy))
+		for _, l := range listeners {
+			l(healthy, failed)
+		}
+	}
+	for _, l := range checkListeners {
+		l(healthy)
+	}
+}
@@ -0,0 +1,79 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChecker_Hysteresis(t *testing.T) {
+	failing := false
+	c := NewChecker(&Options{FailureThreshold: 3, SuccessThreshold: 2, InitialHealthy: true})
+	c.AddProbe("func", ProbeFunc(func(ctx context.Context) error {
+		if failing {
+			return errors.New("down")
+		}
+		return nil
+	}))
+	var changes []bool
+	c.OnChange(func(healthy bool, er error) {
+		changes = append(changes, healthy)
+	})
+	failing = true
+	c.CheckOnce()
+	c.CheckOnce()
+	if !c.Healthy() {
+		t.Fatalf("expect healthy before reaching failure threshold")
+	}
+	c.CheckOnce()
+	if c.Healthy() {
+		t.Fatalf("expect unhealthy after 3 failures")
+	}
+	failing = false
+	c.CheckOnce()
+	if c.Healthy() {
+		t.Fatalf("expect unhealthy before reaching success threshold")
+	}
+	failing = true
+	c.CheckOnce()
+	failing = false
+	c.CheckOnce()
+	c.CheckOnce()
+	if !c.Healthy() {
+		t.Fatalf("expect healthy after 2 consecutive successes")
+	}
+	if len(changes) != 2 || changes[0] || !changes[1] {
+		t.Fatalf("unexpected changes:%v", changes)
+	}
+}
+
+func TestProbes(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	ctx := context.Background()
+	if er := HTTPProbe(server.URL).Check(ctx); er != nil {
+		t.Fatalf("http probe error:%+v", er)
+	}
+	status = http.StatusServiceUnavailable
+	if er := HTTPProbe(server.URL).Check(ctx); er == nil {
+		t.Fatalf("expect http probe failed with 503")
+	}
+	if er := TCPProbe(server.Listener.Addr().String()).Check(ctx); er != nil {
+		t.Fatalf("tcp probe error:%+v", er)
+	}
+	l, er := net.Listen("tcp", "127.0.0.1:0")
+	if er != nil {
+		t.Fatal(er)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+	if er := TCPProbe(addr).Check(ctx); er == nil {
+		t.Fatalf("expect tcp probe failed on closed port")
+	}
+}
@@ -0,0 +1,51 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+//Probe 健康检查的探针,返回nil表示检查通过
+type Probe interface {
+	Check(ctx context.Context) error
+}
+
+//ProbeFunc 函数形式的探针
+type ProbeFunc func(ctx context.Context) error
+
+func (f ProbeFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+//HTTPProbe 请求url,返回2xx或者3xx的时候检查通过
+func HTTPProbe(url string) Probe {
+	return ProbeFunc(func(ctx context.Context) error {
+		req, er := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if er != nil {
+			return er
+		}
+		resp, er := http.DefaultClient.Do(req)
+		if er != nil {
+			return er
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+			return fmt.Errorf("http probe %s, unexpected status code:%d", url, resp.StatusCode)
+		}
+		return nil
+	})
+}
+
+//TCPProbe 能够建立tcp连接的时候检查通过,addr格式为host:port
+func TCPProbe(addr string) Probe {
+	return ProbeFunc(func(ctx context.Context) error {
+		var d net.Dialer
+		conn, er := d.DialContext(ctx, "tcp", addr)
+		if er != nil {
+			return er
+		}
+		return conn.Close()
+	})
+}
@@ -2,72 +2,43 @@ package beat
 
 import (
 	v1 "github.com/celeskyking/go-nacos/api/ns/v1"
-	"github.com/celeskyking/go-nacos/pkg/util"
 	"github.com/celeskyking/go-nacos/types"
-	"github.com/sirupsen/logrus"
-	"time"
 )
 
-//HeartBeat
+const (
+	//DefaultInterval 服务端没有返回心跳间隔时使用的默认间隔,毫秒
+	DefaultInterval = 5000
+)
+
+//HeartBeat 单个实例的心跳,多个实例的时候使用Scheduler共享调度
 type HeartBeatService interface {
 
 	//开始
 	Start(stop <-chan struct{})
+
+	//AddListener 添加心跳事件的监听器
+	AddListener(listener EventListener)
 }
 
 func NewHeartBeatService(client v1.NamingHttpClient, instance *types.ServiceInstance) HeartBeatService {
 	return &heartBeatService{
-		client:   client,
-		Instance: instance,
+		scheduler: NewScheduler(client),
+		Instance:  instance,
 	}
 }
 
 type heartBeatService struct {
-	client v1.NamingHttpClient
+	scheduler Scheduler
 
 	Instance *types.ServiceInstance
 }
 
-func (h *heartBeatService) Start(stop <-chan struct{}) {
-	interval := h.beat()
-	timer := time.NewTimer(time.Duration(interval) * time.Millisecond)
-	for {
-		select {
-		case <-timer.C:
-			//logrus.Info("heart beat")
-			interval = h.beat()
-			timer.Reset(time.Duration(interval) * time.Millisecond)
-		case <-stop:
-			timer.Stop()
-			logrus.Infof("heartbeat stopped")
-			return
-		}
-	}
+func (h *heartBeatService) AddListener(listener EventListener) {
+	h.scheduler.AddListener(listener)
 }
 
-func (h *heartBeatService) beat() int {
-	reties := 0
-	maxDelay := 30
-	for {
-		r, err := h.client.HeartBeat(&types.HeartBeat{
-			ServiceName: h.Instance.ServiceName,
-			NamespaceID: h.Instance.NamespaceID,
-			GroupName:   h.Instance.GroupName,
-			Beat: &types.Beat{
-				ServiceName: h.Instance.ServiceName,
-				IP:          h.Instance.IP,
-				Port:        h.Instance.Port,
-				Cluster:     h.Instance.ClusterName,
-				Weight:      h.Instance.Weight,
-				Metadata:    util.ToMap(h.Instance.Metadata),
-			},
-		})
-		if err == nil {
-			return r.ClientBeatInterval
-		} else {
-			logrus.Errorf("send heart beat error:%+v", err)
-			reties = reties + 1
-			time.Sleep(time.Duration(util.Min(reties, maxDelay)) * time.Second)
-		}
-	}
+func (h *heartBeatService) Start(stop <-chan struct{}) {
+	h.scheduler.Add(h.Instance)
+	<-stop
+	h.scheduler.Stop()
 }
@@ -0,0 +1,61 @@
+package beat
+
+import (
+	"github.com/celeskyking/go-nacos/api"
+	v1 "github.com/celeskyking/go-nacos/api/ns/v1"
+	"github.com/celeskyking/go-nacos/internal/nacostest"
+	"github.com/celeskyking/go-nacos/types"
+	"testing"
+	"time"
+)
+
+func TestHeartBeatService_Reregister(t *testing.T) {
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	nacos.BeatInterval = 20
+	option := api.DefaultOption()
+	option.Servers = []string{nacos.Addr()}
+	client := v1.NewNamingHttpClient(option)
+	instance := &types.ServiceInstance{
+		IP:          "10.0.0.1",
+		Port:        8080,
+		ServiceName: "demo",
+		GroupName:   "dev",
+		Weight:      2.5,
+		Healthy:     true,
+		Enable:      true,
+		Ephemeral:   true,
+		Metadata:    map[string]string{"version": "v2"},
+	}
+	if _, er := client.RegisterServiceInstance(instance); er != nil {
+		t.Fatalf("register error:%+v", er)
+	}
+	events := make(chan *Event, 10)
+	h := NewHeartBeatService(client, instance)
+	h.AddListener(func(event *Event) {
+		events <- event
+	})
+	stop := make(chan struct{})
+	defer close(stop)
+	go h.Start(stop)
+
+	time.Sleep(100 * time.Millisecond)
+	//模拟nacos节点重启丢失了临时实例
+	nacos.Drop()
+	select {
+	case e := <-events:
+		if e.Type != Reregistered {
+			t.Fatalf("unexpected event:%s, error:%+v", e.Type, e.Error)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("instance not re-registered")
+	}
+	hosts := nacos.Instances("", "dev", "demo")
+	if len(hosts) != 1 {
+		t.Fatalf("expect 1 instance, got:%d", len(hosts))
+	}
+	h1 := hosts[0]
+	if h1.Weight != 2.5 || !h1.Healthy || !h1.Enabled || !h1.Ephemeral || h1.Metadata["version"] != "v2" {
+		t.Fatalf("instance not fully re-registered:%+v", h1)
+	}
+}
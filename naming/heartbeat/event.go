@@ -0,0 +1,45 @@
+package beat
+
+import (
+	"github.com/celeskyking/go-nacos/types"
+)
+
+const (
+	//ResourceNotFound 服务端找不到实例时心跳返回的code,一般是nacos节点重启之后丢失了临时实例
+	ResourceNotFound = 20404
+)
+
+type EventType int
+
+const (
+	//心跳发送失败
+	BeatFailed EventType = iota
+	//服务端丢失了实例,重新注册成功
+	Reregistered
+	//服务端丢失了实例,重新注册失败,下一次心跳的时候会继续尝试
+	ReregisterFailed
+)
+
+func (e EventType) String() string {
+	switch e {
+	case BeatFailed:
+		return "BeatFailed"
+	case Reregistered:
+		return "Reregistered"
+	case ReregisterFailed:
+		return "ReregisterFailed"
+	}
+	return "Unknown"
+}
+
+//Event 心跳的事件
+type Event struct {
+	Type EventType
+
+	Instance *types.ServiceInstance
+
+	Error error
+}
+
+//EventListener 心跳事件的监听器
+type EventListener func(event *Event)
@@ -0,0 +1,354 @@
+package beat
+
+import (
+	v1 "github.com/celeskyking/go-nacos/api/ns/v1"
+	"github.com/celeskyking/go-nacos/err"
+	"github.com/celeskyking/go-nacos/pkg/logger"
+	"github.com/celeskyking/go-nacos/pkg/stats"
+	"github.com/celeskyking/go-nacos/pkg/util"
+	"github.com/celeskyking/go-nacos/types"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	//DefaultJitter 心跳间隔的随机抖动比例,避免大量实例在同一时刻发送心跳
+	DefaultJitter = 0.1
+	//MaxRetryDelay 心跳失败之后重试的最大间隔,秒
+	MaxRetryDelay = 30
+	//DefaultWorkers 同时发送心跳的最大goroutine数,单个心跳请求超时重试的时候不会阻塞其他实例的心跳
+	DefaultWorkers = 8
+)
+
+//Scheduler 心跳调度器,同一个进程内注册的多个实例共享一个调度的goroutine,到期的心跳交给最多DefaultWorkers个goroutine并发发送
+type Scheduler interface {
+	//Add 添加实例的心跳,立即发送第一次心跳,重复添加会覆盖之前的实例信息
+	Add(instance *types.ServiceInstance)
+	//Remove 移除实例的心跳
+	Remove(instance *types.ServiceInstance)
+	//Update 更新已经调度的实例信息(例如enabled和权重),服务端丢失实例之后按照最新的信息重新注册,实例没有调度的时候忽略
+	Update(instance *types.ServiceInstance)
+	//AddListener 添加心跳事件的监听器
+	AddListener(listener EventListener)
+	//Stop 停止所有的心跳
+	Stop()
+}
+
+//NewScheduler 返回心跳调度器,第一次Add的时候启动调度的goroutine
+func NewScheduler(client v1.NamingHttpClient) Scheduler {
+	return NewSchedulerWithLogger(client, nil)
+}
+
+//NewSchedulerWithLogger 返回使用l输出日志的心跳调度器,l为nil的时候使用全局的Logger
+func NewSchedulerWithLogger(client v1.NamingHttpClient, l logger.Logger) Scheduler {
+	return NewSchedulerWithOptions(client, &Options{Logger: l})
+}
+
+//Options 心跳调度器的配置
+type Options struct {
+	//输出日志使用的Logger,为空的时候使用全局的Logger
+	Logger logger.Logger
+	//记录心跳失败使用的Recorder,为空的时候使用全局的Recorder
+	Recorder stats.Recorder
+}
+
+//NewSchedulerWithOptions 按照options创建心跳调度器
+func NewSchedulerWithOptions(client v1.NamingHttpClient, options *Options) Scheduler {
+	if options == nil {
+		options = &Options{}
+	}
+	return &scheduler{
+		client:      client,
+		tasks:       make(map[string]*task),
+		wakeC:       make(chan struct{}, 1),
+		workers:     make(chan struct{}, DefaultWorkers),
+		stopC:       make(chan struct{}),
+		jitterRatio: DefaultJitter,
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
+		log:         logger.With(options.Logger),
+		rec:         stats.With(options.Recorder),
+	}
+}
+
+//task 单个实例的心跳任务
+type task struct {
+	instance *types.ServiceInstance
+	//下一次心跳的时间
+	next time.Time
+	//服务端开启了light beat之后,心跳不再携带beat的内容
+	lightBeat bool
+
+	retries int
+	//心跳正在发送,发送完成之前不会再次调度
+	running bool
+}
+
+type scheduler struct {
+	client v1.NamingHttpClient
+
+	lock sync.Mutex
+
+	tasks map[string]*task
+
+	listeners []EventListener
+
+	jitterRatio float64
+
+	rand *rand.Rand
+
+	started bool
+
+	stopped bool
+
+	wakeC chan struct{}
+	//限制同时发送心跳的goroutine数
+	workers chan struct{}
+
+	stopC chan struct{}
+
+	log logger.Logger
+
+	rec stats.Recorder
+}
+
+//TaskKey 返回实例心跳任务的key
+func TaskKey(instance *types.ServiceInstance) string {
+	return strings.Join([]string{instance.NamespaceID, instance.GroupName, instance.ServiceName, instance.ClusterName, instance.IP, strconv.Itoa(instance.Port)}, "@@")
+}
+
+func (s *scheduler) Add(instance *types.ServiceInstance) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.stopped {
+		return
+	}
+	s.tasks[TaskKey(instance)] = &task{
+		instance: instance,
+		next:     time.Now(),
+	}
+	if !s.started {
+		s.started = true
+		go s.run()
+	}
+	s.wake()
+}
+
+func (s *scheduler) Remove(instance *types.ServiceInstance) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.tasks, TaskKey(instance))
+	s.wake()
+}
+
+func (s *scheduler) Update(instance *types.ServiceInstance) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if t, ok := s.tasks[TaskKey(instance)]; ok {
+		i := *instance
+		t.instance = &i
+	}
+}
+
+//scheduled 任务是否仍然在调度,Remove或者重新Add之后返回false
+func (s *scheduler) scheduled(t *task) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.tasks[TaskKey(t.instance)] == t
+}
+
+func (s *scheduler) AddListener(listener EventListener) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+func (s *scheduler) Stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.stopped {
+		return
+	}
+	s.stopped = true
+	s.tasks = make(map[string]*task)
+	close(s.stopC)
+}
+
+func (s *scheduler) wake() {
+	select {
+	case s.wakeC <- struct{}{}:
+	default:
+	}
+}
+
+func (s *scheduler) emit(event *Event) {
+	s.lock.Lock()
+	listeners := s.listeners
+	s.lock.Unlock()
+	for _, l := range listeners {
+		l(event)
+	}
+}
+
+func (s *scheduler) run() {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-s.stopC:
+			s.log.Info("heartbeat stopped")
+			return
+		case <-s.wakeC:
+		case <-timer.C:
+		}
+		for _, t := range s.dueTasks() {
+			go s.dispatch(t)
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(s.nextDelay())
+	}
+}
+
+//dueTasks 返回已经到期并且没有在发送的心跳任务,返回的任务标记为正在发送
+func (s *scheduler) dueTasks() []*task {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := time.Now()
+	var due []*task
+	for _, t := range s.tasks {
+		if !t.running && !t.next.After(now) {
+			t.running = true
+			due = append(due, t)
+		}
+	}
+	return due
+}
+
+//dispatch 等待空闲的worker发送心跳,发送完成之后唤醒调度的goroutine计算下一次心跳的时间
+func (s *scheduler) dispatch(t *task) {
+	select {
+	case s.workers <- struct{}{}:
+	case <-s.stopC:
+		return
+	}
+	s.beat(t)
+	<-s.workers
+	s.lock.Lock()
+	t.running = false
+	s.lock.Unlock()
+	s.wake()
+}
+
+//nextDelay 距离下一个心跳任务的时间
+func (s *scheduler) nextDelay() time.Duration {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delay := time.Duration(DefaultInterval) * time.Millisecond
+	now := time.Now()
+	for _, t := range s.tasks {
+		if t.running {
+			continue
+		}
+		if d := t.next.Sub(now); d < delay {
+			delay = d
+		}
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return delay
+}
+
+func (s *scheduler) beat(t *task) {
+	s.lock.Lock()
+	instance := t.instance
+	lightBeat := t.lightBeat
+	s.lock.Unlock()
+	req := &types.HeartBeat{
+		ServiceName: instance.ServiceName,
+		NamespaceID: instance.NamespaceID,
+		GroupName:   instance.GroupName,
+		IP:          instance.IP,
+		Port:        instance.Port,
+		ClusterName: instance.ClusterName,
+	}
+	if !lightBeat {
+		req.Beat = &types.Beat{
+			ServiceName: instance.ServiceName,
+			IP:          instance.IP,
+			Port:        instance.Port,
+			Cluster:     instance.ClusterName,
+			Weight:      instance.Weight,
+			Metadata:    instance.Metadata,
+		}
+	}
+	r, er := s.client.HeartBeat(req)
+	if er != nil {
+		s.log.Error("send heart beat error", logger.F("service", instance.ServiceName), logger.F("ip", instance.IP), logger.F("port", instance.Port), logger.Err(er))
+		s.rec.HeartbeatFailed(instance.ServiceName)
+		s.emit(&Event{Type: BeatFailed, Instance: instance, Error: er})
+		s.lock.Lock()
+		t.retries++
+		t.next = time.Now().Add(time.Duration(util.Min(t.retries, MaxRetryDelay)) * time.Second)
+		s.lock.Unlock()
+		return
+	}
+	interval := r.ClientBeatInterval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	light := r.LightBeatEnabled
+	if r.Code == ResourceNotFound {
+		s.reregister(t)
+		light = false
+	}
+	s.lock.Lock()
+	t.retries = 0
+	t.lightBeat = light
+	t.next = time.Now().Add(s.jitter(time.Duration(interval) * time.Millisecond))
+	s.lock.Unlock()
+}
+
+//jitter 在心跳间隔的基础上增加随机的抖动,调用方需要持有锁
+func (s *scheduler) jitter(interval time.Duration) time.Duration {
+	if s.jitterRatio <= 0 {
+		return interval
+	}
+	delta := (s.rand.Float64()*2 - 1) * s.jitterRatio * float64(interval)
+	return interval + time.Duration(delta)
+}
+
+//reregister 服务端丢失了当前的实例,使用任务中最新的实例信息重新注册。心跳的过程中任务已经移除的时候不会重新注册,
+//重新注册的过程中任务被移除的时候注销刚注册的实例,避免Deregister之后实例又出现在服务端
+func (s *scheduler) reregister(t *task) {
+	if !s.scheduled(t) {
+		return
+	}
+	s.lock.Lock()
+	instance := t.instance
+	s.lock.Unlock()
+	s.log.Warn("instance not found in nacos server, re-register it", logger.F("service", instance.ServiceName), logger.F("ip", instance.IP), logger.F("port", instance.Port))
+	r, er := s.client.RegisterServiceInstance(instance)
+	if er == nil && !r.Success {
+		er = err.ErrNamingService
+	}
+	if er != nil {
+		s.log.Error("re-register instance error", logger.F("service", instance.ServiceName), logger.Err(er))
+		s.emit(&Event{Type: ReregisterFailed, Instance: instance, Error: er})
+		return
+	}
+	if !s.scheduled(t) {
+		if _, er := s.client.DeRegisterServiceInstance(instance); er != nil {
+			s.log.Error("deregister removed instance error", logger.F("service", instance.ServiceName), logger.Err(er))
+		}
+		return
+	}
+	s.emit(&Event{Type: Reregistered, Instance: instance})
+}
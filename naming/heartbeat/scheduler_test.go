@@ -0,0 +1,152 @@
+package beat
+
+import (
+	"github.com/celeskyking/go-nacos/api"
+	v1 "github.com/celeskyking/go-nacos/api/ns/v1"
+	"github.com/celeskyking/go-nacos/internal/nacostest"
+	"github.com/celeskyking/go-nacos/types"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScheduler_LightBeat(t *testing.T) {
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	nacos.BeatInterval = 20
+	nacos.LightBeatEnabled = true
+	option := api.DefaultOption()
+	option.Servers = []string{nacos.Addr()}
+	client := v1.NewNamingHttpClient(option)
+	s := NewScheduler(client)
+	defer s.Stop()
+	for _, port := range []int{8080, 8081} {
+		instance := &types.ServiceInstance{
+			IP:          "10.0.0.1",
+			Port:        port,
+			ServiceName: "demo",
+			GroupName:   "dev",
+			Weight:      1,
+			Enable:      true,
+			Ephemeral:   true,
+		}
+		if _, er := client.RegisterServiceInstance(instance); er != nil {
+			t.Fatalf("register error:%+v", er)
+		}
+		s.Add(instance)
+	}
+	time.Sleep(300 * time.Millisecond)
+
+	full := make(map[string]int)
+	light := make(map[string]int)
+	for _, b := range nacos.Beats() {
+		if b.Get("beat") != "" {
+			full[b.Get("port")]++
+		} else {
+			light[b.Get("port")]++
+		}
+	}
+	for _, port := range []int{8080, 8081} {
+		p := strconv.Itoa(port)
+		if full[p] != 1 {
+			t.Errorf("port %s: expect only the first beat carries beat info, got:%d", p, full[p])
+		}
+		if light[p] < 3 {
+			t.Errorf("port %s: expect light beats, got:%d", p, light[p])
+		}
+	}
+}
+
+func TestScheduler_Jitter(t *testing.T) {
+	s := NewScheduler(nil).(*scheduler)
+	interval := time.Second
+	min, max := interval, interval
+	for i := 0; i < 1000; i++ {
+		d := s.jitter(interval)
+		if d < min {
+			min = d
+		}
+		if d > max {
+			max = d
+		}
+	}
+	if min < 900*time.Millisecond || max > 1100*time.Millisecond || min == max {
+		t.Fatalf("unexpected jitter range:[%s,%s]", min, max)
+	}
+}
+
+func TestScheduler_ReregisterUpdated(t *testing.T) {
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	nacos.BeatInterval = 20
+	option := api.DefaultOption()
+	option.Servers = []string{nacos.Addr()}
+	client := v1.NewNamingHttpClient(option)
+	s := NewScheduler(client)
+	defer s.Stop()
+	events := make(chan *Event, 10)
+	s.AddListener(func(event *Event) {
+		events <- event
+	})
+	instance := &types.ServiceInstance{IP: "10.0.0.1", Port: 8080, ServiceName: "demo", GroupName: "dev", Weight: 1, Enable: true, Ephemeral: true}
+	removed := &types.ServiceInstance{IP: "10.0.0.1", Port: 8081, ServiceName: "demo", GroupName: "dev", Weight: 1, Enable: true, Ephemeral: true}
+	for _, i := range []*types.ServiceInstance{instance, removed} {
+		if _, er := client.RegisterServiceInstance(i); er != nil {
+			t.Fatalf("register error:%+v", er)
+		}
+		s.Add(i)
+	}
+	//禁用之后重新注册的实例依然是禁用的,已经移除的实例不会重新注册
+	disabled := *instance
+	disabled.Enable = false
+	disabled.Weight = 3
+	s.Update(&disabled)
+	s.Remove(removed)
+	nacos.Drop()
+	select {
+	case e := <-events:
+		if e.Type != Reregistered || e.Instance.Port != 8080 {
+			t.Fatalf("unexpected event:%s, instance:%+v", e.Type, e.Instance)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("instance not re-registered")
+	}
+	time.Sleep(100 * time.Millisecond)
+	hosts := nacos.Instances("", "dev", "demo")
+	if len(hosts) != 1 || hosts[0].Port != 8080 || hosts[0].Enabled || hosts[0].Weight != 3 {
+		t.Fatalf("expect only the updated instance re-registered, got:%+v", hosts)
+	}
+}
+
+//slowClient 8080端口的心跳阻塞到release关闭,其他实例的心跳立即返回
+type slowClient struct {
+	v1.NamingHttpClient
+
+	release chan struct{}
+
+	beats int32
+}
+
+func (c *slowClient) HeartBeat(beat *types.HeartBeat) (*types.HeartBeatResult, error) {
+	if beat.Port == 8080 {
+		<-c.release
+	} else {
+		atomic.AddInt32(&c.beats, 1)
+	}
+	return &types.HeartBeatResult{ClientBeatInterval: 20}, nil
+}
+
+func TestScheduler_SlowBeat(t *testing.T) {
+	client := &slowClient{release: make(chan struct{})}
+	defer close(client.release)
+	s := NewScheduler(client)
+	defer s.Stop()
+	for _, port := range []int{8080, 8081} {
+		s.Add(&types.ServiceInstance{IP: "10.0.0.1", Port: port, ServiceName: "demo", GroupName: "dev", Ephemeral: true})
+	}
+	time.Sleep(300 * time.Millisecond)
+	if n := atomic.LoadInt32(&client.beats); n < 5 {
+		t.Fatalf("expect beats not blocked by slow instance, got:%d", n)
+	}
+}
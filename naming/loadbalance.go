@@ -4,6 +4,7 @@ import (
 	"github.com/celeskyking/go-nacos/types"
 	"math/rand"
 	"reflect"
+	"sync"
 	"time"
 )
 
@@ -30,9 +31,13 @@ func NewRandom() InternalLB {
 
 type Random struct {
 	Servers []*types.ServiceInstance
+
+	lock sync.RWMutex
 }
 
 func (r *Random) Refresh(instances []*types.ServiceInstance) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
 	if len(r.Servers) == 0 {
 		r.Servers = instances
 	}
@@ -42,12 +47,14 @@ func (r *Random) Refresh(instances []*types.ServiceInstance) {
 }
 
 func (r *Random) GetAll() []*types.ServiceInstance {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
 	return r.Servers
 }
 
 func (r *Random) SelectOne(filter func(instance *types.ServiceInstance) bool) *types.ServiceInstance {
 	var servers []*types.ServiceInstance
-	for _, i := range r.Servers {
+	for _, i := range r.GetAll() {
 		if filter == nil || filter(i) {
 			servers = append(servers, i)
 		}
@@ -56,5 +63,5 @@ func (r *Random) SelectOne(filter func(instance *types.ServiceInstance) bool) *t
 	if l == 0 || servers == nil {
 		return nil
 	}
-	return servers[rand.Intn(l-1)]
+	return servers[rand.Intn(l)]
 }
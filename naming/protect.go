@@ -0,0 +1,135 @@
+package naming
+
+import (
+	"github.com/celeskyking/go-nacos/pkg/logger"
+	"github.com/celeskyking/go-nacos/types"
+	"time"
+)
+
+//ProtectEvent 保护阈值状态变化的事件
+type ProtectEvent struct {
+	ServiceName string
+
+	GroupName string
+
+	Clusters string
+	//健康的实例数
+	HealthyCount int
+	//全部的实例数
+	TotalCount int
+	//服务的保护阈值
+	Threshold float64
+	//true为进入保护状态,false为退出保护状态
+	Protected bool
+}
+
+//ProtectListener 保护状态变更的监听器
+type ProtectListener func(event *ProtectEvent)
+
+//protectFilter 根据保护阈值返回实例的过滤条件
+//健康实例的比例小于等于保护阈值的时候,和nacos server的逻辑一致,退化为选择全部的实例,避免剩余的健康实例被打垮
+func (s *ServerList) protectFilter(all []*types.ServiceInstance) func(instance *types.ServiceInstance) bool {
+	healthy := 0
+	for _, i := range all {
+		if i.Healthy {
+			healthy++
+		}
+	}
+	threshold := s.GetProtectThreshold()
+	total := len(all)
+	protected := total > 0 && float64(healthy)/float64(total) <= threshold
+	s.setProtected(protected, healthy, total, threshold)
+	if protected {
+		return nil
+	}
+	return func(instance *types.ServiceInstance) bool {
+		return instance.Healthy
+	}
+}
+
+//setProtected 记录保护状态,状态变化的时候通知监听器。SelectOne每次都会调用,状态没有变化的时候只持有读锁
+func (s *ServerList) setProtected(protected bool, healthy, total int, threshold float64) {
+	if s.Protected() == protected {
+		return
+	}
+	s.lock.Lock()
+	if s.protected == protected {
+		s.lock.Unlock()
+		return
+	}
+	s.protected = protected
+	if protected {
+		s.protectTimes++
+	}
+	listeners := s.protectListeners
+	s.lock.Unlock()
+	s.rec.ServiceProtected(s.NamespaceId, s.GroupName, s.ServiceName, protected)
+	event := &ProtectEvent{
+		ServiceName:  s.ServiceName,
+		GroupName:    s.GroupName,
+		Clusters:     s.Clusters,
+		HealthyCount: healthy,
+		TotalCount:   total,
+		Threshold:    threshold,
+		Protected:    protected,
+	}
+	for _, l := range listeners {
+		l(event)
+	}
+}
+
+//OnProtect 注册保护状态变化的监听器,进入和退出保护状态的时候都会触发
+func (s *ServerList) OnProtect(listener ProtectListener) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.protectListeners = append(s.protectListeners, listener)
+}
+
+//Protected 当前是否处于保护状态
+func (s *ServerList) Protected() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.protected
+}
+
+//ProtectTimes 进入保护状态的累计次数
+func (s *ServerList) ProtectTimes() int64 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.protectTimes
+}
+
+//GetProtectThreshold 返回服务的保护阈值
+func (s *ServerList) GetProtectThreshold() float64 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.protectThreshold
+}
+
+//SetProtectThreshold 设置服务的保护阈值,一般由服务端的配置刷新
+func (s *ServerList) SetProtectThreshold(threshold float64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.protectThreshold = threshold
+}
+
+//refreshProtectThreshold 从服务端刷新保护阈值,距离上一次刷新不到ProtectRefreshInterval的时候跳过,避免每次轮询都请求服务详情
+func (s *ServerList) refreshProtectThreshold() {
+	s.lock.Lock()
+	if !s.protectRefreshedAt.IsZero() && time.Since(s.protectRefreshedAt) < ProtectRefreshInterval {
+		s.lock.Unlock()
+		return
+	}
+	s.protectRefreshedAt = time.Now()
+	s.lock.Unlock()
+	detail, er := s.httpClient.GetService(&types.Service{
+		ServiceName: s.ServiceName,
+		GroupName:   s.GroupName,
+		NamespaceId: s.NamespaceId,
+	})
+	if er != nil {
+		s.log.Error("get service protect threshold failed", logger.F("service", s.ServiceName), logger.Err(er))
+		return
+	}
+	s.SetProtectThreshold(detail.ProtectThreshold)
+}
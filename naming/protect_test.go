@@ -0,0 +1,98 @@
+package naming
+
+import (
+	"github.com/celeskyking/go-nacos/internal/nacostest"
+	"github.com/celeskyking/go-nacos/pkg/stats"
+	"github.com/celeskyking/go-nacos/types"
+	"math"
+	"testing"
+	"time"
+)
+
+func newTestServerList(instances ...*types.ServiceInstance) *ServerList {
+	sl := NewServerList(nil, nil, false, "demo", "dev", "", "")
+	sl.lb.Refresh(instances)
+	return sl
+}
+
+func TestServerList_ProtectThreshold(t *testing.T) {
+	sl := newTestServerList(
+		&types.ServiceInstance{IP: "10.0.0.1", Port: 8080, Healthy: true},
+		&types.ServiceInstance{IP: "10.0.0.2", Port: 8080},
+		&types.ServiceInstance{IP: "10.0.0.3", Port: 8080},
+		&types.ServiceInstance{IP: "10.0.0.4", Port: 8080},
+	)
+	recorder := &protectRecorder{}
+	stats.SetRecorder(recorder)
+	defer stats.SetRecorder(nil)
+	var events []*ProtectEvent
+	sl.OnProtect(func(event *ProtectEvent) {
+		events = append(events, event)
+	})
+	for i := 0; i < 20; i++ {
+		if s := sl.SelectOne(); s == nil || !s.Healthy {
+			t.Fatalf("expect healthy instance, got:%+v", s)
+		}
+	}
+	if sl.Protected() {
+		t.Fatal("expect not protected")
+	}
+	sl.SetProtectThreshold(0.5)
+	unhealthy := false
+	for i := 0; i < 100; i++ {
+		if s := sl.SelectOne(); s != nil && !s.Healthy {
+			unhealthy = true
+		}
+	}
+	if !unhealthy {
+		t.Fatal("expect unhealthy instances selected when protected")
+	}
+	if !sl.Protected() || sl.ProtectTimes() != 1 {
+		t.Fatalf("protected:%v, times:%d", sl.Protected(), sl.ProtectTimes())
+	}
+	if len(events) != 1 || events[0].HealthyCount != 1 || events[0].TotalCount != 4 || !events[0].Protected {
+		t.Fatalf("unexpected events:%+v", events)
+	}
+	sl.SetProtectThreshold(0.1)
+	sl.SelectOne()
+	if sl.Protected() || len(events) != 2 || events[1].Protected {
+		t.Fatalf("expect protect recovered, events:%d", len(events))
+	}
+	if len(recorder.protected) != 2 || !recorder.protected[0] || recorder.protected[1] {
+		t.Fatalf("expect protect state recorded on changes, got:%v", recorder.protected)
+	}
+}
+
+//protectRecorder 记录保护状态的变化
+type protectRecorder struct {
+	stats.Nop
+
+	protected []bool
+}
+
+func (r *protectRecorder) ServiceProtected(namespace, group, service string, protected bool) {
+	r.protected = append(r.protected, protected)
+}
+
+func TestServerList_ProtectRefreshInterval(t *testing.T) {
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	nacos.SetInstances("", "dev", "demo", &types.Host{IP: "10.0.0.1", Port: 8080})
+	ns := newTestNamingService(nacos)
+	defer ns.Stop()
+	sl, er := ns.GetInstances("demo", &QueryOptions{Group: "dev"})
+	if er != nil {
+		t.Fatalf("get instances error:%+v", er)
+	}
+	defer sl.StopListen()
+	time.Sleep(500 * time.Millisecond)
+	if polls := nacos.Requests("GET /nacos/v1/ns/instance/list"); polls < 3 {
+		t.Fatalf("expect instances polled, got:%d", polls)
+	}
+	if n := nacos.Requests("GET /nacos/v1/ns/service"); n != 1 {
+		t.Fatalf("expect protect threshold refreshed once within interval, got:%d", n)
+	}
+	if sl.GetLastRefTime() == math.MaxInt64 || sl.GetCacheMillis() != nacos.CacheMillis {
+		t.Fatalf("expect refresh state recorded, lastRefTime:%d, cacheMillis:%d", sl.GetLastRefTime(), sl.GetCacheMillis())
+	}
+}
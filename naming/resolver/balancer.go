@@ -0,0 +1,71 @@
+package resolver
+
+import (
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"math/rand"
+	"sync"
+)
+
+const (
+	//Name 按照nacos实例权重随机选择的balancer
+	Name = "nacos_weighted"
+)
+
+func init() {
+	balancer.Register(base.NewBalancerBuilder(Name, &pickerBuilder{}, base.Config{HealthCheck: true}))
+}
+
+type pickerBuilder struct{}
+
+func (p *pickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+	picker := &weightedPicker{
+		rand: rand.New(rand.NewSource(rand.Int63())),
+	}
+	for sc, sci := range info.ReadySCs {
+		w := GetWeight(sci.Address)
+		if w <= 0 {
+			continue
+		}
+		picker.total += w
+		picker.subConns = append(picker.subConns, sc)
+		picker.weights = append(picker.weights, picker.total)
+	}
+	//全部的实例权重都为0的时候退化为随机
+	if len(picker.subConns) == 0 {
+		for sc := range info.ReadySCs {
+			picker.total++
+			picker.subConns = append(picker.subConns, sc)
+			picker.weights = append(picker.weights, picker.total)
+		}
+	}
+	return picker
+}
+
+//weightedPicker 按照权重随机选择subConn,weights为累加的权重
+type weightedPicker struct {
+	subConns []balancer.SubConn
+
+	weights []float64
+
+	total float64
+
+	lock sync.Mutex
+
+	rand *rand.Rand
+}
+
+func (w *weightedPicker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	w.lock.Lock()
+	r := w.rand.Float64() * w.total
+	w.lock.Unlock()
+	for i, weight := range w.weights {
+		if r < weight {
+			return balancer.PickResult{SubConn: w.subConns[i]}, nil
+		}
+	}
+	return balancer.PickResult{SubConn: w.subConns[len(w.subConns)-1]}, nil
+}
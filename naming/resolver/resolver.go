@@ -0,0 +1,191 @@
+//Package resolver 基于NamingService实现的grpc resolver,target的格式为:
+//nacos://[namespace]/[group]/service?cluster=a&selector=version%20in%20(v2)
+package resolver
+
+import (
+	"github.com/celeskyking/go-nacos/naming"
+	"github.com/celeskyking/go-nacos/pkg/selector"
+	"github.com/celeskyking/go-nacos/types"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/attributes"
+	gresolver "google.golang.org/grpc/resolver"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	//Scheme nacos的resolver的scheme
+	Scheme = "nacos"
+)
+
+type weightKey struct{}
+
+type metadataKey struct{}
+
+type serviceNameKey struct{}
+
+//Metadata 实例的元数据,实现了Equal方法可以作为grpc的attribute
+type Metadata map[string]string
+
+func (m Metadata) Equal(o interface{}) bool {
+	other, ok := o.(Metadata)
+	if !ok || len(m) != len(other) {
+		return false
+	}
+	for k, v := range m {
+		if ov, ok := other[k]; !ok || ov != v {
+			return false
+		}
+	}
+	return true
+}
+
+//GetWeight 返回地址上的实例权重,不存在的时候返回1
+func GetWeight(addr gresolver.Address) float64 {
+	if addr.BalancerAttributes == nil {
+		return 1
+	}
+	if w, ok := addr.BalancerAttributes.Value(weightKey{}).(float64); ok {
+		return w
+	}
+	return 1
+}
+
+//GetMetadata 返回地址上的实例元数据
+func GetMetadata(addr gresolver.Address) map[string]string {
+	if addr.BalancerAttributes == nil {
+		return nil
+	}
+	m, _ := addr.BalancerAttributes.Value(metadataKey{}).(Metadata)
+	return m
+}
+
+//GetServiceName 返回地址对应的nacos服务名
+func GetServiceName(addr gresolver.Address) string {
+	if addr.Attributes == nil {
+		return ""
+	}
+	name, _ := addr.Attributes.Value(serviceNameKey{}).(string)
+	return name
+}
+
+//Register 使用NamingService注册全局的nacos resolver
+func Register(ns naming.NamingService) {
+	gresolver.Register(NewBuilder(ns))
+}
+
+//NewBuilder 返回nacos的resolver builder,可以通过grpc.WithResolvers单独使用
+func NewBuilder(ns naming.NamingService) gresolver.Builder {
+	return &builder{ns: ns}
+}
+
+type builder struct {
+	ns naming.NamingService
+}
+
+func (b *builder) Scheme() string {
+	return Scheme
+}
+
+func (b *builder) Build(target gresolver.Target, cc gresolver.ClientConn, opts gresolver.BuildOptions) (gresolver.Resolver, error) {
+	serviceName, options, er := parseTarget(target)
+	if er != nil {
+		return nil, er
+	}
+	//ServerList只在选择单个实例的时候使用selector,推送给grpc的地址列表需要resolver自己过滤
+	sel, er := selector.Parse(options.Selector)
+	if er != nil {
+		return nil, errors.Wrap(er, "nacos resolver parse selector")
+	}
+	sl, er := b.ns.GetInstances(serviceName, options)
+	if er != nil {
+		return nil, errors.Wrap(er, "nacos resolver get instances")
+	}
+	r := &nacosResolver{
+		cc:          cc,
+		serverList:  sl,
+		serviceName: serviceName,
+		selector:    sel,
+	}
+	sl.Subscribe(r.update)
+	r.update(sl.GetAll())
+	return r, nil
+}
+
+//parseTarget 解析target,host为namespace,path为 /group/service 或者 /service
+func parseTarget(target gresolver.Target) (string, *naming.QueryOptions, error) {
+	parts := strings.Split(strings.Trim(target.URL.Path, "/"), "/")
+	options := &naming.QueryOptions{
+		Namespace: target.URL.Host,
+		Watch:     true,
+	}
+	var serviceName string
+	switch len(parts) {
+	case 1:
+		serviceName = parts[0]
+	case 2:
+		options.Group = parts[0]
+		serviceName = parts[1]
+	}
+	if serviceName == "" {
+		return "", nil, errors.Errorf("nacos resolver: invalid target:%s", target.URL.String())
+	}
+	q := target.URL.Query()
+	options.Cluster = q.Get("cluster")
+	options.Selector = q.Get("selector")
+	return serviceName, options, nil
+}
+
+type nacosResolver struct {
+	cc gresolver.ClientConn
+
+	serverList *naming.ServerList
+
+	serviceName string
+	//target中selector参数对应的元数据过滤条件
+	selector selector.Selector
+
+	lock sync.Mutex
+}
+
+//update 把满足selector的实例转化为grpc的地址,没有健康的实例的时候使用全部满足selector的实例
+func (r *nacosResolver) update(instances []*types.ServiceInstance) {
+	var healthy, all []gresolver.Address
+	for _, i := range instances {
+		if !i.Enable || !r.selector.Matches(i.Metadata) {
+			continue
+		}
+		addr := toAddress(i)
+		all = append(all, addr)
+		if i.Healthy {
+			healthy = append(healthy, addr)
+		}
+	}
+	if len(healthy) == 0 {
+		healthy = all
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	_ = r.cc.UpdateState(gresolver.State{
+		Addresses:     healthy,
+		ServiceConfig: r.cc.ParseServiceConfig(`{"loadBalancingConfig":[{"` + Name + `":{}}]}`),
+	})
+}
+
+func toAddress(instance *types.ServiceInstance) gresolver.Address {
+	attrs := attributes.New(weightKey{}, instance.Weight).WithValue(metadataKey{}, Metadata(instance.Metadata))
+	//ServerName会作为tls校验证书和http2的:authority,使用服务名会导致tls握手失败,所以服务名只写入Attributes
+	return gresolver.Address{
+		Addr:               instance.IP + ":" + strconv.Itoa(instance.Port),
+		Attributes:         attributes.New(serviceNameKey{}, instance.ServiceName),
+		BalancerAttributes: attrs,
+	}
+}
+
+//ResolveNow 实例列表由ServerList的推送和轮询维护,不需要主动刷新
+func (r *nacosResolver) ResolveNow(gresolver.ResolveNowOptions) {}
+
+func (r *nacosResolver) Close() {
+	r.serverList.StopListen()
+}
@@ -0,0 +1,218 @@
+package resolver
+
+import (
+	"context"
+	"github.com/celeskyking/go-nacos/api"
+	"github.com/celeskyking/go-nacos/internal/nacostest"
+	"github.com/celeskyking/go-nacos/naming"
+	"github.com/celeskyking/go-nacos/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+	gresolver "google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/serviceconfig"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func startGRPCServer(t *testing.T) (*grpc.Server, *net.TCPAddr) {
+	lis, er := net.Listen("tcp", "127.0.0.1:0")
+	if er != nil {
+		t.Fatalf("listen error:%+v", er)
+	}
+	s := grpc.NewServer()
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go func() {
+		_ = s.Serve(lis)
+	}()
+	return s, lis.Addr().(*net.TCPAddr)
+}
+
+func host(addr *net.TCPAddr, weight float64) *types.Host {
+	return &types.Host{
+		IP:          addr.IP.String(),
+		Port:        addr.Port,
+		Weight:      weight,
+		Healthy:     true,
+		Enabled:     true,
+		ServiceName: "dev@@demo",
+		Metadata:    map[string]string{"version": "v1"},
+	}
+}
+
+//call 调用count次,返回每个server处理的次数
+func call(t *testing.T, client healthpb.HealthClient, count int) map[string]int {
+	result := make(map[string]int)
+	for i := 0; i < count; i++ {
+		var p peer.Peer
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		_, er := client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Peer(&p))
+		cancel()
+		if er != nil {
+			t.Fatalf("grpc call error:%+v", er)
+		}
+		result[p.Addr.String()]++
+	}
+	return result
+}
+
+func TestResolver(t *testing.T) {
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	s1, addr1 := startGRPCServer(t)
+	defer s1.Stop()
+	s2, addr2 := startGRPCServer(t)
+	defer s2.Stop()
+	nacos.SetInstances("", "dev", "demo", host(addr1, 1), host(addr2, 9))
+
+	ns := naming.NewNamingService(&api.ServerOptions{
+		Addresses:  []string{nacos.Addr()},
+		LBStrategy: api.Direct,
+	})
+	conn, er := grpc.NewClient(Scheme+":///dev/demo",
+		grpc.WithResolvers(NewBuilder(ns)),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if er != nil {
+		t.Fatalf("dial error:%+v", er)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	//等待两个subConn都ready
+	deadline := time.Now().Add(5 * time.Second)
+	var result map[string]int
+	for time.Now().Before(deadline) {
+		result = call(t, client, 200)
+		if len(result) == 2 {
+			break
+		}
+	}
+	result = call(t, client, 1000)
+	if result[addr2.String()] <= result[addr1.String()]*3 {
+		t.Fatalf("expect weighted balance, got:%v", result)
+	}
+
+	//下线一个实例,等待推送到resolver
+	nacos.SetInstances("", "dev", "demo", host(addr1, 1))
+	deadline = time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		result = call(t, client, 50)
+		if len(result) == 1 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if len(result) != 1 || result[addr1.String()] != 50 {
+		t.Fatalf("expect only %s, got:%v", addr1, result)
+	}
+}
+
+//stateConn 记录resolver推送的最后一次状态
+type stateConn struct {
+	gresolver.ClientConn
+
+	lock sync.Mutex
+
+	state gresolver.State
+}
+
+func (c *stateConn) UpdateState(state gresolver.State) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.state = state
+	return nil
+}
+
+func (c *stateConn) ParseServiceConfig(string) *serviceconfig.ParseResult {
+	return nil
+}
+
+func (c *stateConn) addresses() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var addrs []string
+	for _, a := range c.state.Addresses {
+		addrs = append(addrs, a.Addr)
+	}
+	return addrs
+}
+
+func TestResolver_Selector(t *testing.T) {
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	v1 := &types.Host{IP: "10.0.0.1", Port: 8080, Weight: 1, Healthy: true, Enabled: true, Metadata: map[string]string{"zone": "a"}}
+	v2 := &types.Host{IP: "10.0.0.2", Port: 8080, Weight: 1, Healthy: true, Enabled: true, Metadata: map[string]string{"zone": "b"}}
+	nacos.SetInstances("", "dev", "demo", v1, v2)
+	ns := naming.NewNamingService(&api.ServerOptions{
+		Addresses:  []string{nacos.Addr()},
+		LBStrategy: api.Direct,
+		Push:       &api.PushOptions{Disabled: true},
+	})
+	defer ns.Stop()
+	conn := &stateConn{}
+	r, er := NewBuilder(ns).Build(mustTarget(t, "nacos:///dev/demo?selector=zone%3Da"), conn, gresolver.BuildOptions{})
+	if er != nil {
+		t.Fatalf("build error:%+v", er)
+	}
+	defer r.Close()
+	if addrs := conn.addresses(); len(addrs) != 1 || addrs[0] != "10.0.0.1:8080" {
+		t.Fatalf("expect only instances matching selector, got:%v", addrs)
+	}
+	if _, er := NewBuilder(ns).Build(mustTarget(t, "nacos:///dev/demo?selector=zone%20in"), conn, gresolver.BuildOptions{}); er == nil {
+		t.Fatal("expect error for invalid selector")
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	cases := []struct {
+		target    string
+		service   string
+		namespace string
+		group     string
+		cluster   string
+	}{
+		{"nacos:///demo", "demo", "", "", ""},
+		{"nacos:///dev/demo?cluster=a", "demo", "", "dev", "a"},
+		{"nacos://ns-1/dev/demo", "demo", "ns-1", "dev", ""},
+	}
+	for _, c := range cases {
+		serviceName, options, er := parseTarget(mustTarget(t, c.target))
+		if er != nil {
+			t.Errorf("parse %s error:%+v", c.target, er)
+			continue
+		}
+		if serviceName != c.service || options.Namespace != c.namespace || options.Group != c.group || options.Cluster != c.cluster {
+			t.Errorf("parse %s, got:%s %+v", c.target, serviceName, options)
+		}
+	}
+	if _, _, er := parseTarget(mustTarget(t, "nacos:///a/b/c")); er == nil {
+		t.Errorf("expect error for invalid target")
+	}
+}
+
+func TestGetWeight(t *testing.T) {
+	addr := toAddress(&types.ServiceInstance{IP: "127.0.0.1", Port: 80, Weight: 2.5, ServiceName: "demo", Metadata: map[string]string{"a": "b"}})
+	if GetWeight(addr) != 2.5 || GetMetadata(addr)["a"] != "b" {
+		t.Fatalf("unexpected attributes:%v", addr.BalancerAttributes)
+	}
+	if addr.ServerName != "" || GetServiceName(addr) != "demo" {
+		t.Fatalf("expect service name only in attributes, got:%q, %v", addr.ServerName, addr.Attributes)
+	}
+	if addr.Addr != "127.0.0.1:"+strconv.Itoa(80) {
+		t.Fatalf("unexpected addr:%s", addr.Addr)
+	}
+}
+
+func mustTarget(t *testing.T, s string) gresolver.Target {
+	u, er := url.Parse(s)
+	if er != nil {
+		t.Fatalf("parse url error:%+v", er)
+	}
+	return gresolver.Target{URL: *u}
+}
@@ -1,18 +1,33 @@
 package naming
 
 import (
+	"context"
+	"github.com/celeskyking/go-nacos/api"
 	v1 "github.com/celeskyking/go-nacos/api/ns/v1"
+	"github.com/celeskyking/go-nacos/pkg/logger"
+	"github.com/celeskyking/go-nacos/pkg/selector"
+	"github.com/celeskyking/go-nacos/pkg/stats"
 	"github.com/celeskyking/go-nacos/pkg/util"
 	"github.com/celeskyking/go-nacos/types"
-	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"math"
+	"net"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+//AttrInstance 选择的实例的地址
+const AttrInstance = attribute.Key("nacos.instance")
+
 const (
 	Splitter           string = "@@"
 	DefaultCacheMillis int    = 10
+	//ProtectRefreshInterval 轮询实例列表的时候刷新服务保护阈值的最小间隔
+	ProtectRefreshInterval = 30 * time.Second
 )
 
 func NewServerList(httpClient v1.NamingHttpClient, receiver *v1.PushReceiver, watch bool, serviceName, groupName, namespaceId, clusters string) *ServerList {
@@ -28,6 +43,8 @@ func NewServerList(httpClient v1.NamingHttpClient, receiver *v1.PushReceiver, wa
 		LastRefTime: math.MaxInt64,
 		NamespaceId: namespaceId,
 		CacheMillis: DefaultCacheMillis,
+		log:         logger.With(nil),
+		rec:         stats.With(nil),
 	}
 	return sl
 }
@@ -52,77 +69,136 @@ type ServerList struct {
 	NamespaceId string
 
 	Clusters string
+	//默认的元数据过滤条件
+	selector selector.Selector
+	//同区域优先的路由策略
+	zoneAffinity *ZoneAffinity
 
 	httpClient v1.NamingHttpClient
 	//缓存时间
 	stopC chan struct{}
+
+	lock sync.RWMutex
+	//服务的保护阈值
+	protectThreshold float64
+	//是否处于保护状态
+	protected bool
+	//进入保护状态的次数
+	protectTimes int64
+	//最后一次刷新保护阈值的时间
+	protectRefreshedAt time.Time
+
+	protectListeners []ProtectListener
+
+	subscribers []Subscriber
+
+	stopOnce sync.Once
+	//实例列表的保护策略
+	guard *ListGuard
+
+	guardState guardState
+
+	log logger.Logger
+
+	rec stats.Recorder
 }
 
+//Subscriber 实例列表变化的订阅者
+type Subscriber func(instances []*types.ServiceInstance)
+
 func (s *ServerList) GetAll() []*types.ServiceInstance {
 	return s.lb.GetAll()
 }
 
 func (s *ServerList) Listen(stop <-chan struct{}) error {
-	instances, result, er := selectInstances(s.httpClient, s.ServiceName, &QueryOptions{
+	instances, result, er := selectInstances(s.httpClient, s.receiver, s.ServiceName, &QueryOptions{
 		Group:     s.GroupName,
 		Cluster:   s.Clusters,
 		Namespace: s.NamespaceId,
-		Watch:     true,
+		Watch:     s.watch,
 	})
 	if er != nil {
 		return er
 	}
-	s.CacheMillis = result.CacheMillis
-	s.LastRefTime = result.LastRefTime
-	s.lb.Refresh(instances)
+	s.refreshProtectThreshold()
+	s.setRefreshed(result.CacheMillis, result.LastRefTime)
+	s.refresh(instances)
 	go func() {
-		timer := time.NewTimer(time.Duration(s.CacheMillis) * time.Millisecond)
+		timer := time.NewTimer(s.cacheInterval())
 		for {
 			select {
 			case <-timer.C:
-				instances, result, er := selectInstances(s.httpClient, s.ServiceName, &QueryOptions{
+				instances, result, er := selectInstances(s.httpClient, s.receiver, s.ServiceName, &QueryOptions{
 					Group:     s.GroupName,
 					Cluster:   s.Clusters,
 					Namespace: s.NamespaceId,
-					Watch:     true,
+					Watch:     s.watch,
 				})
 				if er != nil {
-					logrus.Errorf("list service failed, serviceName:%s, error:%+v\n", result, er)
+					s.log.Error("list service failed", logger.F("service", s.ServiceName), logger.Err(er))
 					time.Sleep(5 * time.Second)
-					timer.Reset(time.Duration(s.CacheMillis) * time.Millisecond)
+					timer.Reset(s.cacheInterval())
 					continue
 				}
-				s.CacheMillis = result.CacheMillis
-				s.LastRefTime = result.LastRefTime
-				timer.Reset(time.Duration(s.CacheMillis) * time.Millisecond)
-				s.lb.Refresh(instances)
+				s.refreshProtectThreshold()
+				s.setRefreshed(result.CacheMillis, result.LastRefTime)
+				timer.Reset(s.cacheInterval())
+				s.refresh(instances)
 			case <-s.stopC:
 				return
 			}
 		}
 	}()
-	go func() {
-		if s.watch {
-			notifyC := s.receiver.GetNotifyChannel()
+	if s.watch {
+		notifyC, cancel := s.receiver.Subscribe(Key(s.GroupName, s.ServiceName), s.Clusters)
+		go func() {
+			defer cancel()
 			for {
 				select {
 				case msg := <-notifyC:
-					if Key(msg.Name, msg.Clusters) == Key(s.GroupName, s.ServiceName, s.Clusters) && msg.LastRefTime > s.LastRefTime {
+					if msg.LastRefTime > s.GetLastRefTime() {
 						s.refreshServiceList(msg)
 					}
 				case <-s.stopC:
 					return
 				}
 			}
-		}
-	}()
+		}()
+	}
 	return nil
 }
 
+//setRefreshed 记录轮询返回的缓存时间和最后更新的时间,推送的goroutine会同时读取,需要加锁
+func (s *ServerList) setRefreshed(cacheMillis int, lastRefTime int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.CacheMillis = cacheMillis
+	s.LastRefTime = lastRefTime
+}
+
+func (s *ServerList) cacheInterval() time.Duration {
+	return time.Duration(s.GetCacheMillis()) * time.Millisecond
+}
+
+//GetLastRefTime 返回实例列表在服务端最后更新的时间
+func (s *ServerList) GetLastRefTime() int64 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.LastRefTime
+}
+
+//GetCacheMillis 返回服务端建议的实例列表的缓存时间
+func (s *ServerList) GetCacheMillis() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.CacheMillis
+}
+
+//StopListen 停止轮询和推送的监听,可以重复调用
 func (s *ServerList) StopListen() {
-	if s.watch {
-		s.stopC <- struct{}{}
-	}
+	s.stopOnce.Do(func() {
+		close(s.stopC)
+	})
 }
 
 func Key(parts ...string) string {
@@ -135,13 +211,87 @@ func (s *ServerList) refreshServiceList(msg *v1.PushMessage) {
 	for _, instance := range msg.Hosts {
 		list = append(list, hostToServiceInstance(s.NamespaceId, groupName, instance))
 	}
-	s.list = list
-	logrus.Info(util.ToJSONString(s.list))
-	s.lb.Refresh(list)
+	s.log.Debug("receive pushed instances", logger.F("service", s.ServiceName), logger.F("count", len(list)))
+	s.refresh(list)
+}
+
+//refresh 刷新实例列表,列表发生变化的时候通知订阅者,被ListGuard拦截的列表会被忽略
+func (s *ServerList) refresh(instances []*types.ServiceInstance) {
+	current := s.lb.GetAll()
+	if !s.acceptList(current, instances) {
+		return
+	}
+	changed := !reflect.DeepEqual(current, instances)
+	s.lb.Refresh(instances)
+	s.rec.InstanceCount(s.NamespaceId, s.GroupName, s.ServiceName, len(instances))
+	if !changed {
+		return
+	}
+	s.lock.RLock()
+	subscribers := s.subscribers
+	s.lock.RUnlock()
+	for _, subscriber := range subscribers {
+		subscriber(instances)
+	}
+}
+
+//Subscribe 订阅实例列表的变化,推送和轮询导致的变化都会触发
+func (s *ServerList) Subscribe(subscriber Subscriber) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.subscribers = append(s.subscribers, subscriber)
 }
 
+//SelectOne 选择一个实例,健康实例的比例低于保护阈值的时候会从全部的实例中选择
 func (s *ServerList) SelectOne() *types.ServiceInstance {
-	return s.lb.SelectOne(nil)
+	return s.SelectOneContext(context.Background())
+}
+
+//SelectOneContext 和SelectOne相同,使用ctx作为选择实例的span的父context
+func (s *ServerList) SelectOneContext(ctx context.Context) *types.ServiceInstance {
+	return s.selectOne(ctx, s.selector, nil)
+}
+
+//Select 选择一个元数据满足selector的实例,忽略QueryOptions中设置的Selector
+func (s *ServerList) Select(sel selector.Selector) *types.ServiceInstance {
+	return s.selectOne(context.Background(), sel, nil)
+}
+
+//SelectFunc 在SelectOne的基础上额外使用filter过滤实例,例如重试的时候排除已经失败的实例
+func (s *ServerList) SelectFunc(filter func(instance *types.ServiceInstance) bool) *types.ServiceInstance {
+	return s.SelectFuncContext(context.Background(), filter)
+}
+
+//SelectFuncContext 和SelectFunc相同,使用ctx作为选择实例的span的父context
+func (s *ServerList) SelectFuncContext(ctx context.Context, filter func(instance *types.ServiceInstance) bool) *types.ServiceInstance {
+	return s.selectOne(ctx, s.selector, filter)
+}
+
+func (s *ServerList) selectOne(ctx context.Context, sel selector.Selector, filter func(instance *types.ServiceInstance) bool) *types.ServiceInstance {
+	_, span := api.StartSpan(ctx, "nacos select instance",
+		api.AttrService.String(s.ServiceName),
+		api.AttrGroup.String(s.GroupName),
+		api.AttrCluster.String(s.Clusters),
+		api.AttrNamespace.String(s.NamespaceId))
+	defer span.End()
+	all := s.lb.GetAll()
+	protect := s.protectFilter(all)
+	matches := func(instance *types.ServiceInstance) bool {
+		return sel.Matches(instance.Metadata)
+	}
+	candidate := func(instance *types.ServiceInstance) bool {
+		return (protect == nil || protect(instance)) && matches(instance)
+	}
+	zone := s.zoneFilter(all, matches, candidate)
+	instance := s.lb.SelectOne(func(instance *types.ServiceInstance) bool {
+		return candidate(instance) && (zone == nil || zone(instance)) && (filter == nil || filter(instance))
+	})
+	if instance == nil {
+		span.SetStatus(codes.Error, "no instance available")
+	} else {
+		span.SetAttributes(AttrInstance.String(net.JoinHostPort(instance.IP, strconv.Itoa(instance.Port))))
+	}
+	return instance
 }
 
 func hostToServiceInstance(namespaceID, groupName string, msg *types.Host) *types.ServiceInstance {
@@ -158,7 +308,7 @@ func hostToServiceInstance(namespaceID, groupName string, msg *types.Host) *type
 		//健康状态
 		Healthy: msg.Healthy,
 		//元数据
-		Metadata: util.MapToString(msg.Metadata),
+		Metadata: msg.Metadata,
 		//集群名
 		ClusterName: msg.ClusterName,
 		//服务名
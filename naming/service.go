@@ -4,12 +4,16 @@ import (
 	"github.com/celeskyking/go-nacos/api"
 	v1 "github.com/celeskyking/go-nacos/api/ns/v1"
 	"github.com/celeskyking/go-nacos/err"
+	beat "github.com/celeskyking/go-nacos/naming/heartbeat"
+	"github.com/celeskyking/go-nacos/pkg/logger"
+	"github.com/celeskyking/go-nacos/pkg/selector"
+	"github.com/celeskyking/go-nacos/pkg/stats"
 	"github.com/celeskyking/go-nacos/pkg/util"
 	"github.com/celeskyking/go-nacos/types"
 	"github.com/pkg/errors"
 	"strconv"
-	"strings"
 	"sync"
+	"time"
 )
 
 //Service naming的服务
@@ -24,18 +28,27 @@ type NamingService interface {
 	//GetInstances 通过服务名来获取实例信息,默认会选择同namespace和group下的服务
 	GetInstances(serviceName string, options *QueryOptions) (*ServerList, error)
 
-	//GetAllService 返回所有的服务信息,服务的个数和服务的名称列表
+	//GetServices 返回一页服务信息,GroupName为空的时候返回所有分组的服务
 	GetServices(option *types.ServiceListOption) ([]*types.CatalogServiceDetail, error)
 
-	//返回所有的
+	//GetAllServices 遍历namespace下所有分组的服务,返回服务的个数和服务列表
+	GetAllServices(namespaceID string) (int, []*types.CatalogServiceDetail, error)
+
+	//GetAllServicesCount 返回namespace下所有分组的服务的个数
 	GetAllServicesCount(namespaceID string) (int, error)
 
+	//WatchCatalog 轮询namespace下的服务目录,返回服务的新增和删除事件
+	WatchCatalog(namespaceID string, interval time.Duration) (*CatalogWatcher, error)
+
 	//更新cluster
 	PatchCluster(cluster *types.Cluster) error
 
 	//设置实例是否可用
 	SetInstanceHealthy(serviceName string, options *QueryOptions) error
 
+	//UpdateInstanceHealthy 更新持久化实例的健康状态,需要服务端关闭该集群的健康检查
+	UpdateInstanceHealthy(instance *types.ServiceInstance, healthy bool) error
+
 	//Stop 停止当前的服务
 	Stop()
 
@@ -54,6 +67,15 @@ type NamingService interface {
 	GetService(options ServiceOptions) (*types.ServiceDetail, error)
 
 	GetNamespaceID() string
+
+	//PushReceiver 接收服务端推送的udp receiver,可以获取推送的统计数据
+	PushReceiver() *v1.PushReceiver
+
+	//BeatScheduler 共享的心跳调度器,同一个NamingService注册的临时实例共用
+	BeatScheduler() beat.Scheduler
+
+	//Logger 输出日志使用的Logger,通过ServerOptions.Logger设置,没有设置的时候使用全局的Logger
+	Logger() logger.Logger
 }
 
 type ServiceOptions struct {
@@ -74,22 +96,81 @@ type QueryOptions struct {
 	Healthy bool
 	//是否开启watch
 	Watch bool
+	//实例元数据的过滤表达式,例如: version in (v2,v3), zone = cn-a, !canary
+	Selector string
+	//同区域优先的路由策略,为空的时候不开启
+	ZoneAffinity *ZoneAffinity
+	//推送和轮询的实例列表的保护策略,为空的时候使用DefaultListGuard
+	ListGuard *ListGuard
 }
 
+//NewNamingService 创建naming服务,配置不合法的时候只输出错误日志,没有可用的nacos server的时候请求返回错误。
+//推荐使用NewNamingServiceE
 func NewNamingService(config *api.ServerOptions) NamingService {
-	httpOption := api.DefaultOption()
-	httpOption.Servers = config.Addresses
-	httpOption.LBStrategy = config.LBStrategy
-	httpClient := v1.NewNamingHttpClient(httpOption)
+	o := httpOption(config)
+	if er := config.Validate(); er != nil {
+		logger.With(o.Logger).Error("naming options not valid", logger.Err(er))
+	}
+	return newNamingService(config, v1.NewNamingHttpClient(o))
+}
+
+//NewNamingServiceE 创建naming服务,配置不合法或者没有可用的nacos server的时候返回错误
+func NewNamingServiceE(config *api.ServerOptions) (NamingService, error) {
+	o := httpOption(config)
+	if er := config.Validate(); er != nil {
+		return nil, er
+	}
+	httpClient, er := v1.NewNamingHttpClientE(o)
+	if er != nil {
+		return nil, er
+	}
+	return newNamingService(config, httpClient), nil
+}
+
+//httpOption 转换为http客户端的配置
+func httpOption(config *api.ServerOptions) *api.HttpConfigOption {
+	o := api.DefaultOption()
+	if config == nil {
+		return o
+	}
+	o.Logger = config.Logger
+	o.Recorder = config.Recorder
+	o.Servers = config.Addresses
+	o.LBStrategy = config.LBStrategy
+	o.RetryPolicy = config.RetryPolicy
+	o.HealthCheck = config.HealthCheck
+	o.Endpoint = config.Endpoint
+	o.EndpointEnabled = config.EndpointEnabled
+	o.EndpointOptions = config.EndpointOptions
+	o.ServerProvider = config.ServerProvider
+	return o
+}
+
+func newNamingService(config *api.ServerOptions, httpClient v1.NamingHttpClient) *namingService {
+	if config == nil {
+		config = &api.ServerOptions{}
+	}
 	stopC := make(chan struct{})
 	ns := &namingService{
 		Config:       config,
 		httpClient:   httpClient,
-		pushReceiver: v1.NewPushReceiver(),
+		beats:        beat.NewSchedulerWithOptions(httpClient, &beat.Options{Logger: config.Logger, Recorder: config.Recorder}),
+		pushReceiver: v1.NewPushReceiverWithOptions(config.Push),
 		stopC:        stopC,
 		NamespaceID:  config.NamespaceID,
+		log:          logger.With(config.Logger),
+		rec:          stats.With(config.Recorder),
+	}
+	ns.pushReceiver.SetLogger(config.Logger)
+	ns.pushReceiver.SetRecorder(config.Recorder)
+	//同步绑定端口,保证第一次订阅的时候已经有udp端口,绑定失败的时候只依靠轮询
+	if config.Push == nil || !config.Push.Disabled {
+		if er := ns.pushReceiver.Bind(); er != nil {
+			ns.log.Error("push receiver not available, fallback to polling", logger.Err(er))
+		} else {
+			go ns.pushReceiver.Serve()
+		}
 	}
-	go ns.pushReceiver.Start()
 	return ns
 }
 
@@ -107,6 +188,12 @@ type namingService struct {
 	NamespaceID string
 
 	stopC chan struct{}
+
+	beats beat.Scheduler
+
+	log logger.Logger
+
+	rec stats.Recorder
 }
 
 func (n *namingService) HttpClient() v1.NamingHttpClient {
@@ -141,15 +228,38 @@ func (n *namingService) SetInstanceHealthy(serviceName string, option *QueryOpti
 	return nil
 }
 
+func (n *namingService) UpdateInstanceHealthy(instance *types.ServiceInstance, healthy bool) error {
+	r, er := n.httpClient.UpdateServiceInstanceHealthy(&types.UpdateServiceInstanceHealthyRequest{
+		IP:          instance.IP,
+		Port:        instance.Port,
+		NamespaceID: instance.NamespaceID,
+		Healthy:     healthy,
+		ClusterName: instance.ClusterName,
+		ServiceName: instance.ServiceName,
+		GroupName:   instance.GroupName,
+	})
+	if er != nil {
+		return errors.Wrap(er, "update instance healthy")
+	}
+	if !r.Success {
+		return err.ErrNamingService
+	}
+	return nil
+}
+
 func (n *namingService) UpdateInstance(instance *types.ServiceInstance) error {
 	result, er := n.httpClient.UpdateServiceInstance(instance)
 	if er != nil {
 		return er
 	}
-	if result.Success {
-		return nil
+	if !result.Success {
+		return err.ErrNamingService
 	}
-	return err.ErrNamingService
+	if instance.Ephemeral {
+		//服务端丢失实例之后心跳按照更新之后的信息重新注册,避免恢复已经禁用的实例
+		n.beats.Update(instance)
+	}
+	return nil
 }
 
 func (n *namingService) CreateService(service *types.Service) error {
@@ -185,6 +295,18 @@ func (n *namingService) GetService(options ServiceOptions) (*types.ServiceDetail
 	return detail, nil
 }
 
+func (n *namingService) PushReceiver() *v1.PushReceiver {
+	return n.pushReceiver
+}
+
+func (n *namingService) Logger() logger.Logger {
+	return n.log
+}
+
+func (n *namingService) BeatScheduler() beat.Scheduler {
+	return n.beats
+}
+
 func (n *namingService) GetNamespaceID() string {
 	return n.NamespaceID
 }
@@ -218,8 +340,17 @@ func (n *namingService) PatchCluster(cluster *types.Cluster) error {
 //GetInstances 返回制定services的所有的实例信息
 //todo 支持failover
 func (n *namingService) GetInstances(serviceName string, options *QueryOptions) (*ServerList, error) {
+	sel, er := selector.Parse(options.Selector)
+	if er != nil {
+		return nil, er
+	}
 	sl := NewServerList(n.httpClient, n.pushReceiver, options.Watch, serviceName, options.Group, options.Namespace, options.Cluster)
-	er := sl.Listen(n.stopC)
+	sl.log = n.log
+	sl.rec = n.rec
+	sl.selector = sel
+	sl.zoneAffinity = options.ZoneAffinity
+	sl.guard = options.ListGuard
+	er = sl.Listen(n.stopC)
 	return sl, er
 }
 
@@ -228,7 +359,7 @@ func (n *namingService) GetServices(option *types.ServiceListOption) ([]*types.C
 		NamespaceID: option.NamespaceID,
 		PageSize:    option.PageSize,
 		PageNo:      option.PageNo,
-		GroupName:   "",
+		GroupName:   option.GroupName,
 	})
 	if er != nil {
 		return nil, er
@@ -237,14 +368,14 @@ func (n *namingService) GetServices(option *types.ServiceListOption) ([]*types.C
 }
 
 func (n *namingService) GetAllServicesCount(namespaceID string) (int, error) {
-	r, er := n.httpClient.ListService(&types.ServiceListOption{PageNo: 1, PageSize: 1, NamespaceID: namespaceID})
-	if er != nil {
-		return 0, er
-	}
-	return r.Count, nil
+	count, _, er := n.GetAllServices(namespaceID)
+	return count, er
 }
 
 func (n *namingService) Stop() {
+	n.beats.Stop()
+	n.pushReceiver.Stop()
+	n.httpClient.Stop()
 	select {
 	case n.stopC <- struct{}{}:
 	default:
@@ -253,33 +384,25 @@ func (n *namingService) Stop() {
 
 }
 
-func selectInstances(httpClient v1.NamingHttpClient, serviceName string, options *QueryOptions) (instances []*types.ServiceInstance, result *types.ServiceInstanceListResult, er error) {
-	req := buildQueryListRequest(serviceName, options, options.Watch)
+//selectInstances 查询实例列表,Watch的时候带上receiver的端口订阅服务端的推送
+func selectInstances(httpClient v1.NamingHttpClient, receiver *v1.PushReceiver, serviceName string, options *QueryOptions) (instances []*types.ServiceInstance, result *types.ServiceInstanceListResult, er error) {
+	udpPort := 0
+	if receiver != nil {
+		udpPort = receiver.GetPort()
+	}
+	req := buildQueryListRequest(serviceName, options, options.Watch, udpPort)
 	r, er := httpClient.ListServiceInstance(req)
 	if er != nil {
 		return nil, nil, er
 	}
-	if len(r.Hosts) == 0 {
-		return nil, nil, nil
-	}
 	var results []*types.ServiceInstance
 	for _, h := range r.Hosts {
-		parts := strings.Split(h.InstanceID, "#")
-		results = append(results, &types.ServiceInstance{
-			IP:          parts[0],
-			Port:        stringToInt(parts[1]),
-			ServiceName: parts[3],
-			GroupName:   options.Group,
-			NamespaceID: options.Namespace,
-			ClusterName: h.ClusterName,
-			Ephemeral:   h.Ephemeral,
-			Metadata:    util.MapToString(h.Metadata),
-		})
+		results = append(results, hostToServiceInstance(options.Namespace, options.Group, h))
 	}
 	return results, r, nil
 }
 
-func buildQueryListRequest(appName string, options *QueryOptions, subscriber bool) *types.ServiceInstanceListOption {
+func buildQueryListRequest(appName string, options *QueryOptions, subscriber bool, udpPort int) *types.ServiceInstanceListOption {
 	req := &types.ServiceInstanceListOption{}
 	req.ServiceName = appName
 	if options.Group != "" {
@@ -294,7 +417,7 @@ func buildQueryListRequest(appName string, options *QueryOptions, subscriber boo
 	req.HealthyOnly = options.Healthy
 	if subscriber {
 		req.ClientIP = util.LocalIP()
-		req.UdpPort = v1.GetUDPPort()
+		req.UdpPort = udpPort
 	}
 	return req
 }
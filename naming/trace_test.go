@@ -0,0 +1,84 @@
+package naming
+
+import (
+	"context"
+	"github.com/celeskyking/go-nacos/api"
+	"github.com/celeskyking/go-nacos/internal/nacostest"
+	"github.com/celeskyking/go-nacos/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+)
+
+func attr(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	nacos.SetInstances("", "dev", "demo", &types.Host{IP: "10.0.0.1", Port: 8080, Weight: 1, Healthy: true, Enabled: true})
+	ns := newTestNamingService(nacos)
+	defer ns.Stop()
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "startup")
+	_, er := ns.HttpClient().WithContext(ctx).ListServiceInstance(&types.ServiceInstanceListOption{
+		ServiceName: "dev@@demo",
+		Clusters:    "DEFAULT",
+	})
+	if er != nil {
+		t.Fatalf("list instances error:%+v", er)
+	}
+	sl, er := ns.GetInstances("demo", &QueryOptions{Group: "dev"})
+	if er != nil {
+		t.Fatalf("get instances error:%+v", er)
+	}
+	defer sl.StopListen()
+	if sl.SelectOneContext(ctx) == nil {
+		t.Fatal("expect instance selected")
+	}
+	parent.End()
+
+	var request, attempt, selection sdktrace.ReadOnlySpan
+	for _, s := range recorder.Ended() {
+		switch {
+		case s.Name() == "nacos GET nacos/v1/ns/instance/list" && s.Parent().SpanID() == parent.SpanContext().SpanID():
+			request = s
+		case s.Name() == "nacos select instance" && s.Parent().SpanID() == parent.SpanContext().SpanID():
+			selection = s
+		}
+	}
+	if request == nil || selection == nil {
+		t.Fatal("expect request and selection spans under the caller's span")
+	}
+	for _, s := range recorder.Ended() {
+		if s.Name() == "nacos attempt" && s.Parent().SpanID() == request.SpanContext().SpanID() {
+			attempt = s
+		}
+	}
+	if attempt == nil {
+		t.Fatal("expect attempt span under request span")
+	}
+	if attr(request, api.AttrService).AsString() != "dev@@demo" || attr(request, api.AttrCluster).AsString() != "DEFAULT" {
+		t.Fatalf("unexpected request attributes:%v", request.Attributes())
+	}
+	if attr(attempt, api.AttrServerAddress).AsString() != nacos.Addr() || attr(attempt, api.AttrStatusCode).AsInt64() != 200 {
+		t.Fatalf("unexpected attempt attributes:%v", attempt.Attributes())
+	}
+	if attr(selection, AttrInstance).AsString() != "10.0.0.1:8080" || attr(selection, api.AttrService).AsString() != "demo" {
+		t.Fatalf("unexpected selection attributes:%v", selection.Attributes())
+	}
+}
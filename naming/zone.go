@@ -0,0 +1,69 @@
+package naming
+
+import (
+	"github.com/celeskyking/go-nacos/types"
+)
+
+const (
+	//ZoneMetadataKey 实例元数据中标识区域的key
+	ZoneMetadataKey = "zone"
+)
+
+//ZoneAffinity 同区域优先的路由策略,优先选择cluster或者元数据zone与调用方相同的实例,
+//本区域的健康实例不足的时候回退到其他的区域
+type ZoneAffinity struct {
+	//调用方所在的区域,discovery中为空的时候使用AppConfig.Cluster
+	Zone string
+	//本区域的健康实例数小于MinHealthy的时候回退到全部区域,小于等于0的时候只要存在健康实例就不回退
+	MinHealthy int
+	//本区域的健康实例占本区域全部实例的比例小于MinHealthyRatio的时候回退到全部区域
+	MinHealthyRatio float64
+}
+
+//InZone 判断实例是否属于当前的区域
+func (z *ZoneAffinity) InZone(instance *types.ServiceInstance) bool {
+	if instance.ClusterName == z.Zone {
+		return true
+	}
+	zone, ok := instance.Metadata[ZoneMetadataKey]
+	return ok && zone == z.Zone
+}
+
+//SetZoneAffinity 设置同区域优先的路由策略,nil为关闭
+func (s *ServerList) SetZoneAffinity(affinity *ZoneAffinity) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.zoneAffinity = affinity
+}
+
+func (s *ServerList) getZoneAffinity() *ZoneAffinity {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.zoneAffinity
+}
+
+//zoneFilter 返回同区域的过滤条件,需要回退到全部区域的时候返回nil
+//matches 是selector的过滤条件,candidate 是经过保护阈值和selector过滤之后的条件
+func (s *ServerList) zoneFilter(all []*types.ServiceInstance, matches, candidate func(instance *types.ServiceInstance) bool) func(instance *types.ServiceInstance) bool {
+	affinity := s.getZoneAffinity()
+	if affinity == nil || affinity.Zone == "" {
+		return nil
+	}
+	total, healthy := 0, 0
+	for _, i := range all {
+		if !affinity.InZone(i) || !matches(i) {
+			continue
+		}
+		total++
+		if i.Healthy && candidate(i) {
+			healthy++
+		}
+	}
+	if healthy == 0 || healthy < affinity.MinHealthy {
+		return nil
+	}
+	if float64(healthy)/float64(total) < affinity.MinHealthyRatio {
+		return nil
+	}
+	return affinity.InZone
+}
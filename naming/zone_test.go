@@ -0,0 +1,81 @@
+package naming
+
+import (
+	"fmt"
+	"github.com/celeskyking/go-nacos/types"
+	"testing"
+)
+
+func zoneInstances(zone string, healthy, unhealthy int) []*types.ServiceInstance {
+	var instances []*types.ServiceInstance
+	for i := 0; i < healthy+unhealthy; i++ {
+		instances = append(instances, &types.ServiceInstance{
+			IP:          fmt.Sprintf("10.0.%s.%d", zone, i),
+			Port:        8080,
+			ClusterName: "cn-" + zone,
+			Healthy:     i < healthy,
+		})
+	}
+	return instances
+}
+
+//selectZones 多次选择,返回选中的实例的cluster集合
+func selectZones(sl *ServerList, times int) map[string]int {
+	zones := make(map[string]int)
+	for i := 0; i < times; i++ {
+		if s := sl.SelectOne(); s != nil {
+			zones[s.ClusterName]++
+		}
+	}
+	return zones
+}
+
+func TestServerList_ZoneAffinity(t *testing.T) {
+	instances := append(zoneInstances("a", 3, 1), zoneInstances("b", 4, 0)...)
+	cases := []struct {
+		name     string
+		affinity *ZoneAffinity
+		fallback bool
+	}{
+		{"disabled", nil, true},
+		{"prefer local", &ZoneAffinity{Zone: "cn-a"}, false},
+		{"min healthy reached", &ZoneAffinity{Zone: "cn-a", MinHealthy: 3}, false},
+		{"min healthy not reached", &ZoneAffinity{Zone: "cn-a", MinHealthy: 4}, true},
+		{"ratio reached", &ZoneAffinity{Zone: "cn-a", MinHealthyRatio: 0.75}, false},
+		{"ratio not reached", &ZoneAffinity{Zone: "cn-a", MinHealthyRatio: 0.8}, true},
+		{"unknown zone", &ZoneAffinity{Zone: "cn-c"}, true},
+	}
+	for _, c := range cases {
+		sl := newTestServerList(instances...)
+		sl.SetZoneAffinity(c.affinity)
+		zones := selectZones(sl, 200)
+		if c.fallback && (zones["cn-a"] == 0 || zones["cn-b"] == 0) {
+			t.Errorf("%s: expect fallback to all zones, got:%v", c.name, zones)
+		}
+		if !c.fallback && zones["cn-b"] != 0 {
+			t.Errorf("%s: expect local zone only, got:%v", c.name, zones)
+		}
+	}
+}
+
+func TestServerList_ZoneAffinityMetadata(t *testing.T) {
+	sl := newTestServerList(
+		&types.ServiceInstance{IP: "10.0.0.1", Port: 8080, Healthy: true, Metadata: map[string]string{ZoneMetadataKey: "cn-a"}},
+		&types.ServiceInstance{IP: "10.0.0.2", Port: 8080, Healthy: true, Metadata: map[string]string{ZoneMetadataKey: "cn-b"}},
+	)
+	sl.SetZoneAffinity(&ZoneAffinity{Zone: "cn-a"})
+	for i := 0; i < 50; i++ {
+		if s := sl.SelectOne(); s == nil || s.IP != "10.0.0.1" {
+			t.Fatalf("expect instance in zone cn-a, got:%+v", s)
+		}
+	}
+}
+
+func TestServerList_ZoneAffinityFallbackWhenLocalDown(t *testing.T) {
+	sl := newTestServerList(append(zoneInstances("a", 0, 2), zoneInstances("b", 2, 0)...)...)
+	sl.SetZoneAffinity(&ZoneAffinity{Zone: "cn-a", MinHealthy: 1})
+	zones := selectZones(sl, 50)
+	if zones["cn-a"] != 0 || zones["cn-b"] != 50 {
+		t.Fatalf("expect healthy instances in zone cn-b, got:%v", zones)
+	}
+}
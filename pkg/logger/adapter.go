@@ -0,0 +1,102 @@
+package logger
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"log/slog"
+)
+
+//Nop 不输出任何日志
+type Nop struct {
+}
+
+func (Nop) Debug(msg string, fields ...Field) {}
+
+func (Nop) Info(msg string, fields ...Field) {}
+
+func (Nop) Warn(msg string, fields ...Field) {}
+
+func (Nop) Error(msg string, fields ...Field) {}
+
+//NewLogrus 使用logrus输出日志,l为nil的时候使用logrus的标准logger
+func NewLogrus(l logrus.FieldLogger) Logger {
+	if l == nil {
+		l = logrus.StandardLogger()
+	}
+	return &logrusLogger{logger: l}
+}
+
+type logrusLogger struct {
+	logger logrus.FieldLogger
+}
+
+func (l *logrusLogger) entry(fields []Field) logrus.FieldLogger {
+	if len(fields) == 0 {
+		return l.logger
+	}
+	f := make(logrus.Fields, len(fields))
+	for _, field := range fields {
+		f[field.Key] = field.Value
+	}
+	return l.logger.WithFields(f)
+}
+
+func (l *logrusLogger) Debug(msg string, fields ...Field) {
+	l.entry(fields).Debug(msg)
+}
+
+func (l *logrusLogger) Info(msg string, fields ...Field) {
+	l.entry(fields).Info(msg)
+}
+
+func (l *logrusLogger) Warn(msg string, fields ...Field) {
+	l.entry(fields).Warn(msg)
+}
+
+func (l *logrusLogger) Error(msg string, fields ...Field) {
+	l.entry(fields).Error(msg)
+}
+
+//NewSlog 使用log/slog输出日志,l为nil的时候使用slog.Default()
+func NewSlog(l *slog.Logger) Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &slogLogger{logger: l}
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l *slogLogger) log(level slog.Level, msg string, fields []Field) {
+	ctx := context.Background()
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, f := range fields {
+		if er, ok := f.Value.(error); ok {
+			attrs = append(attrs, slog.String(f.Key, er.Error()))
+			continue
+		}
+		attrs = append(attrs, slog.Any(f.Key, f.Value))
+	}
+	l.logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+func (l *slogLogger) Debug(msg string, fields ...Field) {
+	l.log(slog.LevelDebug, msg, fields)
+}
+
+func (l *slogLogger) Info(msg string, fields ...Field) {
+	l.log(slog.LevelInfo, msg, fields)
+}
+
+func (l *slogLogger) Warn(msg string, fields ...Field) {
+	l.log(slog.LevelWarn, msg, fields)
+}
+
+func (l *slogLogger) Error(msg string, fields ...Field) {
+	l.log(slog.LevelError, msg, fields)
+}
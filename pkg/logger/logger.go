@@ -0,0 +1,281 @@
+package logger
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+//Field 结构化日志的字段
+type Field struct {
+	Key string
+
+	Value interface{}
+}
+
+//F 创建字段
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+//Err 创建key为error的字段
+func Err(er error) Field {
+	return Field{Key: "error", Value: er}
+}
+
+//Logger sdk使用的日志接口,默认使用logrus的标准logger,可以通过SetLogger替换
+type Logger interface {
+	Debug(msg string, fields ...Field)
+
+	Info(msg string, fields ...Field)
+
+	Warn(msg string, fields ...Field)
+
+	Error(msg string, fields ...Field)
+}
+
+//Redacted 敏感字段的值替换为该值
+const Redacted = "******"
+
+//DefaultSensitiveKeys 默认脱敏的字段,按照小写比较,字段名包含其中任意一个的时候脱敏
+var DefaultSensitiveKeys = []string{"content", "password", "token", "secret", "accesskey", "credential"}
+
+type holder struct {
+	logger Logger
+}
+
+var (
+	current atomic.Value
+
+	lock sync.RWMutex
+
+	sensitiveKeys = DefaultSensitiveKeys
+
+	redaction = true
+)
+
+func init() {
+	current.Store(holder{logger: NewLogrus(nil)})
+}
+
+//SetLogger 设置sdk使用的Logger,为nil的时候不输出日志
+func SetLogger(l Logger) {
+	if l == nil {
+		l = Nop{}
+	}
+	current.Store(holder{logger: l})
+}
+
+//SetRedaction 开启或者关闭敏感字段的脱敏,默认开启
+func SetRedaction(enabled bool) {
+	lock.Lock()
+	defer lock.Unlock()
+	redaction = enabled
+}
+
+//SetSensitiveKeys 替换需要脱敏的字段名
+func SetSensitiveKeys(keys ...string) {
+	lock.Lock()
+	defer lock.Unlock()
+	sensitiveKeys = make([]string, 0, len(keys))
+	for _, k := range keys {
+		sensitiveKeys = append(sensitiveKeys, strings.ToLower(k))
+	}
+}
+
+//Sensitive 字段名是否需要脱敏
+func Sensitive(key string) bool {
+	lock.RLock()
+	defer lock.RUnlock()
+	if !redaction {
+		return false
+	}
+	k := strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(k, s) {
+			return true
+		}
+	}
+	return false
+}
+
+//Redact 返回脱敏之后的字段,map[string]string类型的值(例如实例的元数据)会按照key逐个脱敏,
+//error和[]error类型的值会去掉请求url中的查询参数,查询参数中可能包含实例的元数据等敏感信息
+func Redact(fields []Field) []Field {
+	var result []Field
+	for i, f := range fields {
+		v, changed := redactValue(f)
+		if !changed {
+			if result != nil {
+				result = append(result, f)
+			}
+			continue
+		}
+		if result == nil {
+			result = make([]Field, i, len(fields))
+			copy(result, fields[:i])
+		}
+		result = append(result, Field{Key: f.Key, Value: v})
+	}
+	if result == nil {
+		return fields
+	}
+	return result
+}
+
+func redactValue(f Field) (interface{}, bool) {
+	if Sensitive(f.Key) {
+		return Redacted, true
+	}
+	switch v := f.Value.(type) {
+	case error:
+		return redactError(v)
+	case []error:
+		return redactErrors(v)
+	}
+	m, ok := f.Value.(map[string]string)
+	if !ok {
+		return f.Value, false
+	}
+	var redacted map[string]string
+	for k := range m {
+		if !Sensitive(k) {
+			continue
+		}
+		if redacted == nil {
+			redacted = make(map[string]string, len(m))
+			for k2, v2 := range m {
+				redacted[k2] = v2
+			}
+		}
+		redacted[k] = Redacted
+	}
+	if redacted == nil {
+		return f.Value, false
+	}
+	return redacted, true
+}
+
+func get() Logger {
+	return current.Load().(holder).logger
+}
+
+//Debug 使用当前的Logger输出脱敏之后的日志
+func Debug(msg string, fields ...Field) {
+	get().Debug(msg, Redact(fields)...)
+}
+
+func Info(msg string, fields ...Field) {
+	get().Info(msg, Redact(fields)...)
+}
+
+func Warn(msg string, fields ...Field) {
+	get().Warn(msg, Redact(fields)...)
+}
+
+func Error(msg string, fields ...Field) {
+	get().Error(msg, Redact(fields)...)
+}
+
+//With 返回输出脱敏日志的Logger,l为nil的时候使用全局的Logger,之后通过SetLogger替换全局的Logger同样生效。
+//sdk的组件通过With保存各自的Logger,不会修改全局的Logger
+func With(l Logger) Logger {
+	switch l.(type) {
+	case nil:
+		return global{}
+	case global, redacting:
+		return l
+	}
+	return redacting{logger: l}
+}
+
+//global 使用全局的Logger
+type global struct {
+}
+
+func (global) Debug(msg string, fields ...Field) { Debug(msg, fields...) }
+
+func (global) Info(msg string, fields ...Field) { Info(msg, fields...) }
+
+func (global) Warn(msg string, fields ...Field) { Warn(msg, fields...) }
+
+func (global) Error(msg string, fields ...Field) { Error(msg, fields...) }
+
+//redacting 脱敏之后输出到logger
+type redacting struct {
+	logger Logger
+}
+
+func (r redacting) Debug(msg string, fields ...Field) { r.logger.Debug(msg, Redact(fields)...) }
+
+func (r redacting) Info(msg string, fields ...Field) { r.logger.Info(msg, Redact(fields)...) }
+
+func (r redacting) Warn(msg string, fields ...Field) { r.logger.Warn(msg, Redact(fields)...) }
+
+func (r redacting) Error(msg string, fields ...Field) { r.logger.Error(msg, Redact(fields)...) }
+
+func redactErrors(errs []error) (interface{}, bool) {
+	var result []error
+	for i, er := range errs {
+		v, changed := redactError(er)
+		if !changed {
+			if result != nil {
+				result = append(result, er)
+			}
+			continue
+		}
+		if result == nil {
+			result = make([]error, i, len(errs))
+			copy(result, errs[:i])
+		}
+		result = append(result, v.(error))
+	}
+	if result == nil {
+		return errs, false
+	}
+	return result, true
+}
+
+//redactError 把错误信息中请求url的查询参数替换为Redacted
+func redactError(er error) (interface{}, bool) {
+	if er == nil || !redactionEnabled() {
+		return er, false
+	}
+	urls := queryURLs(er, nil)
+	if len(urls) == 0 {
+		return er, false
+	}
+	msg := er.Error()
+	for _, u := range urls {
+		msg = strings.ReplaceAll(msg, u, u[:strings.IndexByte(u, '?')+1]+Redacted)
+	}
+	return errors.New(msg), true
+}
+
+//queryURLs 返回er以及er包装的错误中所有带有查询参数的请求url
+func queryURLs(er error, urls []string) []string {
+	if ue, ok := er.(*url.Error); ok && strings.Contains(ue.URL, "?") {
+		urls = append(urls, ue.URL)
+	}
+	switch e := er.(type) {
+	case interface{ Unwrap() error }:
+		if inner := e.Unwrap(); inner != nil {
+			urls = queryURLs(inner, urls)
+		}
+	case interface{ Unwrap() []error }:
+		for _, inner := range e.Unwrap() {
+			if inner != nil {
+				urls = queryURLs(inner, urls)
+			}
+		}
+	}
+	return urls
+}
+
+func redactionEnabled() bool {
+	lock.RLock()
+	defer lock.RUnlock()
+	return redaction
+}
@@ -0,0 +1,142 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type recorder struct {
+	msgs []string
+
+	fields [][]Field
+}
+
+func (r *recorder) log(msg string, fields []Field) {
+	r.msgs = append(r.msgs, msg)
+	r.fields = append(r.fields, fields)
+}
+
+func (r *recorder) Debug(msg string, fields ...Field) { r.log(msg, fields) }
+
+func (r *recorder) Info(msg string, fields ...Field) { r.log(msg, fields) }
+
+func (r *recorder) Warn(msg string, fields ...Field) { r.log(msg, fields) }
+
+func (r *recorder) Error(msg string, fields ...Field) { r.log(msg, fields) }
+
+func TestRedact(t *testing.T) {
+	meta := map[string]string{"zone": "a", "accessToken": "t"}
+	fields := []Field{F("dataId", "app"), F("content", "k=v"), F("Password", "p"), F("metadata", meta)}
+	redacted := Redact(fields)
+	if redacted[0].Value != "app" || redacted[1].Value != Redacted || redacted[2].Value != Redacted {
+		t.Fatalf("unexpected redaction:%+v", redacted)
+	}
+	m := redacted[3].Value.(map[string]string)
+	if m["zone"] != "a" || m["accessToken"] != Redacted {
+		t.Fatalf("expect metadata redacted by key, got:%v", m)
+	}
+	if meta["accessToken"] != "t" || fields[1].Value != "k=v" {
+		t.Fatal("expect original fields unchanged")
+	}
+	plain := []Field{F("service", "s")}
+	if got := Redact(plain); &got[0] != &plain[0] {
+		t.Fatal("expect fields without sensitive keys returned as is")
+	}
+}
+
+func TestSetRedaction(t *testing.T) {
+	defer SetRedaction(true)
+	defer SetSensitiveKeys(DefaultSensitiveKeys...)
+	SetSensitiveKeys("Ip")
+	if !Sensitive("ip") || Sensitive("content") {
+		t.Fatal("expect custom sensitive keys")
+	}
+	SetRedaction(false)
+	if Sensitive("ip") {
+		t.Fatal("expect redaction disabled")
+	}
+}
+
+func TestSetLogger(t *testing.T) {
+	defer SetLogger(NewLogrus(nil))
+	r := &recorder{}
+	SetLogger(r)
+	Warn("publish configs", F("content", "secret"), Err(errors.New("boom")))
+	if len(r.msgs) != 1 || r.fields[0][0].Value != Redacted {
+		t.Fatalf("expect redacted field delivered, got:%+v", r.fields)
+	}
+	SetLogger(nil)
+	if _, ok := get().(Nop); !ok {
+		t.Fatal("expect nil logger replaced by Nop")
+	}
+	Error("dropped")
+	if len(r.msgs) != 1 {
+		t.Fatal("expect previous logger not used")
+	}
+}
+
+func TestSlog(t *testing.T) {
+	defer SetLogger(NewLogrus(nil))
+	var buf bytes.Buffer
+	SetLogger(NewSlog(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))))
+	Debug("hidden")
+	Info("register", F("service", "demo"), F("token", "x"), Err(errors.New("boom")))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expect debug filtered, got:%q", buf.String())
+	}
+	var record map[string]interface{}
+	if er := json.Unmarshal([]byte(lines[0]), &record); er != nil {
+		t.Fatal(er)
+	}
+	if record["msg"] != "register" || record["service"] != "demo" || record["token"] != Redacted || record["error"] != "boom" {
+		t.Fatalf("unexpected record:%v", record)
+	}
+}
+
+func TestWith(t *testing.T) {
+	defer SetLogger(NewLogrus(nil))
+	own, global := &recorder{}, &recorder{}
+	SetLogger(global)
+	l := With(own)
+	l.Info("publish configs", F("content", "secret"))
+	if len(own.msgs) != 1 || own.fields[0][0].Value != Redacted || len(global.msgs) != 0 {
+		t.Fatalf("expect redacted field delivered to own logger only, got:%+v", own.fields)
+	}
+	if With(l) != l {
+		t.Fatal("expect wrapped logger returned as is")
+	}
+	//nil使用全局的Logger,之后替换全局的Logger同样生效
+	fallback := With(nil)
+	replaced := &recorder{}
+	SetLogger(replaced)
+	fallback.Warn("no servers")
+	if len(replaced.msgs) != 1 || len(global.msgs) != 0 {
+		t.Fatal("expect nil logger follows the global logger")
+	}
+}
+
+func TestRedactErrors(t *testing.T) {
+	ue := &url.Error{Op: "Post", URL: "http://127.0.0.1:8848/nacos/v1/ns/instance?ip=10.0.0.1&metadata=%7B%22token%22%3A%22t%22%7D", Err: errors.New("connection refused")}
+	wrapped := fmt.Errorf("register instance: %w", ue)
+	fields := Redact([]Field{Err(wrapped), F("errors", []error{errors.New("timeout"), ue})})
+	msg := fields[0].Value.(error).Error()
+	if strings.Contains(msg, "metadata") || !strings.Contains(msg, "/nacos/v1/ns/instance?"+Redacted) || !strings.Contains(msg, "connection refused") {
+		t.Fatalf("expect query string redacted, got:%s", msg)
+	}
+	errs := fields[1].Value.([]error)
+	if errs[0].Error() != "timeout" || strings.Contains(errs[1].Error(), "metadata") {
+		t.Fatalf("expect each error redacted, got:%v", errs)
+	}
+	defer SetRedaction(true)
+	SetRedaction(false)
+	if Redact([]Field{Err(ue)})[0].Value != error(ue) {
+		t.Fatal("expect error kept when redaction disabled")
+	}
+}
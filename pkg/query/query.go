@@ -27,6 +27,12 @@ func (tf TransferFunc) Transfer(value reflect.Value) string {
 
 var transfers = map[string]TransferFunc{
 	"json": func(value reflect.Value) string {
+		switch value.Kind() {
+		case reflect.Map, reflect.Ptr, reflect.Interface, reflect.Slice:
+			if value.IsNil() {
+				return ""
+			}
+		}
 		data, _ := json.Marshal(value.Interface())
 		return string(data)
 	},
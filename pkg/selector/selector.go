@@ -0,0 +1,261 @@
+package selector
+
+import (
+	"github.com/celeskyking/go-nacos/err"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+type Operator string
+
+const (
+	Equals       Operator = "="
+	NotEquals    Operator = "!="
+	In           Operator = "in"
+	NotIn        Operator = "notin"
+	Exists       Operator = "exists"
+	DoesNotExist Operator = "!"
+)
+
+//Requirement 单个的过滤条件
+type Requirement struct {
+	Key string
+
+	Operator Operator
+
+	Values []string
+}
+
+//Matches 判断labels是否满足当前的条件
+func (r *Requirement) Matches(labels map[string]string) bool {
+	v, ok := labels[r.Key]
+	switch r.Operator {
+	case Exists:
+		return ok
+	case DoesNotExist:
+		return !ok
+	case Equals:
+		return ok && v == r.Values[0]
+	case NotEquals:
+		return !ok || v != r.Values[0]
+	case In:
+		return ok && contains(r.Values, v)
+	case NotIn:
+		return !ok || !contains(r.Values, v)
+	}
+	return false
+}
+
+func (r *Requirement) String() string {
+	switch r.Operator {
+	case Exists:
+		return r.Key
+	case DoesNotExist:
+		return "!" + r.Key
+	case Equals, NotEquals:
+		return r.Key + " " + string(r.Operator) + " " + r.Values[0]
+	default:
+		return r.Key + " " + string(r.Operator) + " (" + strings.Join(r.Values, ",") + ")"
+	}
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+//Selector 实例的元数据过滤器,多个条件之间是and的关系,空的Selector匹配所有的实例
+type Selector []*Requirement
+
+//Matches 判断labels是否满足所有的条件
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, r := range s {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Selector) Empty() bool {
+	return len(s) == 0
+}
+
+func (s Selector) String() string {
+	var parts []string
+	for _, r := range s {
+		parts = append(parts, r.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+//Parse 解析selector表达式,例如: "version in (v2,v3), zone = cn-a, !canary"
+//支持 =, ==, !=, in, notin, key(存在), !key(不存在) 几种条件
+func Parse(expr string) (Selector, error) {
+	p := &parser{tokens: tokenize(expr), expr: expr}
+	var s Selector
+	if len(p.tokens) == 0 {
+		return s, nil
+	}
+	for {
+		r, er := p.requirement()
+		if er != nil {
+			return nil, er
+		}
+		s = append(s, r)
+		t, ok := p.next()
+		if !ok {
+			break
+		}
+		if t != "," {
+			return nil, p.errorf("expect ',' but got '%s'", t)
+		}
+	}
+	return s, nil
+}
+
+type parser struct {
+	expr string
+
+	tokens []string
+
+	pos int
+}
+
+func (p *parser) next() (string, bool) {
+	if p.pos >= len(p.tokens) {
+		return "", false
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	return t, true
+}
+
+func (p *parser) peek() (string, bool) {
+	if p.pos >= len(p.tokens) {
+		return "", false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return errors.Wrapf(err.ErrSelectorNotValid, "selector '%s': "+format, append([]interface{}{p.expr}, args...)...)
+}
+
+func (p *parser) requirement() (*Requirement, error) {
+	t, ok := p.next()
+	if !ok {
+		return nil, p.errorf("unexpected end")
+	}
+	if t == "!" {
+		key, ok := p.next()
+		if !ok || !isIdentifier(key) {
+			return nil, p.errorf("expect key after '!'")
+		}
+		return &Requirement{Key: key, Operator: DoesNotExist}, nil
+	}
+	if !isIdentifier(t) {
+		return nil, p.errorf("expect key but got '%s'", t)
+	}
+	key := t
+	op, ok := p.peek()
+	if !ok || op == "," {
+		return &Requirement{Key: key, Operator: Exists}, nil
+	}
+	p.pos++
+	switch op {
+	case "=", "==", "!=":
+		v, ok := p.next()
+		if !ok || !isIdentifier(v) {
+			return nil, p.errorf("expect value after '%s'", op)
+		}
+		operator := Equals
+		if op == "!=" {
+			operator = NotEquals
+		}
+		return &Requirement{Key: key, Operator: operator, Values: []string{v}}, nil
+	case "in", "notin":
+		values, er := p.values()
+		if er != nil {
+			return nil, er
+		}
+		return &Requirement{Key: key, Operator: Operator(op), Values: values}, nil
+	}
+	return nil, p.errorf("unknown operator '%s'", op)
+}
+
+func (p *parser) values() ([]string, error) {
+	if t, ok := p.next(); !ok || t != "(" {
+		return nil, p.errorf("expect '('")
+	}
+	var values []string
+	for {
+		v, ok := p.next()
+		if !ok {
+			return nil, p.errorf("expect ')'")
+		}
+		if v == ")" && len(values) == 0 {
+			return nil, p.errorf("empty value set")
+		}
+		if !isIdentifier(v) {
+			return nil, p.errorf("expect value but got '%s'", v)
+		}
+		values = append(values, v)
+		t, ok := p.next()
+		if !ok {
+			return nil, p.errorf("expect ')'")
+		}
+		if t == ")" {
+			return values, nil
+		}
+		if t != "," {
+			return nil, p.errorf("expect ',' or ')' but got '%s'", t)
+		}
+	}
+}
+
+func isIdentifier(t string) bool {
+	switch t {
+	case "", ",", "(", ")", "!", "=", "==", "!=":
+		return false
+	}
+	return true
+}
+
+func tokenize(expr string) []string {
+	var tokens []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+	rs := []rune(expr)
+	for i := 0; i < len(rs); i++ {
+		c := rs[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			flush()
+		case c == ',' || c == '(' || c == ')':
+			flush()
+			tokens = append(tokens, string(c))
+		case c == '!' || c == '=':
+			flush()
+			if i+1 < len(rs) && rs[i+1] == '=' {
+				tokens = append(tokens, string(c)+"=")
+				i++
+			} else {
+				tokens = append(tokens, string(c))
+			}
+		default:
+			current.WriteRune(c)
+		}
+	}
+	flush()
+	return tokens
+}
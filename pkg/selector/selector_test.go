@@ -0,0 +1,80 @@
+package selector
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	s, er := Parse("version in (v2,v3), zone = cn-a, !canary")
+	if er != nil {
+		t.Fatalf("parse error:%+v", er)
+	}
+	if len(s) != 3 {
+		t.Fatalf("expect 3 requirements, got:%d", len(s))
+	}
+	if s.String() != "version in (v2,v3), zone = cn-a, !canary" {
+		t.Errorf("unexpected string:%s", s.String())
+	}
+	cases := []struct {
+		labels map[string]string
+		match  bool
+	}{
+		{map[string]string{"version": "v2", "zone": "cn-a"}, true},
+		{map[string]string{"version": "v3", "zone": "cn-a", "env": "prod"}, true},
+		{map[string]string{"version": "v1", "zone": "cn-a"}, false},
+		{map[string]string{"version": "v2", "zone": "cn-b"}, false},
+		{map[string]string{"version": "v2", "zone": "cn-a", "canary": "true"}, false},
+		{nil, false},
+	}
+	for _, c := range cases {
+		if s.Matches(c.labels) != c.match {
+			t.Errorf("labels:%v, expect:%v", c.labels, c.match)
+		}
+	}
+}
+
+func TestParse_Operators(t *testing.T) {
+	cases := []struct {
+		expr   string
+		labels map[string]string
+		match  bool
+	}{
+		{"", map[string]string{"a": "b"}, true},
+		{"canary", map[string]string{"canary": "true"}, true},
+		{"canary", map[string]string{}, false},
+		{"zone==cn-a", map[string]string{"zone": "cn-a"}, true},
+		{"zone!=cn-a", map[string]string{"zone": "cn-b"}, true},
+		{"zone!=cn-a", map[string]string{}, true},
+		{"zone != cn-a", map[string]string{"zone": "cn-a"}, false},
+		{"version notin (v1)", map[string]string{"version": "v2"}, true},
+		{"version notin (v1, v2)", map[string]string{"version": "v2"}, false},
+	}
+	for _, c := range cases {
+		s, er := Parse(c.expr)
+		if er != nil {
+			t.Errorf("parse %s error:%+v", c.expr, er)
+			continue
+		}
+		if s.Matches(c.labels) != c.match {
+			t.Errorf("expr:%s, labels:%v, expect:%v", c.expr, c.labels, c.match)
+		}
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"version in v2",
+		"version in ()",
+		"version in (v2",
+		"zone =",
+		"zone = a b",
+		"!",
+		", zone",
+		"zone = a,",
+		"version like (v2)",
+	} {
+		if _, er := Parse(expr); er == nil {
+			t.Errorf("expect error for:%s", expr)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package stats
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+//LongPollOutcome 配置长轮询的结果
+type LongPollOutcome string
+
+const (
+	//LongPollChanged 有配置发生变化
+	LongPollChanged LongPollOutcome = "changed"
+	//LongPollUnchanged 服务端挂起超时,没有配置变化
+	LongPollUnchanged LongPollOutcome = "unchanged"
+	//LongPollError 请求失败
+	LongPollError LongPollOutcome = "error"
+)
+
+//Recorder 记录sdk内部的事件,默认不记录,metrics包提供了基于prometheus的实现
+type Recorder interface {
+	//Request 一次请求nacos server的结果,endpoint为方法和路径,code为0表示连接失败或者超时
+	Request(endpoint, server string, code int, latency time.Duration)
+	//LongPoll 一次配置长轮询的结果
+	LongPoll(outcome LongPollOutcome)
+	//ConfigChanged 收到配置变化的通知
+	ConfigChanged(group, dataID string)
+	//SnapshotFallback 远程加载配置失败,使用了本地快照
+	SnapshotFallback(group, dataID string)
+	//PushReceived 收到服务端推送的udp包
+	PushReceived()
+	//PushAcked 回复了服务端推送的ack
+	PushAcked()
+	//HeartbeatFailed 临时实例发送心跳失败
+	HeartbeatFailed(service string)
+	//ServerHealth nacos server健康检查状态的变化
+	ServerHealth(server string, healthy bool)
+	//InstanceCount 订阅的服务的实例个数
+	InstanceCount(namespace, group, service string, count int)
+	//ServiceProtected 订阅的服务进入或者退出保护阈值的保护状态
+	ServiceProtected(namespace, group, service string, protected bool)
+}
+
+type holder struct {
+	recorder Recorder
+}
+
+var current atomic.Value
+
+func init() {
+	current.Store(holder{recorder: Nop{}})
+}
+
+//SetRecorder 设置全局的Recorder,为nil的时候不再记录
+func SetRecorder(recorder Recorder) {
+	if recorder == nil {
+		recorder = Nop{}
+	}
+	current.Store(holder{recorder: recorder})
+}
+
+//Get 返回全局的Recorder
+func Get() Recorder {
+	return current.Load().(holder).recorder
+}
+
+//Nop 不记录任何事件
+type Nop struct {
+}
+
+func (Nop) Request(endpoint, server string, code int, latency time.Duration) {}
+
+func (Nop) LongPoll(outcome LongPollOutcome) {}
+
+func (Nop) ConfigChanged(group, dataID string) {}
+
+func (Nop) SnapshotFallback(group, dataID string) {}
+
+func (Nop) PushReceived() {}
+
+func (Nop) PushAcked() {}
+
+func (Nop) HeartbeatFailed(service string) {}
+
+func (Nop) ServerHealth(server string, healthy bool) {}
+
+func (Nop) InstanceCount(namespace, group, service string, count int) {}
+
+func (Nop) ServiceProtected(namespace, group, service string, protected bool) {}
+
+//With 返回r,r为nil的时候返回使用全局Recorder的实现,之后通过SetRecorder替换全局的Recorder同样生效。
+//sdk的组件通过With保存各自的Recorder,不会修改全局的Recorder
+func With(r Recorder) Recorder {
+	if r == nil {
+		return global{}
+	}
+	return r
+}
+
+//global 使用全局的Recorder
+type global struct {
+}
+
+func (global) Request(endpoint, server string, code int, latency time.Duration) {
+	Get().Request(endpoint, server, code, latency)
+}
+
+func (global) LongPoll(outcome LongPollOutcome) { Get().LongPoll(outcome) }
+
+func (global) ConfigChanged(group, dataID string) { Get().ConfigChanged(group, dataID) }
+
+func (global) SnapshotFallback(group, dataID string) { Get().SnapshotFallback(group, dataID) }
+
+func (global) PushReceived() { Get().PushReceived() }
+
+func (global) PushAcked() { Get().PushAcked() }
+
+func (global) HeartbeatFailed(service string) { Get().HeartbeatFailed(service) }
+
+func (global) ServerHealth(server string, healthy bool) { Get().ServerHealth(server, healthy) }
+
+func (global) InstanceCount(namespace, group, service string, count int) {
+	Get().InstanceCount(namespace, group, service, count)
+}
+
+func (global) ServiceProtected(namespace, group, service string, protected bool) {
+	Get().ServiceProtected(namespace, group, service, protected)
+}
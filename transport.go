@@ -0,0 +1,257 @@
+package nacos
+
+import (
+	"github.com/celeskyking/go-nacos/api"
+	"github.com/celeskyking/go-nacos/naming"
+	"github.com/celeskyking/go-nacos/types"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMaxRetries = 2
+)
+
+var ErrTransportClosed = errors.New("nacos transport closed")
+
+//OutlierEjection 被动的异常实例摘除,实例连续失败之后在一段时间内不再被选择
+type OutlierEjection struct {
+	//连续失败的次数,连接失败和5xx都算作失败
+	ConsecutiveFailures int
+	//摘除的时间
+	EjectionDuration time.Duration
+}
+
+//Transport 通过naming.ServerList做负载均衡的http.RoundTripper
+//请求的host格式为 service-name.group,没有group的时候使用默认的group,端口使用实例的端口
+type Transport struct {
+	//实际发送请求的RoundTripper,为空的时候使用http.DefaultTransport
+	Base http.RoundTripper
+	//连接失败的时候换一个实例重试的次数
+	MaxRetries int
+	//查询实例的namespace,为空的时候使用NamingService的namespace
+	Namespace string
+	//查询实例的集群
+	Cluster string
+	//异常实例摘除,为空的时候不开启
+	OutlierEjection *OutlierEjection
+
+	ns naming.NamingService
+
+	lock sync.Mutex
+
+	serverLists map[string]*naming.ServerList
+
+	outliers map[string]*outlier
+	//同一个服务并发的第一次请求只查询一次实例列表
+	group singleflight.Group
+
+	closed bool
+}
+
+type outlier struct {
+	failures int
+
+	ejectedUntil time.Time
+}
+
+//NewTransport 返回一个基于NamingService的Transport,例如:
+//http.Client{Transport: nacos.NewTransport(ns)}.Get("http://service-name.group/path")
+func NewTransport(ns naming.NamingService) *Transport {
+	return &Transport{
+		ns:          ns,
+		MaxRetries:  DefaultMaxRetries,
+		serverLists: make(map[string]*naming.ServerList),
+		outliers:    make(map[string]*outlier),
+	}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	serviceName, group := parseServiceHost(req.URL.Hostname())
+	sl, er := t.serverList(serviceName, group)
+	if er != nil {
+		return nil, er
+	}
+	tried := make(map[string]bool)
+	var lastErr error
+	for attempt := 0; attempt <= t.MaxRetries; attempt++ {
+		instance := sl.SelectFuncContext(req.Context(), func(instance *types.ServiceInstance) bool {
+			addr := instanceAddr(instance)
+			return !tried[addr] && !t.ejected(addr)
+		})
+		if instance == nil && attempt == 0 {
+			//全部的实例都被摘除的时候,忽略摘除的状态
+			instance = sl.SelectOneContext(req.Context())
+		}
+		if instance == nil {
+			break
+		}
+		addr := instanceAddr(instance)
+		tried[addr] = true
+		r, er := rewind(req, addr, attempt)
+		if er != nil {
+			return nil, er
+		}
+		resp, er := t.base().RoundTrip(r)
+		if er != nil {
+			t.onResult(addr, false)
+			lastErr = er
+			if api.IsConnectionError(er) && (req.Body == nil || req.GetBody != nil) {
+				continue
+			}
+			return nil, er
+		}
+		t.onResult(addr, resp.StatusCode < 500)
+		return resp, nil
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, errors.Errorf("nacos transport: no available instance for service %s, group %s", serviceName, group)
+}
+
+//CloseIdleConnections 关闭底层Transport的空闲连接,http.Client.CloseIdleConnections的时候调用,不会停止服务实例的监听
+func (t *Transport) CloseIdleConnections() {
+	if c, ok := t.base().(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+//Close 停止所有服务实例的监听,并且关闭底层Transport的空闲连接,之后的请求返回ErrTransportClosed
+func (t *Transport) Close() error {
+	t.lock.Lock()
+	t.closed = true
+	serverLists := t.serverLists
+	t.serverLists = make(map[string]*naming.ServerList)
+	t.lock.Unlock()
+	for _, sl := range serverLists {
+		sl.StopListen()
+	}
+	t.CloseIdleConnections()
+	return nil
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+//serverList 返回服务的实例列表,第一次请求的时候在锁外查询,不会阻塞其他服务的请求
+func (t *Transport) serverList(serviceName, group string) (*naming.ServerList, error) {
+	k := naming.Key(group, serviceName)
+	if sl, er := t.cached(k); sl != nil || er != nil {
+		return sl, er
+	}
+	v, er, _ := t.group.Do(k, func() (interface{}, error) {
+		if sl, er := t.cached(k); sl != nil || er != nil {
+			return sl, er
+		}
+		namespace := t.Namespace
+		if namespace == "" {
+			namespace = t.ns.GetNamespaceID()
+		}
+		sl, er := t.ns.GetInstances(serviceName, &naming.QueryOptions{
+			Namespace: namespace,
+			Group:     group,
+			Cluster:   t.Cluster,
+			Watch:     true,
+		})
+		if er != nil {
+			return nil, errors.Wrap(er, "nacos transport get instances")
+		}
+		t.lock.Lock()
+		defer t.lock.Unlock()
+		if t.closed {
+			sl.StopListen()
+			return nil, ErrTransportClosed
+		}
+		t.serverLists[k] = sl
+		return sl, nil
+	})
+	if er != nil {
+		return nil, er
+	}
+	return v.(*naming.ServerList), nil
+}
+
+func (t *Transport) cached(k string) (*naming.ServerList, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.closed {
+		return nil, ErrTransportClosed
+	}
+	return t.serverLists[k], nil
+}
+
+func (t *Transport) ejected(addr string) bool {
+	if t.OutlierEjection == nil {
+		return false
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	o, ok := t.outliers[addr]
+	return ok && time.Now().Before(o.ejectedUntil)
+}
+
+func (t *Transport) onResult(addr string, success bool) {
+	if t.OutlierEjection == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	o, ok := t.outliers[addr]
+	if success {
+		//成功并且不在摘除期内的实例不再保留状态,避免实例变化的时候map无限增长
+		if ok && !time.Now().Before(o.ejectedUntil) {
+			delete(t.outliers, addr)
+		} else if ok {
+			o.failures = 0
+		}
+		return
+	}
+	if !ok {
+		o = &outlier{}
+		t.outliers[addr] = o
+	}
+	o.failures++
+	if o.failures >= t.OutlierEjection.ConsecutiveFailures {
+		o.failures = 0
+		o.ejectedUntil = time.Now().Add(t.OutlierEjection.EjectionDuration)
+	}
+}
+
+//rewind 把请求改写为发往实例的请求,重试的时候重新获取body
+func rewind(req *http.Request, addr string, attempt int) (*http.Request, error) {
+	r := req.Clone(req.Context())
+	r.URL.Host = addr
+	r.Host = ""
+	if attempt > 0 && req.GetBody != nil {
+		body, er := req.GetBody()
+		if er != nil {
+			return nil, er
+		}
+		r.Body = body
+	}
+	return r, nil
+}
+
+//parseServiceHost 解析host, 格式为 service-name.group
+func parseServiceHost(host string) (string, string) {
+	i := strings.LastIndex(host, ".")
+	if i < 0 {
+		return host, ""
+	}
+	return host[:i], host[i+1:]
+}
+
+func instanceAddr(instance *types.ServiceInstance) string {
+	return net.JoinHostPort(instance.IP, strconv.Itoa(instance.Port))
+}
@@ -0,0 +1,174 @@
+package nacos
+
+import (
+	"errors"
+	"github.com/celeskyking/go-nacos/api"
+	"github.com/celeskyking/go-nacos/internal/nacostest"
+	"github.com/celeskyking/go-nacos/naming"
+	"github.com/celeskyking/go-nacos/types"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func toHost(t *testing.T, addr string) *types.Host {
+	ip, port, er := net.SplitHostPort(addr)
+	if er != nil {
+		t.Fatalf("split addr error:%+v", er)
+	}
+	p, _ := strconv.Atoi(port)
+	return &types.Host{IP: ip, Port: p, Weight: 1, Healthy: true, Enabled: true}
+}
+
+//closedAddr 返回一个没有监听的地址
+func closedAddr(t *testing.T) string {
+	lis, er := net.Listen("tcp", "127.0.0.1:0")
+	if er != nil {
+		t.Fatalf("listen error:%+v", er)
+	}
+	addr := lis.Addr().String()
+	_ = lis.Close()
+	return addr
+}
+
+func newTestTransport(t *testing.T, nacos *nacostest.Server) *Transport {
+	ns := naming.NewNamingService(&api.ServerOptions{
+		Addresses:  []string{nacos.Addr()},
+		LBStrategy: api.Direct,
+	})
+	return NewTransport(ns)
+}
+
+func TestTransport_RetryOnConnectionError(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello " + r.URL.Path))
+	}))
+	defer backend.Close()
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	nacos.SetInstances("", "dev", "demo", toHost(t, backend.Listener.Addr().String()), toHost(t, closedAddr(t)))
+
+	transport := newTestTransport(t, nacos)
+	defer transport.Close()
+	client := &http.Client{Transport: transport}
+	for i := 0; i < 20; i++ {
+		resp, er := client.Get("http://demo.dev/world")
+		if er != nil {
+			t.Fatalf("request error:%+v", er)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if string(body) != "hello /world" {
+			t.Fatalf("unexpected body:%s", body)
+		}
+	}
+}
+
+func TestTransport_OutlierEjection(t *testing.T) {
+	var healthy, broken int
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		healthy++
+	}))
+	defer ok.Close()
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		broken++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer bad.Close()
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	nacos.SetInstances("", "", "demo", toHost(t, ok.Listener.Addr().String()), toHost(t, bad.Listener.Addr().String()))
+
+	transport := newTestTransport(t, nacos)
+	defer transport.Close()
+	transport.OutlierEjection = &OutlierEjection{
+		ConsecutiveFailures: 2,
+		EjectionDuration:    time.Minute,
+	}
+	client := &http.Client{Transport: transport}
+	for i := 0; i < 100; i++ {
+		resp, er := client.Get("http://demo/")
+		if er != nil {
+			t.Fatalf("request error:%+v", er)
+		}
+		_ = resp.Body.Close()
+	}
+	if broken != 2 || healthy != 98 {
+		t.Fatalf("expect broken instance ejected after 2 failures, healthy:%d, broken:%d", healthy, broken)
+	}
+}
+
+func TestTransport_OutlierPrune(t *testing.T) {
+	transport := &Transport{
+		OutlierEjection: &OutlierEjection{ConsecutiveFailures: 1, EjectionDuration: time.Millisecond},
+		outliers:        make(map[string]*outlier),
+	}
+	transport.onResult("10.0.0.1:80", true)
+	transport.onResult("10.0.0.2:80", false)
+	transport.onResult("10.0.0.3:80", false)
+	transport.onResult("10.0.0.3:80", true)
+	if len(transport.outliers) != 2 {
+		t.Fatalf("expect no state for successful instances, got:%d", len(transport.outliers))
+	}
+	time.Sleep(5 * time.Millisecond)
+	transport.onResult("10.0.0.2:80", true)
+	transport.onResult("10.0.0.3:80", true)
+	if len(transport.outliers) != 0 {
+		t.Fatalf("expect state dropped after ejection expired, got:%d", len(transport.outliers))
+	}
+}
+
+func TestParseServiceHost(t *testing.T) {
+	cases := [][3]string{
+		{"demo", "demo", ""},
+		{"demo.dev", "demo", "dev"},
+		{"service-name.group", "service-name", "group"},
+	}
+	for _, c := range cases {
+		service, group := parseServiceHost(c[0])
+		if service != c[1] || group != c[2] {
+			t.Errorf("parse %s, got:%s %s", c[0], service, group)
+		}
+	}
+}
+
+func TestTransport_Close(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+	nacos := nacostest.NewServer()
+	defer nacos.Close()
+	nacos.SetInstances("", "dev", "demo", toHost(t, backend.Listener.Addr().String()))
+	transport := newTestTransport(t, nacos)
+	client := &http.Client{Transport: transport}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if resp, er := client.Get("http://demo.dev/"); er == nil {
+				_ = resp.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+	sl := transport.serverLists[naming.Key("dev", "demo")]
+	if len(transport.serverLists) != 1 || sl == nil {
+		t.Fatalf("expect one server list for concurrent requests, got:%d", len(transport.serverLists))
+	}
+	//http.Client.CloseIdleConnections不会停止实例的监听
+	client.CloseIdleConnections()
+	if transport.serverLists[naming.Key("dev", "demo")] != sl {
+		t.Fatal("expect server list kept after CloseIdleConnections")
+	}
+	if er := transport.Close(); er != nil {
+		t.Fatal(er)
+	}
+	if _, er := client.Get("http://demo.dev/"); !errors.Is(er, ErrTransportClosed) {
+		t.Fatalf("expect transport closed, got:%v", er)
+	}
+}
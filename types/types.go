@@ -2,6 +2,7 @@ package types
 
 import (
 	"github.com/celeskyking/go-nacos/err"
+	"github.com/pkg/errors"
 	"strings"
 )
 
@@ -119,7 +120,7 @@ type ServiceInstance struct {
 	//健康状态
 	Healthy bool `query:"healthy"`
 	//元数据
-	Metadata string `query:"metadata"`
+	Metadata map[string]string `query:"metadata" transfer:"json"`
 	//集群名
 	ClusterName string `query:"clusterName"`
 	//服务名
@@ -136,6 +137,12 @@ type HeartBeat struct {
 	GroupName string `query:"groupName"`
 
 	NamespaceID string `query:"namespaceId"`
+	//light beat的时候不携带beat,通过ip,port和clusterName定位实例
+	IP string `query:"ip"`
+
+	Port int `query:"port"`
+
+	ClusterName string `query:"clusterName"`
 	//实例心跳内容
 	Beat *Beat `query:"beat" transfer:"json"`
 }
@@ -158,6 +165,10 @@ type HeartBeatResult struct {
 
 	//间隔毫秒数
 	ClientBeatInterval int `json:"clientBeatInterval"`
+	//nacos的响应码,20404表示服务端找不到当前的实例
+	Code int `json:"code"`
+	//服务端开启了light beat,后续的心跳不需要携带beat的内容
+	LightBeatEnabled bool `json:"lightBeatEnabled"`
 }
 
 type ServiceInstanceListOption struct {
@@ -559,7 +570,9 @@ func NewNoneHealthChecker() *NoneHealthChecker {
 
 func NewHttpHealthChecker(path string) *HttpHealthChecker {
 	return &HttpHealthChecker{
-		Path: path,
+		Type:                 "HTTP",
+		Path:                 path,
+		ExpectedResponseCode: 200,
 	}
 }
 
@@ -578,6 +591,54 @@ func NewMySQLHealthChecker(user, pwd, cmd string) *MySQLHealthChecker {
 	}
 }
 
+//ValidateHealthChecker 校验health checker的参数,避免服务端使用不合法的参数导致实例一直不健康
+func ValidateHealthChecker(checker IHealthChecker) error {
+	if checker == nil {
+		return errors.Wrap(err.ErrHealthCheckerNotValid, "health checker is nil")
+	}
+	switch c := checker.(type) {
+	case *NoneHealthChecker:
+		return expectType(c.Type, "NONE")
+	case *TCPHealthChecker:
+		return expectType(c.Type, "TCP")
+	case *HttpHealthChecker:
+		if er := expectType(c.Type, "HTTP"); er != nil {
+			return er
+		}
+		if !strings.HasPrefix(c.Path, "/") {
+			return errors.Wrapf(err.ErrHealthCheckerNotValid, "http path must start with '/', path:%s", c.Path)
+		}
+		if c.ExpectedResponseCode < 100 || c.ExpectedResponseCode > 599 {
+			return errors.Wrapf(err.ErrHealthCheckerNotValid, "expected response code:%d", c.ExpectedResponseCode)
+		}
+		//headers的格式为 key1:value1|key2:value2
+		if c.Headers != "" {
+			for _, h := range strings.Split(c.Headers, "|") {
+				if kv := strings.SplitN(h, ":", 2); len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+					return errors.Wrapf(err.ErrHealthCheckerNotValid, "http header:%s", h)
+				}
+			}
+		}
+		return nil
+	case *MySQLHealthChecker:
+		if er := expectType(c.Type, "MYSQL"); er != nil {
+			return er
+		}
+		if c.User == "" || c.Cmd == "" {
+			return errors.Wrap(err.ErrHealthCheckerNotValid, "mysql user and cmd are required")
+		}
+		return nil
+	}
+	return nil
+}
+
+func expectType(actual, expected string) error {
+	if actual != expected {
+		return errors.Wrapf(err.ErrHealthCheckerNotValid, "expect type:%s, actual:%s", expected, actual)
+	}
+	return nil
+}
+
 type FileDesc struct {
 	//
 	Name string